@@ -0,0 +1,121 @@
+package checkconsul
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+type consulOpts struct {
+	Host          string  `short:"H" long:"host" default:"localhost" description:"Hostname of the Consul agent"`
+	Port          int     `short:"p" long:"port" default:"8500" description:"Port of the HTTP API"`
+	Token         string  `long:"token" description:"ACL token" env:"CONSUL_HTTP_TOKEN"`
+	Timeout       int     `long:"timeout" value-name:"SECONDS" default:"10" description:"Timeout in seconds"`
+	Service       string  `short:"s" long:"service" description:"Service name to check the health of the instances"`
+	Tag           string  `short:"t" long:"tag" description:"Check only the instances with the tag"`
+	Warning       float64 `short:"w" long:"warning" value-name:"PERCENT" default:"0" description:"warning if the unhealthy instances are over (%)"`
+	Critical      float64 `short:"c" long:"critical" value-name:"PERCENT" default:"50" description:"critical if the unhealthy instances are over (%)"`
+	WarningCount  *int    `long:"warning-count" value-name:"INSTANCES" description:"warning if the number of the unhealthy instances is over"`
+	CriticalCount *int    `long:"critical-count" value-name:"INSTANCES" description:"critical if the number of the unhealthy instances is over"`
+	CheckLeader   bool    `long:"check-leader" description:"Check the cluster has a leader instead of the service"`
+}
+
+// Do the plugin
+func Do() {
+	ckr := run(os.Args[1:])
+	ckr.Name = "Consul"
+	ckr.Exit()
+}
+
+func newClient(opts *consulOpts) (*api.Client, error) {
+	config := api.DefaultConfig()
+	config.Address = net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
+	config.Token = opts.Token
+	config.HttpClient = &http.Client{Timeout: time.Duration(opts.Timeout) * time.Second}
+	return api.NewClient(config)
+}
+
+// evalService checks the instances, which are unhealthy if any of the
+// checks is critical
+func evalService(opts *consulOpts, entries []*api.ServiceEntry) *checkers.Checker {
+	name := opts.Service
+	if opts.Tag != "" {
+		name += " (tag " + opts.Tag + ")"
+	}
+	if len(entries) == 0 {
+		return checkers.Critical(fmt.Sprintf("no instances of the service %s", name))
+	}
+
+	var unhealthy []string
+	for _, e := range entries {
+		var failing []string
+		for _, c := range e.Checks {
+			if c.Status == api.HealthCritical {
+				failing = append(failing, c.Name)
+			}
+		}
+		if len(failing) > 0 {
+			unhealthy = append(unhealthy, fmt.Sprintf("%s/%s: %s", e.Node.Node, e.Service.ID, strings.Join(failing, ", ")))
+		}
+	}
+
+	count := len(unhealthy)
+	pct := float64(count) / float64(len(entries)) * 100
+	checkSt := checkers.OK
+	if count > 0 {
+		if pct > opts.Critical || opts.CriticalCount != nil && count > *opts.CriticalCount {
+			checkSt = checkers.CRITICAL
+		} else if pct > opts.Warning || opts.WarningCount != nil && count > *opts.WarningCount {
+			checkSt = checkers.WARNING
+		}
+	}
+	msg := fmt.Sprintf("%d of %d instances of the service %s are unhealthy (%.0f%%)", count, len(entries), name, pct)
+	if count > 0 {
+		msg += "\n" + strings.Join(unhealthy, "\n")
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func checkLeader(client *api.Client) *checkers.Checker {
+	leader, err := client.Status().Leader()
+	if err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't get the leader: %s", err))
+	}
+	if leader == "" {
+		return checkers.Critical("the cluster has no leader")
+	}
+	return checkers.Ok(fmt.Sprintf("leader %s", leader))
+}
+
+func run(args []string) *checkers.Checker {
+	opts := consulOpts{}
+	_, err := flags.ParseArgs(&opts, args)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	client, err := newClient(&opts)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	if opts.CheckLeader {
+		return checkLeader(client)
+	}
+	if opts.Service == "" {
+		return checkers.Unknown("either --service or --check-leader is required")
+	}
+
+	entries, _, err := client.Health().Service(opts.Service, opts.Tag, false, nil)
+	if err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't get the health of the service %s: %s", opts.Service, err))
+	}
+	return evalService(&opts, entries)
+}
@@ -0,0 +1,82 @@
+package checkconsul
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+func entry(node, id string, statuses ...string) *api.ServiceEntry {
+	e := &api.ServiceEntry{
+		Node:    &api.Node{Node: node},
+		Service: &api.AgentService{ID: id, Service: "web"},
+	}
+	for i, st := range statuses {
+		e.Checks = append(e.Checks, &api.HealthCheck{Name: fmt.Sprintf("check%d", i+1), Status: st})
+	}
+	return e
+}
+
+func TestEvalService(t *testing.T) {
+	entries := []*api.ServiceEntry{
+		entry("node1", "web-1", api.HealthPassing, api.HealthPassing),
+		entry("node2", "web-2", api.HealthPassing, api.HealthCritical),
+		entry("node3", "web-3", api.HealthWarning, api.HealthPassing),
+		entry("node4", "web-4", api.HealthPassing),
+	}
+	ckr := evalService(&consulOpts{Service: "web", Warning: 0, Critical: 50}, entries)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "1 of 4 instances of the service web are unhealthy (25%)\nnode2/web-2: check2", ckr.Message)
+
+	ckr = evalService(&consulOpts{Service: "web", Warning: 0, Critical: 20}, entries)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	zero := 0
+	ckr = evalService(&consulOpts{Service: "web", Warning: 100, Critical: 100, CriticalCount: &zero}, entries)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = evalService(&consulOpts{Service: "web", Warning: 0, Critical: 50}, entries[:1])
+	assert.Equal(t, checkers.OK, ckr.Status)
+
+	ckr = evalService(&consulOpts{Service: "web", Tag: "canary", Warning: 0, Critical: 50}, nil)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "no instances of the service web (tag canary)", ckr.Message)
+}
+
+func TestRun(t *testing.T) {
+	leader := `"10.0.0.1:8300"`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/status/leader":
+			fmt.Fprint(w, leader)
+		case "/v1/health/service/web":
+			assert.Equal(t, "secret", r.Header.Get("X-Consul-Token"))
+			fmt.Fprint(w, `[{"Node":{"Node":"node1"},"Service":{"ID":"web-1","Service":"web"},"Checks":[{"Name":"http","Status":"critical"}]}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	host, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+
+	ckr := run([]string{"-H", host, "-p", port, "--check-leader"})
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "leader 10.0.0.1:8300", ckr.Message)
+
+	leader = `""`
+	ckr = run([]string{"-H", host, "-p", port, "--check-leader"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = run([]string{"-H", host, "-p", port, "--service", "web", "--token", "secret"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "1 of 1 instances of the service web are unhealthy (100%)\nnode1/web-1: http", ckr.Message)
+
+	ckr = run([]string{"-H", host, "-p", port})
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status)
+}
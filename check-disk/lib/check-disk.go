@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/mackerelio/checkers"
@@ -13,16 +15,19 @@ import (
 )
 
 var opts struct {
-	Warning       *string   `short:"w" long:"warning" value-name:"N, N%" description:"Exit with WARNING status if less than N units or N% of disk are free"`
-	Critical      *string   `short:"c" long:"critical" value-name:"N, N%" description:"Exit with CRITICAL status if less than N units or N% of disk are free"`
-	InodeWarning  *string   `short:"W" long:"iwarning" value-name:"N%" description:"Exit with WARNING status if less than PERCENT of inode space is free"`
-	InodeCritical *string   `short:"K" long:"icritical" value-name:"N%" description:"Exit with CRITICAL status if less than PERCENT of inode space is free"`
-	Path          *[]string `short:"p" long:"path" value-name:"PATH" description:"Mount point or block device as emitted by the mount(8) command (may be repeated)"`
-	Exclude       *[]string `short:"x" long:"exclude-device" value-name:"EXCLUDE PATH" description:"Ignore device (may be repeated; only works if -p unspecified)"`
-	All           bool      `short:"A" long:"all" description:"Explicitly select all paths."`
-	ExcludeType   *[]string `short:"X" long:"exclude-type" value-name:"TYPE" description:"Ignore all filesystems of indicated type (may be repeated)"`
-	IncludeType   *[]string `short:"N" long:"include-type" value-name:"TYPE" description:"Check only filesystems of indicated type (may be repeated)"`
-	Units         *string   `short:"u" long:"units" value-name:"STRING" description:"Choose bytes, kB, MB, GB, TB (default: MB)"`
+	Warning           *string       `short:"w" long:"warning" value-name:"N, N%" description:"Exit with WARNING status if less than N units or N% of disk are free"`
+	Critical          *string       `short:"c" long:"critical" value-name:"N, N%" description:"Exit with CRITICAL status if less than N units or N% of disk are free"`
+	InodeWarning      *string       `short:"W" long:"iwarning" value-name:"N%" description:"Exit with WARNING status if less than PERCENT of inode space is free"`
+	InodeCritical     *string       `short:"K" long:"icritical" value-name:"N%" description:"Exit with CRITICAL status if less than PERCENT of inode space is free"`
+	Path              *[]string     `short:"p" long:"path" value-name:"PATH" description:"Mount point or block device as emitted by the mount(8) command (may be repeated)"`
+	Exclude           *[]string     `short:"x" long:"exclude-device" value-name:"EXCLUDE PATH" description:"Ignore device (may be repeated; only works if -p unspecified)"`
+	ExcludeRegexp     *string       `long:"exclude-pattern" value-name:"REGEXP" description:"Ignore mount points matching the regular expression (only works if -p unspecified)"`
+	All               bool          `short:"A" long:"all" description:"Explicitly select all paths."`
+	ExcludeType       *[]string     `short:"X" long:"exclude-type" value-name:"TYPE" description:"Ignore all filesystems of indicated type (may be repeated)"`
+	IncludeType       *[]string     `short:"N" long:"include-type" value-name:"TYPE" description:"Check only filesystems of indicated type (may be repeated)"`
+	NoDefaultExcludes bool          `long:"no-default-excludes" description:"Do not ignore pseudo filesystems such as proc, sysfs and cgroup by default"`
+	NFSTimeout        time.Duration `long:"nfs-timeout" default:"5s" description:"Timeout to fetch the usage of network filesystems such as NFS"`
+	Units             *string       `short:"u" long:"units" value-name:"STRING" description:"Choose bytes, kB, MB, GB, TB (default: MB)"`
 }
 
 const (
@@ -95,7 +100,7 @@ func genMessage(disk *gpud.UsageStat, u unit) string {
 	inodesFreePctStr := ""
 	if disk.InodesTotal != 0 {
 		inodesFreePct := float64(100) - disk.InodesUsedPercent
-		inodesFreePctStr = fmt.Sprintf(" (inodes %.2f)", inodesFreePct)
+		inodesFreePctStr = fmt.Sprintf(", Inodes free percentage: %.2f", inodesFreePct)
 	}
 
 	return fmt.Sprintf("Path: %v, All: %.2f %v, Used: %.2f %v, Free: %.2f %v, Free percentage: %.2f%s", disk.Path, all, u.Name, used, u.Name, free, u.Name, freePct, inodesFreePctStr)
@@ -114,7 +119,7 @@ func run(args []string) *checkers.Checker {
 		os.Exit(1)
 	}
 
-	partitions, err := listPartitions()
+	partitions, err := listPartitions(opts.NoDefaultExcludes)
 	if err != nil {
 		return checkers.Unknown(fmt.Sprintf("Failed to fetch partitions: %s", err))
 	}
@@ -147,6 +152,14 @@ func run(args []string) *checkers.Checker {
 		if opts.Path == nil && opts.Exclude != nil {
 			partitions = filterPartitionsByExclusion(partitions, *opts.Exclude, mountpointOfPartition)
 		}
+
+		if opts.Path == nil && opts.ExcludeRegexp != nil {
+			re, err := regexp.Compile(*opts.ExcludeRegexp)
+			if err != nil {
+				return checkers.Unknown(fmt.Sprintf("Invalid arguments: %s", err))
+			}
+			partitions = filterPartitionsByRegexp(partitions, re, mountpointOfPartition)
+		}
 	}
 
 	if len(partitions) == 0 {
@@ -156,7 +169,15 @@ func run(args []string) *checkers.Checker {
 	var disks []*gpud.UsageStat
 
 	for _, partition := range partitions {
-		disk, err := gpud.Usage(partition.Mountpoint)
+		var disk *gpud.UsageStat
+		if remoteFstypes[partition.Fstype] {
+			disk, err = usageWithTimeout(partition.Mountpoint, opts.NFSTimeout)
+			if err == errUnresponsive {
+				return checkers.Critical(fmt.Sprintf("NFS mount %s is unresponsive", partition.Mountpoint))
+			}
+		} else {
+			disk, err = diskUsage(partition.Mountpoint)
+		}
 		if err != nil {
 			return checkers.Unknown(fmt.Sprintf("Failed to fetch disk usage: %s", err))
 		}
@@ -245,39 +266,88 @@ func run(args []string) *checkers.Checker {
 	return checkers.NewChecker(checkSt, msgss)
 }
 
+// pseudo filesystems ignored unless --no-default-excludes is specified
 // ref: mountlist.c in gnulib
 // https://github.com/coreutils/gnulib/blob/a742bdb3/lib/mountlist.c#L168
-func listPartitions() ([]gpud.PartitionStat, error) {
+var defaultExcludeTypes = map[string]bool{
+	"autofs":     true,
+	"proc":       true,
+	"subfs":      true,
+	"debugfs":    true,
+	"devpts":     true,
+	"fusectl":    true,
+	"mqueue":     true,
+	"rpc_pipefs": true,
+	"sysfs":      true,
+	"devfs":      true,
+	"kernfs":     true,
+	"ignore":     true,
+	"devtmpfs":   true,
+	"cgroup":     true,
+	"cgroup2":    true,
+	"pstore":     true,
+	"securityfs": true,
+	"hugetlbfs":  true,
+}
+
+func listPartitions(noDefaultExcludes bool) ([]gpud.PartitionStat, error) {
 	allPartitions, err := gpud.Partitions(true)
 	if err != nil {
 		return nil, err
 	}
+	if noDefaultExcludes {
+		return allPartitions, nil
+	}
+	return filterDefaultExcludes(allPartitions), nil
+}
+
+func filterDefaultExcludes(allPartitions []gpud.PartitionStat) []gpud.PartitionStat {
 	partitions := make([]gpud.PartitionStat, 0, len(allPartitions))
 	for _, p := range allPartitions {
-		switch p.Fstype {
-		case "autofs",
-			"proc",
-			"subfs",
-			"debugfs",
-			"devpts",
-			"fusectl",
-			"mqueue",
-			"rpc_pipefs",
-			"sysfs",
-			"devfs",
-			"kernfs",
-			"ignore":
+		if defaultExcludeTypes[p.Fstype] {
+			continue
+		}
+		if p.Fstype == "none" && strings.Contains(p.Opts, "bind") {
 			continue
-		case "none":
-			if !strings.Contains(p.Opts, "bind") {
-				partitions = append(partitions, p)
-			}
-		default:
-			partitions = append(partitions, p)
 		}
+		partitions = append(partitions, p)
 	}
+	return partitions
+}
 
-	return partitions, nil
+// network filesystems, which may hang statfs(2) when the server is gone
+var remoteFstypes = map[string]bool{
+	"nfs":       true,
+	"nfs4":      true,
+	"cifs":      true,
+	"smbfs":     true,
+	"smb3":      true,
+	"glusterfs": true,
+	"ceph":      true,
+}
+
+var diskUsage = gpud.Usage
+
+var errUnresponsive = errors.New("unresponsive")
+
+// usageWithTimeout gives up waiting for the usage after timeout. The goroutine
+// itself can not be canceled and remains until statfs(2) returns.
+func usageWithTimeout(path string, timeout time.Duration) (*gpud.UsageStat, error) {
+	type result struct {
+		disk *gpud.UsageStat
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		disk, err := diskUsage(path)
+		ch <- result{disk, err}
+	}()
+	select {
+	case r := <-ch:
+		return r.disk, r.err
+	case <-time.After(timeout):
+		return nil, errUnresponsive
+	}
 }
 
 func mountpointOfPartition(partition gpud.PartitionStat) string {
@@ -323,3 +393,14 @@ func filterPartitionsByExclusion(partitions []gpud.PartitionStat, list []string,
 
 	return newPartitions
 }
+
+func filterPartitionsByRegexp(partitions []gpud.PartitionStat, re *regexp.Regexp, key func(_ gpud.PartitionStat) string) []gpud.PartitionStat {
+	newPartitions := make([]gpud.PartitionStat, 0, len(partitions))
+	for _, partition := range partitions {
+		if !re.MatchString(key(partition)) {
+			newPartitions = append(newPartitions, partition)
+		}
+	}
+
+	return newPartitions
+}
@@ -0,0 +1,103 @@
+package checkdisk
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/checkers"
+	gpud "github.com/shirou/gopsutil/disk"
+	"github.com/stretchr/testify/assert"
+)
+
+var testPartitions = []gpud.PartitionStat{
+	{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4"},
+	{Device: "/dev/sdb1", Mountpoint: "/var/lib/docker", Fstype: "xfs"},
+	{Device: "overlay", Mountpoint: "/var/lib/docker/overlay2/abc/merged", Fstype: "overlay"},
+	{Device: "nfs:/export", Mountpoint: "/mnt/nfs", Fstype: "nfs4"},
+}
+
+func mountpoints(partitions []gpud.PartitionStat) []string {
+	var mps []string
+	for _, p := range partitions {
+		mps = append(mps, p.Mountpoint)
+	}
+	return mps
+}
+
+func TestFilterPartitionsByRegexp(t *testing.T) {
+	partitions := filterPartitionsByRegexp(testPartitions, regexp.MustCompile(`^/var/lib/docker/|^/mnt/`), mountpointOfPartition)
+	assert.Equal(t, []string{"/", "/var/lib/docker"}, mountpoints(partitions))
+}
+
+var testDisk = &gpud.UsageStat{
+	Path:              "/",
+	Total:             100 * 1024 * 1024,
+	Free:              20 * 1024 * 1024,
+	Used:              80 * 1024 * 1024,
+	UsedPercent:       80,
+	InodesTotal:       1000,
+	InodesUsed:        950,
+	InodesFree:        50,
+	InodesUsedPercent: 95,
+}
+
+func TestCheckInodes(t *testing.T) {
+	st, err := checkInodes(checkers.OK, "10%", testDisk, checkers.CRITICAL)
+	assert.Nil(t, err)
+	assert.Equal(t, checkers.CRITICAL, st)
+
+	st, err = checkInodes(checkers.OK, "5%", testDisk, checkers.CRITICAL)
+	assert.Nil(t, err)
+	assert.Equal(t, checkers.OK, st)
+
+	_, err = checkInodes(checkers.OK, "10", testDisk, checkers.CRITICAL)
+	assert.NotNil(t, err)
+
+	// disk space is still enough
+	st, err = checkDisk(checkers.OK, "10%", mb, testDisk, checkers.CRITICAL)
+	assert.Nil(t, err)
+	assert.Equal(t, checkers.OK, st)
+}
+
+func TestGenMessage(t *testing.T) {
+	assert.Equal(t,
+		"Path: /, All: 100.00 MB, Used: 80.00 MB, Free: 20.00 MB, Free percentage: 20.00, Inodes free percentage: 5.00",
+		genMessage(testDisk, unit{"MB", mb}))
+
+	noInodes := *testDisk
+	noInodes.InodesTotal = 0
+	assert.Equal(t,
+		"Path: /, All: 100.00 MB, Used: 80.00 MB, Free: 20.00 MB, Free percentage: 20.00",
+		genMessage(&noInodes, unit{"MB", mb}))
+}
+
+func TestFilterDefaultExcludes(t *testing.T) {
+	partitions := []gpud.PartitionStat{
+		{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4"},
+		{Device: "proc", Mountpoint: "/proc", Fstype: "proc"},
+		{Device: "cgroup2", Mountpoint: "/sys/fs/cgroup", Fstype: "cgroup2"},
+		{Device: "udev", Mountpoint: "/dev", Fstype: "devtmpfs"},
+		{Device: "tmpfs", Mountpoint: "/run", Fstype: "tmpfs"},
+		{Device: "/data", Mountpoint: "/srv/data", Fstype: "none", Opts: "rw,bind"},
+	}
+	assert.Equal(t, []string{"/", "/run"}, mountpoints(filterDefaultExcludes(partitions)))
+}
+
+func TestUsageWithTimeout(t *testing.T) {
+	orig := diskUsage
+	defer func() { diskUsage = orig }()
+	diskUsage = func(path string) (*gpud.UsageStat, error) {
+		if path == "/mnt/stale" {
+			time.Sleep(time.Second)
+		}
+		return &gpud.UsageStat{Path: path}, nil
+	}
+
+	disk, err := usageWithTimeout("/mnt/nfs", 100*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Equal(t, "/mnt/nfs", disk.Path)
+
+	_, err = usageWithTimeout("/mnt/stale", 100*time.Millisecond)
+	assert.Equal(t, errUnresponsive, err)
+}
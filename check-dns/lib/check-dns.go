@@ -0,0 +1,290 @@
+package checkdns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+type dnsOpts struct {
+	Host      string   `short:"H" long:"host" required:"true" description:"Host name to resolve"`
+	Type      string   `short:"t" long:"type" default:"A" choice:"A" choice:"AAAA" choice:"MX" choice:"TXT" choice:"CNAME" choice:"PTR" description:"Record type"`
+	Expected  []string `short:"e" long:"expected" value-name:"VALUE" description:"Expected value, critical if any other value is returned (repeatable)"`
+	Resolvers []string `short:"s" long:"resolver" value-name:"HOST:PORT" description:"DNS server to query instead of the system resolver, e.g. 8.8.8.8:53. If repeated, critical if the answers differ among them"`
+	Timeout   int      `long:"timeout" value-name:"SECONDS" default:"10" description:"Timeout in seconds"`
+	Warning   float64  `short:"w" long:"warning" value-name:"MSEC" description:"warning if the resolution time is over (ms)"`
+	Critical  float64  `short:"c" long:"critical" value-name:"MSEC" description:"critical if the resolution time is over (ms)"`
+	Reverse   bool     `long:"reverse" description:"Verify the forward-confirmed reverse DNS of the IP address given by --host"`
+}
+
+// resolver is implemented by net.Resolver
+type resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupCNAME(ctx context.Context, host string) (string, error)
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// Do the plugin
+func Do() {
+	ckr := run(os.Args[1:])
+	ckr.Name = "DNS"
+	ckr.Exit()
+}
+
+// newResolver returns the resolver which queries the server
+func newResolver(server string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// lookup returns the sorted values of the records
+func lookup(ctx context.Context, r resolver, typ, host string) ([]string, error) {
+	var values []string
+	switch typ {
+	case "A", "AAAA":
+		addrs, err := r.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			if (addr.IP.To4() != nil) == (typ == "A") {
+				values = append(values, addr.IP.String())
+			}
+		}
+	case "MX":
+		mxs, err := r.LookupMX(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			values = append(values, fmt.Sprintf("%d %s", mx.Pref, mx.Host))
+		}
+	case "TXT":
+		txts, err := r.LookupTXT(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		values = txts
+	case "CNAME":
+		cname, err := r.LookupCNAME(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		values = []string{cname}
+	case "PTR":
+		names, err := r.LookupAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		values = names
+	default:
+		return nil, fmt.Errorf("unsupported record type: %s", typ)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no %s records", typ)
+	}
+	sort.Strings(values)
+	return values, nil
+}
+
+// unexpectedValues returns the values which are not expected
+func unexpectedValues(values, expected []string) []string {
+	expectedSet := make(map[string]bool, len(expected))
+	for _, v := range expected {
+		expectedSet[v] = true
+	}
+	var unexpected []string
+	for _, v := range values {
+		if !expectedSet[v] {
+			unexpected = append(unexpected, v)
+		}
+	}
+	return unexpected
+}
+
+// namedResolver is the resolver with the name of the DNS server, which is
+// empty for the system resolver
+type namedResolver struct {
+	name string
+	resolver
+}
+
+type lookupResult struct {
+	values  []string
+	elapsed float64
+	err     error
+}
+
+// lookupAll resolves the host via each resolver concurrently
+func lookupAll(ctx context.Context, opts *dnsOpts, resolvers []namedResolver) []lookupResult {
+	results := make([]lookupResult, len(resolvers))
+	var wg sync.WaitGroup
+	for i, r := range resolvers {
+		wg.Add(1)
+		go func(i int, r namedResolver) {
+			defer wg.Done()
+			start := time.Now()
+			values, err := lookup(ctx, r, opts.Type, opts.Host)
+			results[i] = lookupResult{
+				values:  values,
+				elapsed: float64(time.Since(start)) / float64(time.Millisecond),
+				err:     err,
+			}
+		}(i, r)
+	}
+	wg.Wait()
+	return results
+}
+
+func checkDNS(opts *dnsOpts, resolvers []namedResolver) *checkers.Checker {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(opts.Timeout)*time.Second)
+	defer cancel()
+
+	results := lookupAll(ctx, opts, resolvers)
+	for i, res := range results {
+		if res.err != nil {
+			via := ""
+			if resolvers[i].name != "" {
+				via = " via " + resolvers[i].name
+			}
+			return checkers.Critical(fmt.Sprintf("couldn't resolve %s %s%s: %s", opts.Host, opts.Type, via, res.err))
+		}
+	}
+
+	checkSt := checkers.OK
+	var answers, unexpected, differ []string
+	for i, res := range results {
+		if opts.Critical > 0 && res.elapsed > opts.Critical {
+			checkSt = checkers.CRITICAL
+		} else if opts.Warning > 0 && res.elapsed > opts.Warning && checkSt == checkers.OK {
+			checkSt = checkers.WARNING
+		}
+
+		answer := fmt.Sprintf("%s (%.3f ms)", strings.Join(res.values, ", "), res.elapsed)
+		if len(resolvers) > 1 {
+			answer = resolvers[i].name + ": " + answer
+		}
+		answers = append(answers, answer)
+
+		if len(opts.Expected) > 0 {
+			unexpected = append(unexpected, unexpectedValues(res.values, opts.Expected)...)
+		}
+		if i > 0 && strings.Join(res.values, "\n") != strings.Join(results[0].values, "\n") {
+			differ = append(differ, resolvers[i].name)
+		}
+	}
+	msg := fmt.Sprintf("%s %s: %s", opts.Host, opts.Type, strings.Join(answers, "; "))
+
+	if len(unexpected) > 0 {
+		checkSt = checkers.CRITICAL
+		sort.Strings(unexpected)
+		msg += fmt.Sprintf(", unexpected: %s", strings.Join(uniq(unexpected), ", "))
+	}
+	if len(differ) > 0 {
+		checkSt = checkers.CRITICAL
+		msg += fmt.Sprintf(", answers of %s differ from %s", strings.Join(differ, ", "), resolvers[0].name)
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
+
+// checkReverse resolves the PTR records of the IP address and verifies that
+// any of the names resolves back to the address
+func checkReverse(opts *dnsOpts, r resolver) *checkers.Checker {
+	ip := net.ParseIP(opts.Host)
+	if ip == nil {
+		return checkers.Unknown(fmt.Sprintf("%s is not an IP address", opts.Host))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(opts.Timeout)*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	names, err := lookup(ctx, r, "PTR", opts.Host)
+	ptrElapsed := float64(time.Since(start)) / float64(time.Millisecond)
+	if err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't resolve %s PTR: %s", opts.Host, err))
+	}
+
+	var forwards []string
+	var fwdElapsed float64
+	confirmed := false
+	for _, name := range names {
+		start := time.Now()
+		addrs, err := r.LookupIPAddr(ctx, name)
+		elapsed := float64(time.Since(start)) / float64(time.Millisecond)
+		fwdElapsed += elapsed
+		if err != nil {
+			forwards = append(forwards, fmt.Sprintf("%s: %s (%.3f ms)", name, err, elapsed))
+			continue
+		}
+		var values []string
+		for _, addr := range addrs {
+			values = append(values, addr.IP.String())
+			if addr.IP.Equal(ip) {
+				confirmed = true
+			}
+		}
+		forwards = append(forwards, fmt.Sprintf("%s: %s (%.3f ms)", name, strings.Join(values, ", "), elapsed))
+	}
+	msg := fmt.Sprintf("%s PTR: %s (%.3f ms), forward %s", opts.Host, strings.Join(names, ", "), ptrElapsed, strings.Join(forwards, "; "))
+	if !confirmed {
+		return checkers.Critical("FCrDNS failed: PTR record hostname does not resolve back to IP\n" + msg)
+	}
+
+	checkSt := checkers.OK
+	total := ptrElapsed + fwdElapsed
+	if opts.Critical > 0 && total > opts.Critical {
+		checkSt = checkers.CRITICAL
+	} else if opts.Warning > 0 && total > opts.Warning {
+		checkSt = checkers.WARNING
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
+
+// uniq removes the duplicates from the sorted values
+func uniq(values []string) []string {
+	var result []string
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func run(args []string) *checkers.Checker {
+	opts := &dnsOpts{}
+	_, err := flags.ParseArgs(opts, args)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	resolvers := []namedResolver{{"", net.DefaultResolver}}
+	if len(opts.Resolvers) > 0 {
+		resolvers = nil
+		for _, server := range opts.Resolvers {
+			resolvers = append(resolvers, namedResolver{server, newResolver(server)})
+		}
+	}
+	if opts.Reverse {
+		if len(resolvers) > 1 {
+			return checkers.Unknown("--reverse can't be used with multiple --resolver")
+		}
+		return checkReverse(opts, resolvers[0])
+	}
+	return checkDNS(opts, resolvers)
+}
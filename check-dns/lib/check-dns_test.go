@@ -0,0 +1,193 @@
+package checkdns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeResolver answers the records of the maps
+type fakeResolver struct {
+	ips   map[string][]string
+	mxs   map[string][]*net.MX
+	txts  map[string][]string
+	names map[string][]string
+	delay time.Duration
+}
+
+var errNotFound = errors.New("no such host")
+
+func (r *fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	time.Sleep(r.delay)
+	ips, ok := r.ips[host]
+	if !ok {
+		return nil, errNotFound
+	}
+	var addrs []net.IPAddr
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs, nil
+}
+
+func (r *fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	mxs, ok := r.mxs[name]
+	if !ok {
+		return nil, errNotFound
+	}
+	return mxs, nil
+}
+
+func (r *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	txts, ok := r.txts[name]
+	if !ok {
+		return nil, errNotFound
+	}
+	return txts, nil
+}
+
+func (r *fakeResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	return host + ".cdn.example.net.", nil
+}
+
+func (r *fakeResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	names, ok := r.names[addr]
+	if !ok {
+		return nil, errNotFound
+	}
+	return names, nil
+}
+
+var testResolver = &fakeResolver{
+	ips: map[string][]string{
+		"example.com": {"192.0.2.2", "192.0.2.1", "2001:db8::1"},
+	},
+	mxs: map[string][]*net.MX{
+		"example.com": {{Host: "mx1.example.com.", Pref: 10}, {Host: "mx2.example.com.", Pref: 20}},
+	},
+	txts: map[string][]string{
+		"example.com": {"v=spf1 -all"},
+	},
+	names: map[string][]string{
+		"192.0.2.1": {"www.example.com."},
+	},
+}
+
+func TestLookup(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		typ, host string
+		values    []string
+	}{
+		{"A", "example.com", []string{"192.0.2.1", "192.0.2.2"}},
+		{"AAAA", "example.com", []string{"2001:db8::1"}},
+		{"MX", "example.com", []string{"10 mx1.example.com.", "20 mx2.example.com."}},
+		{"TXT", "example.com", []string{"v=spf1 -all"}},
+		{"CNAME", "www.example.com", []string{"www.example.com.cdn.example.net."}},
+		{"PTR", "192.0.2.1", []string{"www.example.com."}},
+	} {
+		values, err := lookup(ctx, testResolver, tc.typ, tc.host)
+		assert.Nil(t, err, tc.typ)
+		assert.Equal(t, tc.values, values, tc.typ)
+	}
+
+	_, err := lookup(ctx, testResolver, "A", "example.org")
+	assert.Equal(t, errNotFound, err)
+
+	_, err = lookup(ctx, &fakeResolver{ips: map[string][]string{"example.com": {"2001:db8::1"}}}, "A", "example.com")
+	assert.Equal(t, "no A records", err.Error())
+}
+
+func TestCheckDNS(t *testing.T) {
+	ckr := checkDNS(&dnsOpts{Host: "example.com", Type: "A", Timeout: 10}, []namedResolver{{"", testResolver}})
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Regexp(t, `^example.com A: 192.0.2.1, 192.0.2.2 \([\d.]+ ms\)$`, ckr.Message)
+
+	ckr = checkDNS(&dnsOpts{Host: "example.com", Type: "A", Timeout: 10, Expected: []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}}, []namedResolver{{"", testResolver}})
+	assert.Equal(t, checkers.OK, ckr.Status)
+
+	ckr = checkDNS(&dnsOpts{Host: "example.com", Type: "A", Timeout: 10, Expected: []string{"192.0.2.1"}}, []namedResolver{{"", testResolver}})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Regexp(t, `, unexpected: 192.0.2.2$`, ckr.Message)
+
+	ckr = checkDNS(&dnsOpts{Host: "example.org", Type: "A", Timeout: 10}, []namedResolver{{"", testResolver}})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "couldn't resolve example.org A: no such host", ckr.Message)
+
+	slow := &fakeResolver{ips: testResolver.ips, delay: 20 * time.Millisecond}
+	ckr = checkDNS(&dnsOpts{Host: "example.com", Type: "A", Timeout: 10, Warning: 10, Critical: 1000}, []namedResolver{{"", slow}})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	ckr = checkDNS(&dnsOpts{Host: "example.com", Type: "A", Timeout: 10, Warning: 1, Critical: 10}, []namedResolver{{"", slow}})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+}
+
+func TestCheckDNSWithResolvers(t *testing.T) {
+	stale := &fakeResolver{ips: map[string][]string{"example.com": {"192.0.2.3"}}}
+	ckr := checkDNS(&dnsOpts{Host: "example.com", Type: "A", Timeout: 10}, []namedResolver{
+		{"192.0.2.53:53", testResolver},
+		{"198.51.100.53:53", testResolver},
+	})
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Regexp(t, `^example.com A: 192.0.2.53:53: 192.0.2.1, 192.0.2.2 \([\d.]+ ms\); 198.51.100.53:53: 192.0.2.1, 192.0.2.2 \([\d.]+ ms\)$`, ckr.Message)
+
+	ckr = checkDNS(&dnsOpts{Host: "example.com", Type: "A", Timeout: 10}, []namedResolver{
+		{"192.0.2.53:53", testResolver},
+		{"198.51.100.53:53", stale},
+		{"203.0.113.53:53", testResolver},
+	})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Regexp(t, `; 198.51.100.53:53: 192.0.2.3 \([\d.]+ ms\); .*, answers of 198.51.100.53:53 differ from 192.0.2.53:53$`, ckr.Message)
+
+	ckr = checkDNS(&dnsOpts{Host: "example.com", Type: "A", Timeout: 10, Expected: []string{"192.0.2.1", "192.0.2.2"}}, []namedResolver{
+		{"192.0.2.53:53", testResolver},
+		{"198.51.100.53:53", stale},
+	})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Regexp(t, `, unexpected: 192.0.2.3, answers of`, ckr.Message)
+
+	ckr = checkDNS(&dnsOpts{Host: "example.com", Type: "AAAA", Timeout: 10}, []namedResolver{
+		{"192.0.2.53:53", testResolver},
+		{"198.51.100.53:53", stale},
+	})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "couldn't resolve example.com AAAA via 198.51.100.53:53: no AAAA records", ckr.Message)
+}
+
+func TestCheckReverse(t *testing.T) {
+	r := &fakeResolver{
+		ips: map[string][]string{
+			"mail.example.com.": {"192.0.2.25"},
+			"www.example.com.":  {"192.0.2.80"},
+		},
+		names: map[string][]string{
+			"192.0.2.25": {"mail.example.com."},
+			"192.0.2.26": {"www.example.com."},
+			"192.0.2.27": {"missing.example.com.", "mail.example.com."},
+		},
+	}
+
+	ckr := checkReverse(&dnsOpts{Host: "192.0.2.25", Timeout: 10}, r)
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Regexp(t, `^192.0.2.25 PTR: mail.example.com. \([\d.]+ ms\), forward mail.example.com.: 192.0.2.25 \([\d.]+ ms\)$`, ckr.Message)
+
+	ckr = checkReverse(&dnsOpts{Host: "192.0.2.26", Timeout: 10}, r)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Regexp(t, `^FCrDNS failed: PTR record hostname does not resolve back to IP\n192.0.2.26 PTR: www.example.com. `, ckr.Message)
+
+	ckr = checkReverse(&dnsOpts{Host: "192.0.2.27", Timeout: 10}, r)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status, "192.0.2.25 is not 192.0.2.27")
+	assert.Regexp(t, `forward mail.example.com.: 192.0.2.25 \([\d.]+ ms\); missing.example.com.: no such host`, ckr.Message)
+
+	ckr = checkReverse(&dnsOpts{Host: "192.0.2.28", Timeout: 10}, r)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "couldn't resolve 192.0.2.28 PTR: no such host", ckr.Message)
+
+	ckr = checkReverse(&dnsOpts{Host: "example.com", Timeout: 10}, r)
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status)
+}
@@ -0,0 +1,265 @@
+package checkdocker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+type dockerOpts struct {
+	Socket          string   `long:"socket" default:"/var/run/docker.sock" description:"Path to the socket of the Docker daemon"`
+	Container       string   `long:"container" required:"true" value-name:"REGEXP" description:"Regexp to match the container names or IDs"`
+	Status          string   `long:"status" default:"running" choice:"running" choice:"paused" choice:"exited" description:"Expected status of the containers"`
+	RestartWarning  *int     `long:"restart-warning" value-name:"N" description:"warning if the restart count is over"`
+	RestartCritical *int     `long:"restart-critical" value-name:"N" description:"critical if the restart count is over"`
+	CPUWarning      *float64 `long:"cpu-warning" value-name:"PERCENT" description:"warning if the CPU usage is over"`
+	CPUCritical     *float64 `long:"cpu-critical" value-name:"PERCENT" description:"critical if the CPU usage is over"`
+	MemoryWarning   *float64 `long:"memory-warning" value-name:"PERCENT" description:"warning if the memory usage of the limit is over"`
+	MemoryCritical  *float64 `long:"memory-critical" value-name:"PERCENT" description:"critical if the memory usage of the limit is over"`
+	Timeout         int      `long:"timeout" value-name:"SECONDS" default:"10" description:"Timeout in seconds"`
+}
+
+// Do the plugin
+func Do() {
+	ckr := run(os.Args[1:])
+	ckr.Name = "Docker"
+	ckr.Exit()
+}
+
+type containerState struct {
+	id           string
+	name         string
+	status       string
+	exitCode     int
+	restartCount int
+	startedAt    time.Time
+	// usage is nil unless the resource usage is checked
+	usage *resourceUsage
+}
+
+type resourceUsage struct {
+	cpu    float64
+	memory float64
+}
+
+func (opts *dockerOpts) checkUsage() bool {
+	return opts.CPUWarning != nil || opts.CPUCritical != nil ||
+		opts.MemoryWarning != nil || opts.MemoryCritical != nil
+}
+
+// cpuPercent computes the CPU usage in the same way as docker stats
+func cpuPercent(stats *types.StatsJSON) float64 {
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	numCPUs := float64(stats.CPUStats.OnlineCPUs)
+	if numCPUs == 0 {
+		// online_cpus is not provided before API 1.27
+		numCPUs = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+	return cpuDelta / systemDelta * numCPUs * 100
+}
+
+func memoryPercent(stats *types.StatsJSON) float64 {
+	if stats.MemoryStats.Limit == 0 {
+		return 0
+	}
+	return float64(stats.MemoryStats.Usage) / float64(stats.MemoryStats.Limit) * 100
+}
+
+func getUsage(ctx context.Context, cli client.APIClient, id string) (*resourceUsage, error) {
+	resp, err := cli.ContainerStats(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var stats types.StatsJSON
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, err
+	}
+	return &resourceUsage{
+		cpu:    cpuPercent(&stats),
+		memory: memoryPercent(&stats),
+	}, nil
+}
+
+// shortID returns the first 12 characters of the container ID as docker ps does
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// plural returns "1 day" or "2 days"
+func plural(n int64, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// humanizeDuration formats the duration like "3 days 2 hours" or "5 minutes"
+func humanizeDuration(dur time.Duration) string {
+	hours := int64(dur.Hours())
+	days := hours / 24
+	hours = hours % 24
+	mins := int64(dur.Minutes()) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%s %s", plural(days, "day"), plural(hours, "hour"))
+	case hours > 0:
+		return fmt.Sprintf("%s %s", plural(hours, "hour"), plural(mins, "minute"))
+	}
+	return plural(mins, "minute")
+}
+
+func evalThreshold(v float64, warning, critical *float64) checkers.Status {
+	if critical != nil && v > *critical {
+		return checkers.CRITICAL
+	}
+	if warning != nil && v > *warning {
+		return checkers.WARNING
+	}
+	return checkers.OK
+}
+
+func evalContainer(opts *dockerOpts, c *containerState, now time.Time) (checkers.Status, string) {
+	checkSt := checkers.OK
+	status := c.status
+	switch c.status {
+	case "running", "paused":
+		if !c.startedAt.IsZero() {
+			status += fmt.Sprintf(" (up %s)", humanizeDuration(now.Sub(c.startedAt)))
+		}
+	case "exited":
+		status += fmt.Sprintf(" (exit code %d)", c.exitCode)
+	}
+	if c.status != opts.Status {
+		checkSt = checkers.CRITICAL
+	}
+	if opts.RestartCritical != nil && c.restartCount > *opts.RestartCritical {
+		checkSt = checkers.CRITICAL
+	} else if opts.RestartWarning != nil && c.restartCount > *opts.RestartWarning && checkSt == checkers.OK {
+		checkSt = checkers.WARNING
+	}
+	msg := fmt.Sprintf("%s %s: %s, restarted %d times", shortID(c.id), c.name, status, c.restartCount)
+	if c.usage != nil {
+		if st := evalThreshold(c.usage.cpu, opts.CPUWarning, opts.CPUCritical); st > checkSt {
+			checkSt = st
+		}
+		if st := evalThreshold(c.usage.memory, opts.MemoryWarning, opts.MemoryCritical); st > checkSt {
+			checkSt = st
+		}
+		msg += fmt.Sprintf(", CPU %.2f%%, memory %.2f%%", c.usage.cpu, c.usage.memory)
+	}
+	return checkSt, msg
+}
+
+func evalContainers(opts *dockerOpts, containers []*containerState, now time.Time) *checkers.Checker {
+	if len(containers) == 0 {
+		return checkers.Critical(fmt.Sprintf("no containers match %s", opts.Container))
+	}
+	checkSt := checkers.OK
+	msgs := make([]string, 0, len(containers))
+	for _, c := range containers {
+		st, msg := evalContainer(opts, c, now)
+		if st > checkSt {
+			checkSt = st
+		}
+		msgs = append(msgs, msg)
+	}
+	return checkers.NewChecker(checkSt, strings.Join(msgs, "\n"))
+}
+
+// matchContainer reports whether any name or the ID of the container matches
+func matchContainer(c types.Container, reg *regexp.Regexp) (string, bool) {
+	name := ""
+	if len(c.Names) > 0 {
+		name = strings.TrimPrefix(c.Names[0], "/")
+	}
+	for _, n := range c.Names {
+		if reg.MatchString(strings.TrimPrefix(n, "/")) {
+			return name, true
+		}
+	}
+	return name, reg.MatchString(c.ID)
+}
+
+func getContainers(ctx context.Context, cli client.APIClient, reg *regexp.Regexp, withUsage bool) ([]*containerState, error) {
+	list, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	var containers []*containerState
+	for _, c := range list {
+		name, ok := matchContainer(c, reg)
+		if !ok {
+			continue
+		}
+		info, err := cli.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			return nil, err
+		}
+		st := &containerState{
+			id:           c.ID,
+			name:         name,
+			status:       c.State,
+			restartCount: info.RestartCount,
+		}
+		if info.State != nil {
+			st.status = info.State.Status
+			st.exitCode = info.State.ExitCode
+			// StartedAt is "0001-01-01T00:00:00Z" if the container has never started
+			st.startedAt, _ = time.Parse(time.RFC3339Nano, info.State.StartedAt)
+		}
+		if withUsage && st.status == "running" {
+			if st.usage, err = getUsage(ctx, cli, c.ID); err != nil {
+				return nil, err
+			}
+		}
+		containers = append(containers, st)
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].name < containers[j].name
+	})
+	return containers, nil
+}
+
+func run(args []string) *checkers.Checker {
+	opts := dockerOpts{}
+	_, err := flags.ParseArgs(&opts, args)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	reg, err := regexp.Compile(opts.Container)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	cli, err := client.NewClientWithOpts(client.WithHost("unix://"+opts.Socket), client.WithAPIVersionNegotiation())
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(opts.Timeout)*time.Second)
+	defer cancel()
+	containers, err := getContainers(ctx, cli, reg, opts.checkUsage())
+	if err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't get the containers: %s", err))
+	}
+	return evalContainers(&opts, containers, time.Now())
+}
@@ -0,0 +1,189 @@
+package checkdocker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvalContainers(t *testing.T) {
+	now := time.Date(2018, 4, 5, 12, 0, 0, 0, time.UTC)
+	web := &containerState{
+		id:           "0123456789abcdef0123456789abcdef",
+		name:         "web",
+		status:       "running",
+		restartCount: 2,
+		startedAt:    now.Add(-(50*time.Hour + 10*time.Minute)),
+	}
+	worker := &containerState{
+		id:           "fedcba9876543210fedcba9876543210",
+		name:         "worker",
+		status:       "exited",
+		exitCode:     137,
+		restartCount: 0,
+		startedAt:    now.Add(-time.Hour),
+	}
+
+	ckr := evalContainers(&dockerOpts{Container: "web", Status: "running"}, []*containerState{web}, now)
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "0123456789ab web: running (up 2 days 2 hours), restarted 2 times", ckr.Message)
+
+	restartWarning, restartCritical := 1, 5
+	ckr = evalContainers(&dockerOpts{Container: "web", Status: "running", RestartWarning: &restartWarning, RestartCritical: &restartCritical}, []*containerState{web}, now)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	ckr = evalContainers(&dockerOpts{Container: ".", Status: "running", RestartWarning: &restartWarning}, []*containerState{web, worker}, now)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "0123456789ab web: running (up 2 days 2 hours), restarted 2 times\n"+
+		"fedcba987654 worker: exited (exit code 137), restarted 0 times", ckr.Message)
+
+	ckr = evalContainers(&dockerOpts{Container: "worker", Status: "exited"}, []*containerState{worker}, now)
+	assert.Equal(t, checkers.OK, ckr.Status)
+
+	ckr = evalContainers(&dockerOpts{Container: "db", Status: "running"}, nil, now)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "no containers match db", ckr.Message)
+}
+
+func TestGetContainers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1.40/containers/json":
+			fmt.Fprint(w, `[
+				{"Id": "0123456789abcdef", "Names": ["/web"], "State": "running"},
+				{"Id": "fedcba9876543210", "Names": ["/worker"], "State": "exited"},
+				{"Id": "aaaabbbbccccdddd", "Names": ["/db"], "State": "running"}
+			]`)
+		case "/v1.40/containers/0123456789abcdef/json":
+			fmt.Fprint(w, `{"Id": "0123456789abcdef", "RestartCount": 3, "State": {"Status": "running", "ExitCode": 0, "StartedAt": "2018-04-05T10:00:00.123456789Z"}}`)
+		case "/v1.40/containers/fedcba9876543210/json":
+			fmt.Fprint(w, `{"Id": "fedcba9876543210", "RestartCount": 0, "State": {"Status": "exited", "ExitCode": 1, "StartedAt": "2018-04-05T09:00:00Z"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+ts.Listener.Addr().String()), client.WithVersion("1.40"))
+	assert.Nil(t, err)
+	containers, err := getContainers(context.Background(), cli, regexp.MustCompile(`^(web|worker)$`), false)
+	assert.Nil(t, err)
+	assert.Equal(t, []*containerState{
+		{
+			id:           "0123456789abcdef",
+			name:         "web",
+			status:       "running",
+			restartCount: 3,
+			startedAt:    time.Date(2018, 4, 5, 10, 0, 0, 123456789, time.UTC),
+		},
+		{
+			id:        "fedcba9876543210",
+			name:      "worker",
+			status:    "exited",
+			exitCode:  1,
+			startedAt: time.Date(2018, 4, 5, 9, 0, 0, 0, time.UTC),
+		},
+	}, containers)
+
+	containers, err = getContainers(context.Background(), cli, regexp.MustCompile(`^aaaabbbb`), false)
+	assert.NotNil(t, err)
+	assert.Nil(t, containers)
+}
+
+func TestGetContainersWithUsage(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1.40/containers/json":
+			fmt.Fprint(w, `[
+				{"Id": "0123456789abcdef", "Names": ["/web"], "State": "running"},
+				{"Id": "fedcba9876543210", "Names": ["/worker"], "State": "exited"}
+			]`)
+		case "/v1.40/containers/0123456789abcdef/json":
+			fmt.Fprint(w, `{"Id": "0123456789abcdef", "RestartCount": 0, "State": {"Status": "running", "StartedAt": "2018-04-05T10:00:00Z"}}`)
+		case "/v1.40/containers/fedcba9876543210/json":
+			fmt.Fprint(w, `{"Id": "fedcba9876543210", "RestartCount": 0, "State": {"Status": "exited", "ExitCode": 0, "StartedAt": "2018-04-05T09:00:00Z"}}`)
+		case "/v1.40/containers/0123456789abcdef/stats":
+			if r.URL.Query().Get("stream") != "0" {
+				http.Error(w, "stream is not supported", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{
+				"cpu_stats": {"cpu_usage": {"total_usage": 1500000000}, "system_cpu_usage": 21000000000, "online_cpus": 4},
+				"precpu_stats": {"cpu_usage": {"total_usage": 1000000000}, "system_cpu_usage": 17000000000},
+				"memory_stats": {"usage": 268435456, "limit": 1073741824}
+			}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+ts.Listener.Addr().String()), client.WithVersion("1.40"))
+	assert.Nil(t, err)
+	containers, err := getContainers(context.Background(), cli, regexp.MustCompile(`.`), true)
+	assert.Nil(t, err)
+	assert.Len(t, containers, 2)
+	assert.Equal(t, &resourceUsage{cpu: 50, memory: 25}, containers[0].usage)
+	// the resource usage of the stopped containers is not checked
+	assert.Nil(t, containers[1].usage)
+}
+
+func TestCPUPercent(t *testing.T) {
+	var stats types.StatsJSON
+	stats.CPUStats.CPUUsage.TotalUsage = 1200000000
+	stats.CPUStats.CPUUsage.PercpuUsage = []uint64{600000000, 600000000}
+	stats.CPUStats.SystemUsage = 22000000000
+	stats.PreCPUStats.CPUUsage.TotalUsage = 1000000000
+	stats.PreCPUStats.SystemUsage = 20000000000
+	// without online_cpus, the number of the CPUs is len(percpu_usage)
+	assert.InDelta(t, 20.0, cpuPercent(&stats), 1e-9)
+
+	stats.CPUStats.OnlineCPUs = 4
+	assert.InDelta(t, 40.0, cpuPercent(&stats), 1e-9)
+
+	stats.PreCPUStats.SystemUsage = 0
+	stats.CPUStats.SystemUsage = 0
+	assert.Equal(t, 0.0, cpuPercent(&stats))
+}
+
+func TestEvalContainersWithUsage(t *testing.T) {
+	now := time.Date(2018, 4, 5, 12, 0, 0, 0, time.UTC)
+	web := &containerState{
+		id:        "0123456789abcdef0123456789abcdef",
+		name:      "web",
+		status:    "running",
+		startedAt: now.Add(-30 * time.Minute),
+		usage:     &resourceUsage{cpu: 85.5, memory: 40},
+	}
+	cpuWarning, cpuCritical := 80.0, 95.0
+	memoryWarning, memoryCritical := 70.0, 90.0
+	opts := &dockerOpts{
+		Container:      "web",
+		Status:         "running",
+		CPUWarning:     &cpuWarning,
+		CPUCritical:    &cpuCritical,
+		MemoryWarning:  &memoryWarning,
+		MemoryCritical: &memoryCritical,
+	}
+
+	ckr := evalContainers(opts, []*containerState{web}, now)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "0123456789ab web: running (up 30 minutes), restarted 0 times, CPU 85.50%, memory 40.00%", ckr.Message)
+
+	web.usage = &resourceUsage{cpu: 10, memory: 92.5}
+	ckr = evalContainers(opts, []*containerState{web}, now)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	web.usage = &resourceUsage{cpu: 10, memory: 20}
+	ckr = evalContainers(opts, []*containerState{web}, now)
+	assert.Equal(t, checkers.OK, ckr.Status)
+}
@@ -12,14 +12,28 @@ import (
 )
 
 type healthStat struct {
-	ClusterName string `json:"cluster_name"`
-	Status      string `json:"status"`
+	ClusterName         string `json:"cluster_name"`
+	Status              string `json:"status"`
+	NumberOfNodes       int    `json:"number_of_nodes"`
+	ActivePrimaryShards int    `json:"active_primary_shards"`
+	ActiveShards        int    `json:"active_shards"`
+	RelocatingShards    int    `json:"relocating_shards"`
+	UnassignedShards    int    `json:"unassigned_shards"`
 }
 
-var opts struct {
-	Scheme string `short:"s" long:"scheme" default:"http" description:"Elasticsearch scheme"`
-	Host   string `short:"H" long:"host" default:"localhost" description:"Elasticsearch host"`
-	Port   int64  `short:"p" long:"port" default:"9200" description:"Elasticsearch port"`
+type elasticsearchOpts struct {
+	Scheme                   string `short:"s" long:"scheme" default:"http" description:"Elasticsearch scheme"`
+	Host                     string `short:"H" long:"host" default:"localhost" description:"Elasticsearch host"`
+	Port                     int64  `short:"p" long:"port" default:"9200" description:"Elasticsearch port"`
+	User                     string `long:"user" description:"Username for basic auth"`
+	Password                 string `long:"password" description:"Password for basic auth" env:"ELASTICSEARCH_PASSWORD"`
+	Timeout                  int    `long:"timeout" default:"10" description:"Timeout in seconds"`
+	UnassignedShardsCritical *int   `long:"unassigned-shards-critical" value-name:"N" description:"critical if the number of unassigned shards is over"`
+	Index                    string `long:"index" value-name:"INDEX" description:"check the indices matching INDEX (wildcards allowed) instead of the cluster health"`
+	DocCountWarn             *int64 `long:"doc-count-warning" value-name:"N" description:"warning if docs.count of an index is under (with --index)"`
+	DocCountCrit             *int64 `long:"doc-count-critical" value-name:"N" description:"critical if docs.count of an index is under (with --index)"`
+	IndexSizeWarn            string `long:"index-size-warning" value-name:"N[KMGT]" description:"warning if store.size of an index is over (with --index)"`
+	IndexSizeCrit            string `long:"index-size-critical" value-name:"N[KMGT]" description:"critical if store.size of an index is over (with --index)"`
 }
 
 // Do the plugin
@@ -29,33 +43,48 @@ func Do() {
 	ckr.Exit()
 }
 
-func run(args []string) *checkers.Checker {
-	_, err := flags.ParseArgs(&opts, args)
-	if err != nil {
-		os.Exit(1)
-	}
-
-	client := &http.Client{}
-	url := fmt.Sprintf("%s://%s:%d/_cluster/health", opts.Scheme, opts.Host, opts.Port)
-
-	stTime := time.Now()
+// getJSON requests the path of the API and decodes the JSON response into v
+func getJSON(opts *elasticsearchOpts, path string, v interface{}) error {
+	client := &http.Client{Timeout: time.Duration(opts.Timeout) * time.Second}
+	url := fmt.Sprintf("%s://%s:%d%s", opts.Scheme, opts.Host, opts.Port, path)
 
 	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return checkers.Unknown(err.Error())
+		return err
 	}
 	req.Header.Set("User-Agent", "check-elasticsearch")
+	if opts.User != "" {
+		req.SetBasicAuth(opts.User, opts.Password)
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return checkers.Critical(err.Error())
+		return err
 	}
-	elapsed := time.Since(stTime)
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
 
-	dec := json.NewDecoder(resp.Body)
+func run(args []string) *checkers.Checker {
+	opts := &elasticsearchOpts{}
+	_, err := flags.ParseArgs(opts, args)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	if opts.Index != "" {
+		return checkIndex(opts)
+	}
+
+	stTime := time.Now()
 	var health healthStat
-	dec.Decode(&health)
+	if err := getJSON(opts, "/_cluster/health", &health); err != nil {
+		return checkers.Critical(err.Error())
+	}
+	elapsed := time.Since(stTime)
 
 	checkSt := checkers.UNKNOWN
 	switch health.Status {
@@ -68,9 +97,13 @@ func run(args []string) *checkers.Checker {
 	default:
 		checkSt = checkers.UNKNOWN
 	}
+	if opts.UnassignedShardsCritical != nil && health.UnassignedShards > *opts.UnassignedShardsCritical {
+		checkSt = checkers.CRITICAL
+	}
 
-	msg := fmt.Sprintf("%s (cluster: %s) - %f second response time",
-		health.Status, health.ClusterName, elapsed.Seconds())
+	msg := fmt.Sprintf("%s (cluster: %s, nodes: %d, active_primary_shards: %d, active_shards: %d, relocating_shards: %d, unassigned_shards: %d) - %f second response time",
+		health.Status, health.ClusterName, health.NumberOfNodes, health.ActivePrimaryShards,
+		health.ActiveShards, health.RelocatingShards, health.UnassignedShards, elapsed.Seconds())
 
 	return checkers.NewChecker(checkSt, msg)
 }
@@ -0,0 +1,102 @@
+package checkelasticsearch
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeElasticsearch serves the JSON bodies for the paths
+func fakeElasticsearch(bodies map[string]string) (*httptest.Server, []string) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); ok && (user != "elastic" || pass != "secret") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, ok := bodies[r.URL.RequestURI()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	u, _ := url.Parse(ts.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	return ts, []string{"-H", host, "-p", port}
+}
+
+const healthYellow = `{"cluster_name":"es","status":"yellow","number_of_nodes":3,"active_primary_shards":5,"active_shards":8,"relocating_shards":0,"unassigned_shards":2}`
+
+func TestClusterHealth(t *testing.T) {
+	ts, args := fakeElasticsearch(map[string]string{"/_cluster/health": healthYellow})
+	defer ts.Close()
+
+	ckr := run(args)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Regexp(t, `^yellow \(cluster: es, nodes: 3, active_primary_shards: 5, active_shards: 8, relocating_shards: 0, unassigned_shards: 2\) - [\d.]+ second response time$`, ckr.Message)
+
+	ckr = run(append(args, "--unassigned-shards-critical", "1"))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = run(append(args, "--unassigned-shards-critical", "2"))
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	ckr = run(append(args, "--user", "elastic", "--password", "secret"))
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	ckr = run(append(args, "--user", "elastic", "--password", "wrong"))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "/_cluster/health returned 401 Unauthorized", ckr.Message)
+}
+
+const indicesJSON = `[
+{"health":"green","status":"open","index":"logs-2018.01.01","docs.count":"1500","store.size":"2097152"},
+{"health":"yellow","status":"open","index":"logs-2018.01.02","docs.count":"10","store.size":"1024"},
+{"health":null,"status":"close","index":"logs-2017.12.31","docs.count":null,"store.size":null}
+]`
+
+func TestIndex(t *testing.T) {
+	ts, args := fakeElasticsearch(map[string]string{
+		"/_cat/indices/logs-*?format=json&bytes=b":          indicesJSON,
+		"/_cat/indices/nothing-*?format=json&bytes=b":       `[]`,
+		"/_cat/indices/logs-2018.01.01?format=json&bytes=b": `[{"health":"green","status":"open","index":"logs-2018.01.01","docs.count":"1500","store.size":"2097152"}]`,
+	})
+	defer ts.Close()
+
+	ckr := run(append(args, "--index", "logs-*"))
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "logs-2018.01.01: green, 1500 docs, 2.00 MB; logs-2018.01.02: yellow, 10 docs, 1.00 KB; logs-2017.12.31: closed", ckr.Message)
+
+	ckr = run(append(args, "--index", "logs-2018.01.01"))
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "logs-2018.01.01: green, 1500 docs, 2.00 MB", ckr.Message)
+
+	ckr = run(append(args, "--index", "logs-*", "--doc-count-critical", "100"))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = run(append(args, "--index", "logs-2018.01.01", "--doc-count-warning", "2000", "--doc-count-critical", "100"))
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	ckr = run(append(args, "--index", "logs-2018.01.01", "--index-size-warning", "1M", "--index-size-critical", "2GB"))
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	ckr = run(append(args, "--index", "logs-2018.01.01", "--index-size-critical", "2M"))
+	assert.Equal(t, checkers.OK, ckr.Status)
+
+	ckr = run(append(args, "--index", "logs-2018.01.01", "--index-size-critical", "foo"))
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status)
+	assert.Equal(t, "foo is invalid", ckr.Message)
+
+	ckr = run(append(args, "--index", "nothing-*"))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "no indices found", ckr.Message)
+
+	ckr = run(append(args, "--index", "missing"))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+}
@@ -0,0 +1,111 @@
+package checkelasticsearch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mackerelio/checkers"
+	"github.com/mackerelio/go-check-plugins/check-file-size/lib"
+)
+
+type indexStat struct {
+	Health    string `json:"health"`
+	Status    string `json:"status"`
+	Index     string `json:"index"`
+	DocsCount string `json:"docs.count"`
+	StoreSize string `json:"store.size"`
+}
+
+type indexThresholds struct {
+	docCountWarn *int64
+	docCountCrit *int64
+	sizeWarn     *float64
+	sizeCrit     *float64
+}
+
+// evalIndices checks each index and returns the worst status among them
+func evalIndices(indices []indexStat, th indexThresholds) *checkers.Checker {
+	if len(indices) == 0 {
+		return checkers.Critical("no indices found")
+	}
+
+	checkSt := checkers.OK
+	raise := func(st checkers.Status) {
+		if st > checkSt {
+			checkSt = st
+		}
+	}
+
+	var msgs []string
+	for _, idx := range indices {
+		// closed indices have no health, docs nor store
+		if idx.Status == "close" {
+			msgs = append(msgs, fmt.Sprintf("%s: closed", idx.Index))
+			continue
+		}
+
+		switch idx.Health {
+		case "green":
+		case "yellow":
+			raise(checkers.WARNING)
+		case "red":
+			raise(checkers.CRITICAL)
+		default:
+			raise(checkers.UNKNOWN)
+		}
+
+		docs, err := strconv.ParseInt(idx.DocsCount, 10, 64)
+		if err != nil {
+			return checkers.Unknown(fmt.Sprintf("couldn't get docs.count of %s", idx.Index))
+		}
+		size, err := strconv.ParseFloat(idx.StoreSize, 64)
+		if err != nil {
+			return checkers.Unknown(fmt.Sprintf("couldn't get store.size of %s", idx.Index))
+		}
+
+		if th.docCountCrit != nil && docs < *th.docCountCrit {
+			raise(checkers.CRITICAL)
+		} else if th.docCountWarn != nil && docs < *th.docCountWarn {
+			raise(checkers.WARNING)
+		}
+		if th.sizeCrit != nil && size > *th.sizeCrit {
+			raise(checkers.CRITICAL)
+		} else if th.sizeWarn != nil && size > *th.sizeWarn {
+			raise(checkers.WARNING)
+		}
+
+		msgs = append(msgs, fmt.Sprintf("%s: %s, %d docs, %s", idx.Index, idx.Health, docs, checkfilesize.HumanizeBytes(size)))
+	}
+	return checkers.NewChecker(checkSt, strings.Join(msgs, "; "))
+}
+
+func checkIndex(opts *elasticsearchOpts) *checkers.Checker {
+	th := indexThresholds{
+		docCountWarn: opts.DocCountWarn,
+		docCountCrit: opts.DocCountCrit,
+	}
+	for _, v := range []struct {
+		value string
+		dest  **float64
+	}{
+		{opts.IndexSizeWarn, &th.sizeWarn},
+		{opts.IndexSizeCrit, &th.sizeCrit},
+	} {
+		if v.value == "" {
+			continue
+		}
+		size, err := checkfilesize.SizeValue(v.value)
+		if err != nil {
+			return checkers.Unknown(err.Error())
+		}
+		*v.dest = &size
+	}
+
+	var indices []indexStat
+	// bytes=b makes store.size a plain number of bytes
+	if err := getJSON(opts, "/_cat/indices/"+opts.Index+"?format=json&bytes=b", &indices); err != nil {
+		return checkers.Critical(err.Error())
+	}
+	return evalIndices(indices, th)
+}
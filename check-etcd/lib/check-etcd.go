@@ -0,0 +1,182 @@
+package checketcd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+	"github.com/mackerelio/go-check-plugins/check-file-size/lib"
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type etcdOpts struct {
+	Endpoints      string `long:"endpoints" value-name:"URL[,URL...]" default:"http://127.0.0.1:2379" description:"Comma-separated endpoints of the members"`
+	Cert           string `long:"cert" value-name:"FILE" description:"Client certificate (PEM)"`
+	Key            string `long:"key" value-name:"FILE" description:"Client private key (PEM)"`
+	CACert         string `long:"cacert" value-name:"FILE" description:"CA certificates (PEM) to verify the members"`
+	Timeout        int    `long:"timeout" value-name:"SECONDS" default:"10" description:"Timeout in seconds"`
+	DBSizeWarning  *int64 `long:"db-size-warning" value-name:"BYTES" description:"warning if the database size of any member is over"`
+	DBSizeCritical *int64 `long:"db-size-critical" value-name:"BYTES" description:"critical if the database size of any member is over"`
+}
+
+// Do the plugin
+func Do() {
+	ckr := run(os.Args[1:])
+	ckr.Name = "etcd"
+	ckr.Exit()
+}
+
+type endpointStatus struct {
+	endpoint string
+	resp     *clientv3.StatusResponse
+	err      error
+}
+
+type clusterStatus struct {
+	clusterID uint64
+	members   []*pb.Member
+	// the error of MemberList, which is linearizable and fails without quorum
+	memberErr error
+	endpoints []endpointStatus
+}
+
+func evalCluster(opts *etcdOpts, cs *clusterStatus) *checkers.Checker {
+	checkSt := checkers.OK
+	raise := func(st checkers.Status) {
+		if st > checkSt {
+			checkSt = st
+		}
+	}
+	var problems, details []string
+
+	clusterID := cs.clusterID
+	var leader uint64
+	var maxDBSize int64
+	reachable := 0
+	for _, ep := range cs.endpoints {
+		if ep.err != nil {
+			details = append(details, fmt.Sprintf("%s: %s", ep.endpoint, ep.err))
+			continue
+		}
+		reachable++
+		if clusterID == 0 {
+			clusterID = ep.resp.Header.ClusterId
+		}
+		if leader == 0 {
+			leader = ep.resp.Leader
+		}
+		if ep.resp.DbSize > maxDBSize {
+			maxDBSize = ep.resp.DbSize
+		}
+		details = append(details, fmt.Sprintf("%s: member %x, version %s, db size %s",
+			ep.endpoint, ep.resp.Header.MemberId, ep.resp.Version, checkfilesize.HumanizeBytes(float64(ep.resp.DbSize))))
+	}
+	if reachable == 0 {
+		return checkers.Critical("no endpoints are reachable\n" + strings.Join(details, "\n"))
+	}
+	if reachable < len(cs.endpoints) {
+		raise(checkers.WARNING)
+		problems = append(problems, fmt.Sprintf("%d of %d endpoints are unreachable", len(cs.endpoints)-reachable, len(cs.endpoints)))
+	}
+	if leader == 0 {
+		raise(checkers.CRITICAL)
+		problems = append(problems, "no leader")
+	}
+
+	quorum := "OK"
+	learners := 0
+	for _, m := range cs.members {
+		if m.IsLearner {
+			learners++
+		}
+	}
+	if cs.memberErr != nil {
+		raise(checkers.CRITICAL)
+		quorum = "lost"
+		problems = append(problems, fmt.Sprintf("couldn't list the members: %s", cs.memberErr))
+	} else if voters := len(cs.members) - learners; voters <= len(cs.members)/2 {
+		raise(checkers.WARNING)
+		problems = append(problems, fmt.Sprintf("%d of %d members are learners", learners, len(cs.members)))
+	}
+
+	if opts.DBSizeCritical != nil && maxDBSize > *opts.DBSizeCritical {
+		raise(checkers.CRITICAL)
+		problems = append(problems, fmt.Sprintf("db size %s > %s", checkfilesize.HumanizeBytes(float64(maxDBSize)), checkfilesize.HumanizeBytes(float64(*opts.DBSizeCritical))))
+	} else if opts.DBSizeWarning != nil && maxDBSize > *opts.DBSizeWarning {
+		raise(checkers.WARNING)
+		problems = append(problems, fmt.Sprintf("db size %s > %s", checkfilesize.HumanizeBytes(float64(maxDBSize)), checkfilesize.HumanizeBytes(float64(*opts.DBSizeWarning))))
+	}
+
+	msg := fmt.Sprintf("cluster %x: leader %x, %d members (%d learners), quorum %s", clusterID, leader, len(cs.members), learners, quorum)
+	if len(problems) > 0 {
+		msg = strings.Join(problems, ", ") + "\n" + msg
+	}
+	return checkers.NewChecker(checkSt, msg+"\n"+strings.Join(details, "\n"))
+}
+
+func newClient(opts *etcdOpts, endpoints []string) (*clientv3.Client, error) {
+	var tlsConfig *tls.Config
+	if opts.Cert != "" || opts.Key != "" || opts.CACert != "" {
+		tlsInfo := transport.TLSInfo{
+			CertFile:      opts.Cert,
+			KeyFile:       opts.Key,
+			TrustedCAFile: opts.CACert,
+		}
+		var err error
+		if tlsConfig, err = tlsInfo.ClientConfig(); err != nil {
+			return nil, err
+		}
+	}
+	return clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: time.Duration(opts.Timeout) * time.Second,
+		TLS:         tlsConfig,
+	})
+}
+
+func run(args []string) *checkers.Checker {
+	opts := etcdOpts{}
+	_, err := flags.ParseArgs(&opts, args)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	var endpoints []string
+	for _, ep := range strings.Split(opts.Endpoints, ",") {
+		if ep = strings.TrimSpace(ep); ep != "" {
+			endpoints = append(endpoints, ep)
+		}
+	}
+	if len(endpoints) == 0 {
+		return checkers.Unknown("no endpoints are given")
+	}
+	client, err := newClient(&opts, endpoints)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(opts.Timeout)*time.Second)
+	defer cancel()
+
+	cs := &clusterStatus{}
+	for _, ep := range endpoints {
+		resp, err := client.Status(ctx, ep)
+		cs.endpoints = append(cs.endpoints, endpointStatus{endpoint: ep, resp: resp, err: err})
+	}
+	members, err := client.MemberList(ctx)
+	if err != nil {
+		cs.memberErr = err
+	} else {
+		cs.clusterID = members.Header.ClusterId
+		cs.members = members.Members
+	}
+	return evalCluster(&opts, cs)
+}
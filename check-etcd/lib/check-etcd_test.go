@@ -0,0 +1,70 @@
+package checketcd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func status(memberID, leader uint64, dbSize int64) *clientv3.StatusResponse {
+	return &clientv3.StatusResponse{
+		Header:  &pb.ResponseHeader{ClusterId: 0xcafe, MemberId: memberID},
+		Version: "3.5.17",
+		DbSize:  dbSize,
+		Leader:  leader,
+	}
+}
+
+func TestEvalCluster(t *testing.T) {
+	members := []*pb.Member{{ID: 0xa1, Name: "etcd1"}, {ID: 0xb2, Name: "etcd2"}, {ID: 0xc3, Name: "etcd3"}}
+	cs := &clusterStatus{
+		clusterID: 0xcafe,
+		members:   members,
+		endpoints: []endpointStatus{
+			{endpoint: "https://etcd1:2379", resp: status(0xa1, 0xb2, 20*1024*1024)},
+			{endpoint: "https://etcd2:2379", resp: status(0xb2, 0xb2, 30*1024*1024)},
+		},
+	}
+	ckr := evalCluster(&etcdOpts{}, cs)
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "cluster cafe: leader b2, 3 members (0 learners), quorum OK\nhttps://etcd1:2379: member a1, version 3.5.17, db size 20.00 MB\nhttps://etcd2:2379: member b2, version 3.5.17, db size 30.00 MB", ckr.Message)
+
+	warn, crit := int64(25*1024*1024), int64(100*1024*1024)
+	ckr = evalCluster(&etcdOpts{DBSizeWarning: &warn, DBSizeCritical: &crit}, cs)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Regexp(t, `^db size 30.00 MB > 25.00 MB\n`, ckr.Message)
+
+	cs.endpoints[1].err = errors.New("context deadline exceeded")
+	ckr = evalCluster(&etcdOpts{}, cs)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Regexp(t, `^1 of 2 endpoints are unreachable\n`, ckr.Message)
+	assert.Regexp(t, `\nhttps://etcd2:2379: context deadline exceeded$`, ckr.Message)
+
+	cs.endpoints[0].resp.Leader = 0
+	cs.clusterID = 0
+	cs.members = nil
+	cs.memberErr = errors.New("etcdserver: request timed out")
+	ckr = evalCluster(&etcdOpts{}, cs)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Regexp(t, `^1 of 2 endpoints are unreachable, no leader, couldn't list the members: etcdserver: request timed out\ncluster cafe: leader 0, 0 members \(0 learners\), quorum lost\n`, ckr.Message)
+
+	cs.endpoints[0].err = errors.New("connection refused")
+	ckr = evalCluster(&etcdOpts{}, cs)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Regexp(t, `^no endpoints are reachable\n`, ckr.Message)
+}
+
+func TestEvalClusterLearners(t *testing.T) {
+	cs := &clusterStatus{
+		clusterID: 0xcafe,
+		members:   []*pb.Member{{ID: 0xa1}, {ID: 0xb2, IsLearner: true}},
+		endpoints: []endpointStatus{{endpoint: "http://127.0.0.1:2379", resp: status(0xa1, 0xa1, 1024)}},
+	}
+	ckr := evalCluster(&etcdOpts{}, cs)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Regexp(t, `^1 of 2 members are learners\n`, ckr.Message)
+}
@@ -3,6 +3,7 @@ package checkfileage
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/jessevdk/go-flags"
@@ -58,36 +59,80 @@ func newMonitor(warningAge, warningSize, criticalAge, criticalSize int64) *monit
 	}
 }
 
-var opts struct {
-	File          string `short:"f" long:"file" required:"true" description:"monitor file name"`
-	WarningAge    int64  `short:"w" long:"warning-age" default:"240" description:"warning if more old than"`
-	WarningSize   int64  `short:"W" long:"warning-size" description:"warning if file size less than"`
-	CriticalAge   int64  `short:"c" long:"critical-age" default:"600" description:"critical if more old than"`
-	CriticalSize  int64  `short:"C" long:"critical-size" default:"0" description:"critical if file size less than"`
-	IgnoreMissing bool   `short:"i" long:"ignore-missing" description:"skip alert if file doesn't exist"`
+type fileAgeOpts struct {
+	File           string `short:"f" long:"file" required:"true" description:"monitor file name, or glob pattern to check the oldest matching file"`
+	WarningAge     int64  `short:"w" long:"warning-age" default:"240" description:"warning if more old than"`
+	WarningSize    int64  `short:"W" long:"warning-size" description:"warning if file size less than"`
+	CriticalAge    int64  `short:"c" long:"critical-age" default:"600" description:"critical if more old than"`
+	CriticalSize   int64  `short:"C" long:"critical-size" default:"0" description:"critical if file size less than"`
+	WarningNewAge  *int64 `long:"warning-new" value-name:"SECONDS" description:"warning if the newest matching file is more new than"`
+	CriticalNewAge *int64 `long:"critical-new" value-name:"SECONDS" description:"critical if the newest matching file is more new than"`
+	IgnoreMissing  bool   `short:"i" long:"ignore-missing" description:"skip alert if file doesn't exist"`
+}
+
+// oldestAndNewest returns the files modified first and last among the files
+// matching the pattern
+func oldestAndNewest(pattern string) (oldest, newest *fileStat, err error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, file := range files {
+		stat, err := os.Stat(file)
+		if err != nil {
+			// removed after globbing
+			continue
+		}
+		fs := &fileStat{name: file, FileInfo: stat}
+		if oldest == nil || stat.ModTime().Before(oldest.ModTime()) {
+			oldest = fs
+		}
+		if newest == nil || stat.ModTime().After(newest.ModTime()) {
+			newest = fs
+		}
+	}
+	return oldest, newest, nil
+}
+
+type fileStat struct {
+	name string
+	os.FileInfo
+}
+
+func (fs *fileStat) age(now time.Time) int64 {
+	return now.Unix() - fs.ModTime().Unix()
+}
+
+func (fs *fileStat) String() string {
+	mtime := fs.ModTime()
+	return fmt.Sprintf("%s is %d seconds old (%02d:%02d:%02d) and %d bytes.", fs.name, fs.age(time.Now()), mtime.Hour(), mtime.Minute(), mtime.Second(), fs.Size())
 }
 
 func run(args []string) *checkers.Checker {
+	opts := fileAgeOpts{}
 	_, err := flags.ParseArgs(&opts, args)
 	if err != nil {
 		os.Exit(1)
 	}
 
-	stat, err := os.Stat(opts.File)
+	oldest, newest, err := oldestAndNewest(opts.File)
 	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	if oldest == nil {
 		if opts.IgnoreMissing {
 			return checkers.Ok("No such file, but ignore missing is set.")
 		}
-		return checkers.Unknown(err.Error())
+		return checkers.Critical(fmt.Sprintf("%s: no such file", opts.File))
 	}
 
 	monitor := newMonitor(opts.WarningAge, opts.WarningSize, opts.CriticalAge, opts.CriticalSize)
 
 	result := checkers.OK
 
-	mtime := stat.ModTime()
-	age := time.Now().Unix() - mtime.Unix()
-	size := stat.Size()
+	now := time.Now()
+	age := oldest.age(now)
+	size := oldest.Size()
 
 	if monitor.CheckWarning(age, size) {
 		result = checkers.WARNING
@@ -97,6 +142,17 @@ func run(args []string) *checkers.Checker {
 		result = checkers.CRITICAL
 	}
 
-	msg := fmt.Sprintf("%s is %d seconds old (%02d:%02d:%02d) and %d bytes.", opts.File, age, mtime.Hour(), mtime.Minute(), mtime.Second(), size)
+	msg := oldest.String()
+	if opts.WarningNewAge != nil || opts.CriticalNewAge != nil {
+		newAge := newest.age(now)
+		if opts.CriticalNewAge != nil && newAge < *opts.CriticalNewAge {
+			result = checkers.CRITICAL
+		} else if opts.WarningNewAge != nil && newAge < *opts.WarningNewAge && result == checkers.OK {
+			result = checkers.WARNING
+		}
+		if newest != oldest {
+			msg += " The newest " + newest.String()
+		}
+	}
 	return checkers.NewChecker(result, msg)
 }
@@ -0,0 +1,59 @@
+package checkfileage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check-file-age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	for name, age := range map[string]time.Duration{
+		"a.log": 300 * time.Second,
+		"b.log": 60 * time.Second,
+		"c.log": 10 * time.Second,
+	} {
+		file := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(file, []byte("log"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := now.Add(-age)
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ckr := run([]string{"-f", filepath.Join(dir, "c.log")})
+	assert.Equal(t, checkers.OK, ckr.Status)
+
+	ckr = run([]string{"-f", filepath.Join(dir, "*.log")})
+	assert.Equal(t, checkers.WARNING, ckr.Status, "the oldest file is checked")
+	assert.Regexp(t, `a\.log is 30\d seconds old`, ckr.Message)
+
+	ckr = run([]string{"-f", filepath.Join(dir, "*.log"), "-w", "600", "--warning-new", "30"})
+	assert.Equal(t, checkers.WARNING, ckr.Status, "the newest file is checked")
+	assert.Regexp(t, `The newest .*c\.log is 1\d seconds old`, ckr.Message)
+
+	ckr = run([]string{"-f", filepath.Join(dir, "*.log"), "-w", "600", "--warning-new", "30", "--critical-new", "20"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = run([]string{"-f", filepath.Join(dir, "*.txt")})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = run([]string{"-f", filepath.Join(dir, "*.txt"), "-i"})
+	assert.Equal(t, checkers.OK, ckr.Status)
+
+	ckr = run([]string{"-f", filepath.Join(dir, "[")})
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status)
+}
@@ -20,16 +20,22 @@ func Do() {
 	ckr.Exit()
 }
 
-var opts struct {
-	Base  string `short:"b" long:"base" required:"true" description:"base directory"`
-	Warn  string `short:"w" long:"warning" default:"1K" description:"warning if the size is over"`
-	Crit  string `short:"c" long:"critical" default:"1K" description:"critical if the size is over"`
-	Depth int    `short:"d" long:"depth" default:"1" description:"max depth of the directory from base directory"`
+type fileSizeOpts struct {
+	Base          string `short:"b" long:"base" description:"base directory"`
+	Warn          string `short:"w" long:"warning" default:"1K" description:"warning if the size is over"`
+	Crit          string `short:"c" long:"critical" default:"1K" description:"critical if the size is over"`
+	Depth         int    `short:"d" long:"depth" default:"1" description:"max depth of the directory from base directory"`
+	File          string `short:"f" long:"file" description:"check each file matching the glob pattern instead of the total size in the base directory"`
+	WarningOver   string `long:"warning-over" value-name:"N[KMGT]" description:"warning if the size of any file is over (with --file)"`
+	CriticalOver  string `long:"critical-over" value-name:"N[KMGT]" description:"critical if the size of any file is over (with --file)"`
+	WarningUnder  string `long:"warning-under" value-name:"N[KMGT]" description:"warning if the size of any file is under (with --file)"`
+	CriticalUnder string `long:"critical-under" value-name:"N[KMGT]" description:"critical if the size of any file is under (with --file)"`
 }
 
-var sizeReg = regexp.MustCompile(`^(\d+\.?\d*)(k|K|m|M|g|G|t|T)?$`)
+var sizeReg = regexp.MustCompile(`^(\d+\.?\d*)(k|K|m|M|g|G|t|T)?[bB]?$`)
 
-func sizeValue(input string) (float64, error) {
+// SizeValue parses a size like "512", "100M" or "1GB" in bytes
+func SizeValue(input string) (float64, error) {
 	var size float64
 	var err error
 
@@ -83,18 +89,120 @@ func listFiles(base string, depth int) ([]string, error) {
 	return files, err
 }
 
+// optionalSize parses the size if given
+func optionalSize(input string) (*float64, error) {
+	if input == "" {
+		return nil, nil
+	}
+	size, err := SizeValue(input)
+	if err != nil {
+		return nil, err
+	}
+	return &size, nil
+}
+
+// HumanizeBytes formats bytes in B, KB, MB, GB or TB (in 1024)
+func HumanizeBytes(n float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.2f %s", n, units[i])
+}
+
+type sizeThresholds struct {
+	warningOver   *float64
+	criticalOver  *float64
+	warningUnder  *float64
+	criticalUnder *float64
+}
+
+func (th *sizeThresholds) eval(size int64) checkers.Status {
+	s := float64(size)
+	if th.criticalOver != nil && s > *th.criticalOver ||
+		th.criticalUnder != nil && s < *th.criticalUnder {
+		return checkers.CRITICAL
+	}
+	if th.warningOver != nil && s > *th.warningOver ||
+		th.warningUnder != nil && s < *th.warningUnder {
+		return checkers.WARNING
+	}
+	return checkers.OK
+}
+
+// evalFiles checks each file and returns the worst status
+func evalFiles(th *sizeThresholds, files []string, stats []os.FileInfo) *checkers.Checker {
+	chkSt := checkers.OK
+	var sizes []string
+	for i, stat := range stats {
+		if st := th.eval(stat.Size()); st > chkSt {
+			chkSt = st
+		}
+		sizes = append(sizes, fmt.Sprintf("%s: %s", files[i], HumanizeBytes(float64(stat.Size()))))
+	}
+	return checkers.NewChecker(chkSt, strings.Join(sizes, ", "))
+}
+
+func checkFiles(opts *fileSizeOpts) *checkers.Checker {
+	var th sizeThresholds
+	for _, t := range []struct {
+		input string
+		size  **float64
+	}{
+		{opts.WarningOver, &th.warningOver},
+		{opts.CriticalOver, &th.criticalOver},
+		{opts.WarningUnder, &th.warningUnder},
+		{opts.CriticalUnder, &th.criticalUnder},
+	} {
+		size, err := optionalSize(t.input)
+		if err != nil {
+			return checkers.Unknown(err.Error())
+		}
+		*t.size = size
+	}
+
+	matches, err := filepath.Glob(opts.File)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	var files []string
+	var stats []os.FileInfo
+	for _, file := range matches {
+		stat, err := os.Stat(file)
+		if err != nil || stat.IsDir() {
+			continue
+		}
+		files = append(files, file)
+		stats = append(stats, stat)
+	}
+	if len(files) == 0 {
+		return checkers.Critical(fmt.Sprintf("%s: no such file", opts.File))
+	}
+	return evalFiles(&th, files, stats)
+}
+
 func run(args []string) *checkers.Checker {
+	opts := fileSizeOpts{}
 	_, err := flags.ParseArgs(&opts, args)
 	if err != nil {
 		os.Exit(1)
 	}
 
-	ws, err := sizeValue(opts.Warn)
+	if opts.File != "" {
+		return checkFiles(&opts)
+	}
+	if opts.Base == "" {
+		return checkers.Unknown("either --base or --file is required")
+	}
+
+	ws, err := SizeValue(opts.Warn)
 	if err != nil {
 		return checkers.NewChecker(checkers.UNKNOWN, err.Error())
 	}
 
-	cs, err := sizeValue(opts.Crit)
+	cs, err := SizeValue(opts.Crit)
 	if err != nil {
 		return checkers.NewChecker(checkers.UNKNOWN, err.Error())
 	}
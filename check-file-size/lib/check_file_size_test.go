@@ -5,6 +5,8 @@ import (
 	"reflect"
 	"sort"
 	"testing"
+
+	"github.com/mackerelio/checkers"
 )
 
 func TestSizeValue(t *testing.T) {
@@ -21,9 +23,11 @@ func TestSizeValue(t *testing.T) {
 		"1.2t": 1.2 * 1024 * 1024 * 1024 * 1024,
 		"1.2T": 1.2 * 1024 * 1024 * 1024 * 1024,
 		"1T":   1.0 * 1024 * 1024 * 1024 * 1024,
+		"1GB":  1.0 * 1024 * 1024 * 1024,
+		"10b":  10,
 	}
 	for input, expect := range testData {
-		size, err := sizeValue(input)
+		size, err := SizeValue(input)
 		if err != nil {
 			t.Error(err)
 		}
@@ -42,7 +46,7 @@ func TestSizeValueWithInvalidInput(t *testing.T) {
 		"aaaaa",
 	}
 	for _, input := range testData {
-		size, err := sizeValue(input)
+		size, err := SizeValue(input)
 		if err == nil {
 			t.Error("Error should occur")
 		}
@@ -52,6 +56,23 @@ func TestSizeValueWithInvalidInput(t *testing.T) {
 	}
 }
 
+func TestHumanizeBytes(t *testing.T) {
+	// map[input] = expected string
+	var testData = map[float64]string{
+		0:                                "0.00 B",
+		1023:                             "1023.00 B",
+		1536:                             "1.50 KB",
+		2 * 1024 * 1024:                  "2.00 MB",
+		3 * 1024 * 1024 * 1024:           "3.00 GB",
+		1024 * 1024 * 1024 * 1024 * 1024: "1024.00 TB",
+	}
+	for input, expect := range testData {
+		if actual := HumanizeBytes(input); actual != expect {
+			t.Errorf("humanized size doesn't match: input = %f, expect = %s, actual = %s", input, expect, actual)
+		}
+	}
+}
+
 func TestListFiles(t *testing.T) {
 	// map[depth] = expected files
 	var testData = map[int][]string{
@@ -84,3 +105,25 @@ func TestListFilesWithInvalidDir(t *testing.T) {
 		t.Error("files should be empty")
 	}
 }
+
+func TestRunWithFile(t *testing.T) {
+	testData := []struct {
+		args   []string
+		status checkers.Status
+		msg    string
+	}{
+		{[]string{"-f", "test_dir/file1", "--warning-over", "1K"}, checkers.OK, "test_dir/file1: 0.00 B"},
+		{[]string{"-f", "test_dir/file1", "--warning-under", "1", "--critical-under", "1B"}, checkers.CRITICAL, "test_dir/file1: 0.00 B"},
+		{[]string{"-f", "test_dir/*/file*", "--warning-under", "1"}, checkers.WARNING, "test_dir/depth1/file2: 0.00 B"},
+		{[]string{"-f", "test_dir/*"}, checkers.OK, "test_dir/file1: 0.00 B"},
+		{[]string{"-f", "test_dir/no_such_file"}, checkers.CRITICAL, "test_dir/no_such_file: no such file"},
+		{[]string{"-f", "test_dir/file1", "--warning-over", "1X"}, checkers.UNKNOWN, "1X is invalid"},
+		{[]string{}, checkers.UNKNOWN, "either --base or --file is required"},
+	}
+	for _, tc := range testData {
+		ckr := run(tc.args)
+		if ckr.Status != tc.status || ckr.Message != tc.msg {
+			t.Errorf("run(%v) = %s %q, expected %s %q", tc.args, ckr.Status, ckr.Message, tc.status, tc.msg)
+		}
+	}
+}
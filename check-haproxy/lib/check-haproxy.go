@@ -0,0 +1,201 @@
+package checkhaproxy
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+type haproxyOpts struct {
+	Socket   string  `short:"s" long:"socket" value-name:"PATH" description:"Path to the stats socket"`
+	URL      string  `short:"u" long:"url" description:"URL of the stats page in CSV, e.g. http://localhost/haproxy?stats;csv"`
+	Username string  `long:"username" description:"Username for the basic authentication of --url"`
+	Password string  `long:"password" description:"Password for the basic authentication of --url"`
+	Backend  string  `short:"b" long:"backend" value-name:"REGEXP" description:"Check only the backends whose names match"`
+	Server   string  `long:"server" value-name:"REGEXP" description:"Check only the servers whose names match"`
+	Warning  float64 `short:"w" long:"warning" value-name:"PERCENT" default:"0" description:"warning if the DOWN servers in any backend are over (%)"`
+	Critical float64 `short:"c" long:"critical" value-name:"PERCENT" default:"50" description:"critical if the DOWN servers in any backend are over (%)"`
+	Timeout  int     `long:"timeout" value-name:"SECONDS" default:"10" description:"Timeout in seconds"`
+}
+
+// Do the plugin
+func Do() {
+	ckr := run(os.Args[1:])
+	ckr.Name = "HAProxy"
+	ckr.Exit()
+}
+
+type serverStat struct {
+	backend string
+	server  string
+	status  string
+}
+
+func (s *serverStat) isDown() bool {
+	// "DOWN", or "DOWN 1/2" while going up
+	return strings.HasPrefix(s.status, "DOWN")
+}
+
+// parseStats returns the servers in the CSV stats, excluding the FRONTEND
+// and BACKEND rows
+func parseStats(r io.Reader) ([]serverStat, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.TrimPrefix(name, "# ")] = i
+	}
+	for _, name := range []string{"pxname", "svname", "status"} {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("no %s field in the stats", name)
+		}
+	}
+
+	var stats []serverStat
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) <= index["status"] {
+			continue
+		}
+		svname := record[index["svname"]]
+		if svname == "FRONTEND" || svname == "BACKEND" {
+			continue
+		}
+		stats = append(stats, serverStat{
+			backend: record[index["pxname"]],
+			server:  svname,
+			status:  record[index["status"]],
+		})
+	}
+	return stats, nil
+}
+
+func evalStats(opts *haproxyOpts, stats []serverStat, backendReg, serverReg *regexp.Regexp) *checkers.Checker {
+	servers := make(map[string]int)
+	downs := make(map[string][]string)
+	for _, s := range stats {
+		if backendReg != nil && !backendReg.MatchString(s.backend) ||
+			serverReg != nil && !serverReg.MatchString(s.server) {
+			continue
+		}
+		servers[s.backend]++
+		if s.isDown() {
+			downs[s.backend] = append(downs[s.backend], s.server)
+		}
+	}
+	if len(servers) == 0 {
+		return checkers.Unknown("no servers are found")
+	}
+
+	var backends []string
+	for b := range servers {
+		backends = append(backends, b)
+	}
+	sort.Strings(backends)
+
+	checkSt := checkers.OK
+	total, down := 0, 0
+	var details []string
+	for _, b := range backends {
+		total += servers[b]
+		down += len(downs[b])
+		if len(downs[b]) == 0 {
+			continue
+		}
+		pct := float64(len(downs[b])) / float64(servers[b]) * 100
+		if pct > opts.Critical {
+			checkSt = checkers.CRITICAL
+		} else if pct > opts.Warning && checkSt == checkers.OK {
+			checkSt = checkers.WARNING
+		}
+		details = append(details, fmt.Sprintf("%s: %.0f%% DOWN (%s)", b, pct, strings.Join(downs[b], ", ")))
+	}
+	msg := fmt.Sprintf("%d of %d servers in %d backends are DOWN", down, total, len(backends))
+	if len(details) > 0 {
+		msg += "\n" + strings.Join(details, "\n")
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func readStats(opts *haproxyOpts) ([]serverStat, error) {
+	timeout := time.Duration(opts.Timeout) * time.Second
+	if opts.Socket != "" {
+		conn, err := net.DialTimeout("unix", opts.Socket, timeout)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(timeout))
+		if _, err := io.WriteString(conn, "show stat\n"); err != nil {
+			return nil, err
+		}
+		return parseStats(conn)
+	}
+
+	req, err := http.NewRequest("GET", opts.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Username != "" {
+		req.SetBasicAuth(opts.Username, opts.Password)
+	}
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", opts.URL, resp.Status)
+	}
+	return parseStats(resp.Body)
+}
+
+func run(args []string) *checkers.Checker {
+	opts := haproxyOpts{}
+	_, err := flags.ParseArgs(&opts, args)
+	if err != nil {
+		os.Exit(1)
+	}
+	if (opts.Socket == "") == (opts.URL == "") {
+		return checkers.Unknown("either --socket or --url is required")
+	}
+
+	var backendReg, serverReg *regexp.Regexp
+	if opts.Backend != "" {
+		if backendReg, err = regexp.Compile(opts.Backend); err != nil {
+			return checkers.Unknown(err.Error())
+		}
+	}
+	if opts.Server != "" {
+		if serverReg, err = regexp.Compile(opts.Server); err != nil {
+			return checkers.Unknown(err.Error())
+		}
+	}
+
+	stats, err := readStats(&opts)
+	if err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't read the stats: %s", err))
+	}
+	return evalStats(&opts, stats, backendReg, serverReg)
+}
@@ -0,0 +1,77 @@
+package checkhaproxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+const statsCSV = `# pxname,svname,qcur,qmax,scur,smax,slim,stot,bin,bout,dreq,dresp,ereq,econ,eresp,wretr,wredis,status,weight,act,bck,
+http-in,FRONTEND,,,1,2,2000,10,0,0,0,0,0,,,,,OPEN,,,,
+web,web1,0,0,0,1,,5,0,0,,0,,0,0,0,0,UP,1,1,0,
+web,web2,0,0,0,1,,5,0,0,,0,,0,0,0,0,DOWN,1,1,0,
+web,web3,0,0,0,1,,5,0,0,,0,,0,0,0,0,UP 1/3,1,1,0,
+web,web4,0,0,0,1,,5,0,0,,0,,0,0,0,0,DOWN 1/2,1,1,0,
+web,BACKEND,0,0,0,1,200,10,0,0,0,0,,0,0,0,0,UP,2,2,0,
+api,api1,0,0,0,1,,5,0,0,,0,,0,0,0,0,UP,1,1,0,
+api,api2,0,0,0,1,,5,0,0,,0,,0,0,0,0,MAINT,1,1,0,
+api,BACKEND,0,0,0,1,200,10,0,0,0,0,,0,0,0,0,UP,1,1,0,
+`
+
+func TestParseStats(t *testing.T) {
+	stats, err := parseStats(strings.NewReader(statsCSV))
+	assert.Nil(t, err)
+	assert.Len(t, stats, 6)
+	assert.Equal(t, serverStat{backend: "web", server: "web4", status: "DOWN 1/2"}, stats[3])
+	assert.True(t, stats[3].isDown())
+	assert.False(t, stats[2].isDown())
+
+	_, err = parseStats(strings.NewReader("<html></html>\n"))
+	assert.NotNil(t, err)
+}
+
+func TestEvalStats(t *testing.T) {
+	stats, _ := parseStats(strings.NewReader(statsCSV))
+	ckr := evalStats(&haproxyOpts{Warning: 0, Critical: 50}, stats, nil, nil)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "2 of 6 servers in 2 backends are DOWN\nweb: 50% DOWN (web2, web4)", ckr.Message)
+
+	ckr = evalStats(&haproxyOpts{Warning: 0, Critical: 40}, stats, nil, nil)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = evalStats(&haproxyOpts{Warning: 0, Critical: 50}, stats, regexp.MustCompile(`^api$`), nil)
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "0 of 2 servers in 1 backends are DOWN", ckr.Message)
+
+	ckr = evalStats(&haproxyOpts{Warning: 0, Critical: 40}, stats, nil, regexp.MustCompile(`[13]$`))
+	assert.Equal(t, checkers.OK, ckr.Status)
+
+	ckr = evalStats(&haproxyOpts{}, stats, regexp.MustCompile(`^db$`), nil)
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status)
+}
+
+func TestRun(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, statsCSV)
+	}))
+	defer ts.Close()
+
+	ckr := run([]string{"--url", ts.URL + "/haproxy?stats;csv", "--username", "admin", "--password", "secret"})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	ckr = run([]string{"--url", ts.URL + "/haproxy?stats;csv"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = run([]string{})
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status)
+}
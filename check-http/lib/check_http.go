@@ -5,7 +5,11 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -15,6 +19,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jessevdk/go-flags"
@@ -23,15 +28,38 @@ import (
 
 // XXX more options
 type checkHTTPOpts struct {
-	URL                string   `short:"u" long:"url" required:"true" description:"A URL to connect to"`
+	URL                string   `short:"u" long:"url" description:"A URL to connect to"`
+	URLFile            string   `long:"url-file" value-name:"PATH" description:"A file listing URLs to connect to, one per line"`
+	Concurrency        int      `long:"concurrency" default:"5" description:"Number of URLs in --url-file checked at once"`
 	Statuses           []string `short:"s" long:"status" description:"mapping of HTTP status"`
 	NoCheckCertificate bool     `long:"no-check-certificate" description:"Do not check certificate"`
+	CACert             string   `long:"ca-cert" value-name:"PATH" description:"CA certificates (PEM) to verify the server"`
+	ClientCert         string   `long:"client-cert" value-name:"PATH" description:"Client certificate (PEM), used with --client-key"`
+	ClientKey          string   `long:"client-key" value-name:"PATH" description:"Client private key (PEM), used with --client-cert"`
 	SourceIP           string   `short:"i" long:"source-ip" description:"source IP address"`
-	Headers            []string `short:"H" description:"HTTP request headers"`
+	Headers            []string `short:"H" long:"header" value-name:"KEY: VALUE" description:"HTTP request headers (may be repeated)"`
 	Regexp             string   `short:"p" long:"pattern" description:"Expected pattern in the content"`
+	HeaderMatches      []string `long:"header-match" value-name:"KEY: REGEXP" description:"Expected pattern of the response header (may be repeated)"`
+	InvertPattern      bool     `long:"invert-pattern" description:"Make --pattern match a failure instead"`
+	MaxBodyBytes       int64    `long:"max-body-bytes" default:"1048576" description:"Maximum bytes of the content to read"`
 	MaxRedirects       int      `long:"max-redirects" description:"Maximum number of redirects followed" default:"10"`
+	NoFollowRedirects  bool     `long:"no-follow-redirects" description:"Do not follow redirects, same as --max-redirects=0"`
 	ConnectTos         []string `long:"connect-to" value-name:"HOST1:PORT1:HOST2:PORT2" description:"Request to HOST2:PORT2 instead of HOST1:PORT1"`
 	Proxy              string   `short:"x" long:"proxy" value-name:"[PROTOCOL://][USER:PASS@]HOST[:PORT]" description:"Use the specified proxy. PROTOCOL's default is http, and PORT's default is 1080."`
+	Method             string   `long:"method" default:"GET" description:"HTTP request method"`
+	Body               string   `short:"d" long:"body" description:"HTTP request body (quote it properly for the shell)"`
+	ContentType        string   `long:"content-type" default:"application/json" description:"Content-Type of the request body, used with --body"`
+	Username           string   `long:"username" description:"Username for the basic authentication"`
+	Password           string   `long:"password" description:"Password for the basic authentication"`
+	Warning            float64  `short:"w" long:"warning" value-name:"MSEC" description:"Warning threshold of the response time (ms)"`
+	Critical           float64  `short:"c" long:"critical" value-name:"MSEC" description:"Critical threshold of the response time (ms)"`
+	WarningSize        int64    `long:"warning-size" value-name:"BYTES" description:"Warning if the content is larger than this size"`
+	CriticalSize       int64    `long:"critical-size" value-name:"BYTES" description:"Critical if the content is larger than this size"`
+	MinSize            int64    `long:"min-size" value-name:"BYTES" description:"Warning if the content is smaller than this size"`
+	JSONKey            string   `short:"j" long:"json-key" value-name:"KEY.PATH" description:"Dot-separated path to a number in the JSON content"`
+	JSONWarning        *float64 `long:"json-warning" value-name:"N" description:"Warning if the value of --json-key is larger than this"`
+	JSONCritical       *float64 `long:"json-critical" value-name:"N" description:"Critical if the value of --json-key is larger than this"`
+	Timeout            int      `short:"t" long:"timeout" value-name:"SEC" default:"10" description:"Timeout of the whole request (seconds)"`
 }
 
 // Do the plugin
@@ -140,6 +168,12 @@ func parseStatusRanges(opts *checkHTTPOpts) ([]statusRange, error) {
 }
 
 func parseHeader(opts *checkHTTPOpts) (http.Header, error) {
+	for _, h := range opts.Headers {
+		// same as curl -H, the key must not be empty
+		if i := strings.Index(h, ":"); i <= 0 || strings.TrimSpace(h[:i]) == "" {
+			return nil, fmt.Errorf("Invalid header: %s", h)
+		}
+	}
 	reader := bufio.NewReader(strings.NewReader(strings.Join(opts.Headers, "\r\n") + "\r\n\r\n"))
 	tp := textproto.NewReader(reader)
 	mimeheader, err := tp.ReadMIMEHeader()
@@ -149,6 +183,39 @@ func parseHeader(opts *checkHTTPOpts) (http.Header, error) {
 	return http.Header(mimeheader), nil
 }
 
+type headerMatcher struct {
+	key string
+	re  *regexp.Regexp
+}
+
+func (m headerMatcher) match(values []string) bool {
+	for _, v := range values {
+		if m.re.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseHeaderMatches(opts *checkHTTPOpts) ([]headerMatcher, error) {
+	matchers := make([]headerMatcher, len(opts.HeaderMatches))
+	for i, h := range opts.HeaderMatches {
+		kv := strings.SplitN(h, ":", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("Invalid --header-match: %s", h)
+		}
+		re, err := regexp.Compile(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid --header-match: %s (%s)", h, err)
+		}
+		matchers[i] = headerMatcher{
+			key: textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(kv[0])),
+			re:  re,
+		}
+	}
+	return matchers, nil
+}
+
 var connectToRegexp = regexp.MustCompile(`^(\[.+\]|[^\[\]]+)?:(\d*):(\[.+\]|[^\[\]]+)?:(\d+)?$`)
 
 func parseConnectTo(opts *checkHTTPOpts) ([]resolveMapping, error) {
@@ -196,6 +263,73 @@ func parseProxy(opts *checkHTTPOpts) (*url.URL, error) {
 	return u, nil
 }
 
+func newTLSConfig(opts *checkHTTPOpts) (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: opts.NoCheckCertificate,
+	}
+	if opts.CACert != "" {
+		pem, err := ioutil.ReadFile(opts.CACert)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("failed to parse CA certificates: %s", opts.CACert)
+		}
+		config.RootCAs = pool
+	}
+	if (opts.ClientCert == "") != (opts.ClientKey == "") {
+		return nil, fmt.Errorf("Both --client-cert and --client-key are required for the client certificate")
+	}
+	if opts.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+var errJSONKeyNotFound = errors.New("JSON key not found")
+
+// lookupJSONNumber walks the dot-separated key path, e.g. "data.queue.depth",
+// in the JSON content. A number in a path is used as an index of an array.
+func lookupJSONNumber(body []byte, path string) (float64, error) {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return 0, fmt.Errorf("failed to parse JSON: %s", err)
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = node[key]; !ok {
+				return 0, errJSONKeyNotFound
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return 0, errJSONKeyNotFound
+			}
+			v = node[i]
+		default:
+			return 0, errJSONKeyNotFound
+		}
+	}
+	n, ok := v.(float64)
+	if !ok {
+		return 0, fmt.Errorf("JSON value of %s is not a number: %v", path, v)
+	}
+	return n, nil
+}
+
+// exceedsResponseTime reports whether elapsed is over the threshold in
+// milliseconds. 0 means no threshold.
+func exceedsResponseTime(elapsed time.Duration, msec float64) bool {
+	return msec > 0 && elapsed > time.Duration(msec*float64(time.Millisecond))
+}
+
 // Run do external monitoring via HTTP
 func Run(args []string) *checkers.Checker {
 	opts := checkHTTPOpts{}
@@ -204,16 +338,107 @@ func Run(args []string) *checkers.Checker {
 		os.Exit(1)
 	}
 
+	if opts.MaxBodyBytes <= 0 {
+		return checkers.Unknown("--max-body-bytes must be greater than 0")
+	}
+
+	if opts.URLFile != "" {
+		return checkURLFile(opts)
+	}
+	if opts.URL == "" {
+		return checkers.Unknown("Either -u/--url or --url-file is required")
+	}
+	return checkURL(opts)
+}
+
+// checkURLFile checks each URL listed in opts.URLFile concurrently, and
+// returns the worst status of them
+func checkURLFile(opts checkHTTPOpts) *checkers.Checker {
+	urls, err := readURLFile(opts.URLFile)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	if len(urls) == 0 {
+		return checkers.Unknown(fmt.Sprintf("No URLs found in %s", opts.URLFile))
+	}
+	if opts.Concurrency < 1 {
+		return checkers.Unknown("--concurrency should be greater than 0")
+	}
+
+	results := make([]*checkers.Checker, len(urls))
+	sem := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			o := opts
+			o.URL = u
+			results[i] = checkURL(o)
+		}(i, u)
+	}
+	wg.Wait()
+
+	checkSt := checkers.OK
+	msgs := make([]string, len(urls))
+	for i, r := range results {
+		if severity(r.Status) > severity(checkSt) {
+			checkSt = r.Status
+		}
+		msgs[i] = fmt.Sprintf("%s %s: %s", r.Status, urls[i], r.Message)
+	}
+	return checkers.NewChecker(checkSt, strings.Join(msgs, "\n"))
+}
+
+// readURLFile reads URLs one per line, skipping empty lines and # comments
+func readURLFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var urls []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	return urls, scanner.Err()
+}
+
+// severity orders statuses so that the worst one wins: CRITICAL > UNKNOWN > WARNING > OK
+func severity(st checkers.Status) int {
+	switch st {
+	case checkers.OK:
+		return 0
+	case checkers.WARNING:
+		return 1
+	case checkers.UNKNOWN:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func checkURL(opts checkHTTPOpts) *checkers.Checker {
 	statusRanges, err := parseStatusRanges(&opts)
 	if err != nil {
 		return checkers.Unknown(err.Error())
 	}
 
+	tlsConfig, err := newTLSConfig(&opts)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
 	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: opts.NoCheckCertificate,
-		},
-		Proxy: http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+		Proxy:           http.ProxyFromEnvironment,
 	}
 	// same as http.Transport's default dialer
 	dialer := &net.Dialer{
@@ -244,15 +469,30 @@ func Run(args []string) *checkers.Checker {
 		}
 		tr.DialContext = newReplacableDial(dialer, resolves)
 	}
-	client := &http.Client{Transport: tr}
+	client := &http.Client{
+		Transport: tr,
+		Timeout:   time.Duration(opts.Timeout) * time.Second,
+	}
 	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-		if len(via) > opts.MaxRedirects {
+		if opts.NoFollowRedirects || opts.MaxRedirects <= 0 {
 			return http.ErrUseLastResponse
 		}
+		// a redirect loop ends up here, and is reported as CRITICAL
+		if len(via) > opts.MaxRedirects {
+			return fmt.Errorf("stopped after %d redirects", opts.MaxRedirects)
+		}
 		return nil
 	}
 
-	req, err := http.NewRequest(http.MethodGet, opts.URL, nil)
+	method := strings.ToUpper(opts.Method)
+	var reqBody io.Reader
+	if opts.Body != "" {
+		if method == http.MethodGet {
+			return checkers.Unknown("--body can not be used with GET method")
+		}
+		reqBody = strings.NewReader(opts.Body)
+	}
+	req, err := http.NewRequest(method, opts.URL, reqBody)
 	if err != nil {
 		return checkers.Unknown(err.Error())
 	}
@@ -272,6 +512,18 @@ func Run(args []string) *checkers.Checker {
 		req.Header = header
 	}
 
+	if opts.Username != "" || opts.Password != "" {
+		if opts.Username == "" || opts.Password == "" {
+			return checkers.Unknown("Both --username and --password are required for basic auth")
+		}
+		req.SetBasicAuth(opts.Username, opts.Password)
+	}
+
+	// set Content-Type of the body unless specified by `opts.Headers`
+	if opts.Body != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", opts.ContentType)
+	}
+
 	// set default User-Agent unless specified by `opts.Headers`
 	if _, ok := req.Header["User-Agent"]; !ok {
 		req.Header.Set("User-Agent", "check-http")
@@ -282,10 +534,17 @@ func Run(args []string) *checkers.Checker {
 	if err != nil {
 		return checkers.Critical(err.Error())
 	}
-	elapsed := time.Since(stTime)
 	defer resp.Body.Close()
 
-	body, _ := ioutil.ReadAll(resp.Body)
+	// read one more byte than --max-body-bytes to tell whether the body is
+	// truncated
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, opts.MaxBodyBytes+1))
+	elapsed := time.Since(stTime)
+	readBytes := int64(len(body))
+	truncated := readBytes > opts.MaxBodyBytes
+	if truncated {
+		body = body[:opts.MaxBodyBytes]
+	}
 
 	cLength := resp.ContentLength
 	if cLength == -1 {
@@ -316,19 +575,100 @@ func Run(args []string) *checkers.Checker {
 
 	respMsg := new(bytes.Buffer)
 
+	// the body is cut off at --max-body-bytes, so the size thresholds use
+	// Content-Length or count the rest of the body without keeping it
+	size := readBytes
+	if opts.WarningSize > 0 || opts.CriticalSize > 0 || opts.MinSize > 0 {
+		if resp.ContentLength >= 0 {
+			size = resp.ContentLength
+		} else {
+			n, _ := io.Copy(ioutil.Discard, resp.Body)
+			size += n
+		}
+	}
+	if opts.CriticalSize > 0 && size > opts.CriticalSize {
+		fmt.Fprintf(respMsg, "content size %d bytes is larger than %d bytes\n", size, opts.CriticalSize)
+		checkSt = checkers.CRITICAL
+	} else if checkSt == checkers.OK {
+		if opts.WarningSize > 0 && size > opts.WarningSize {
+			fmt.Fprintf(respMsg, "content size %d bytes is larger than %d bytes\n", size, opts.WarningSize)
+			checkSt = checkers.WARNING
+		} else if size < opts.MinSize {
+			fmt.Fprintf(respMsg, "content size %d bytes is smaller than %d bytes\n", size, opts.MinSize)
+			checkSt = checkers.WARNING
+		}
+	}
+
+	elapsedMsec := elapsed.Seconds() * 1000
+	if exceedsResponseTime(elapsed, opts.Critical) {
+		fmt.Fprintf(respMsg, "response time %.3f ms is over %g ms\n", elapsedMsec, opts.Critical)
+		checkSt = checkers.CRITICAL
+	} else if exceedsResponseTime(elapsed, opts.Warning) {
+		fmt.Fprintf(respMsg, "response time %.3f ms is over %g ms\n", elapsedMsec, opts.Warning)
+		if checkSt == checkers.OK {
+			checkSt = checkers.WARNING
+		}
+	}
+
 	if opts.Regexp != "" {
 		re, err := regexp.Compile(opts.Regexp)
 		if err != nil {
 			return checkers.Unknown(err.Error())
 		}
-		if !re.Match(body) {
-			fmt.Fprintf(respMsg, "'%s' not found in the content\n", opts.Regexp)
+		if matched := re.Match(body); !matched && !opts.InvertPattern {
+			if truncated {
+				fmt.Fprintf(respMsg, "'%s' not found in the first %d bytes of the content (truncated by --max-body-bytes)\n", opts.Regexp, opts.MaxBodyBytes)
+			} else {
+				fmt.Fprintf(respMsg, "'%s' not found in the content\n", opts.Regexp)
+			}
+			checkSt = checkers.CRITICAL
+		} else if matched && opts.InvertPattern {
+			fmt.Fprintf(respMsg, "'%s' found in the content\n", opts.Regexp)
 			checkSt = checkers.CRITICAL
 		}
 	}
 
+	if len(opts.HeaderMatches) != 0 {
+		matchers, err := parseHeaderMatches(&opts)
+		if err != nil {
+			return checkers.Unknown(err.Error())
+		}
+		for _, m := range matchers {
+			values, ok := resp.Header[m.key]
+			if !ok {
+				fmt.Fprintf(respMsg, "header %s not found\n", m.key)
+				checkSt = checkers.CRITICAL
+				continue
+			}
+			if !m.match(values) {
+				fmt.Fprintf(respMsg, "header %s: '%s' does not match '%s'\n", m.key, strings.Join(values, ", "), m.re)
+				checkSt = checkers.CRITICAL
+			}
+		}
+	}
+
+	if opts.JSONKey != "" && resp.StatusCode == http.StatusOK {
+		v, err := lookupJSONNumber(body, opts.JSONKey)
+		if err == errJSONKeyNotFound {
+			return checkers.Critical(fmt.Sprintf("JSON key not found: %s", opts.JSONKey))
+		}
+		if err != nil {
+			return checkers.Unknown(err.Error())
+		}
+		if opts.JSONCritical != nil && v > *opts.JSONCritical {
+			fmt.Fprintf(respMsg, "%s %g is larger than %g\n", opts.JSONKey, v, *opts.JSONCritical)
+			checkSt = checkers.CRITICAL
+		} else if opts.JSONWarning != nil && v > *opts.JSONWarning && checkSt == checkers.OK {
+			fmt.Fprintf(respMsg, "%s %g is larger than %g\n", opts.JSONKey, v, *opts.JSONWarning)
+			checkSt = checkers.WARNING
+		}
+	}
+
 	fmt.Fprintf(respMsg, "%s %s - %d bytes in %f second response time",
 		resp.Proto, resp.Status, cLength, elapsed.Seconds())
+	if finalURL := resp.Request.URL.String(); finalURL != req.URL.String() {
+		fmt.Fprintf(respMsg, " (redirected to %s)", finalURL)
+	}
 
 	return checkers.NewChecker(checkSt, respMsg.String())
 }
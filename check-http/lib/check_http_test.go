@@ -1,11 +1,16 @@
 package checkhttp
 
 import (
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/elazarl/goproxy"
 	"github.com/elazarl/goproxy/ext/auth"
@@ -74,6 +79,93 @@ func TestStatusRange(t *testing.T) {
 	}
 }
 
+func TestCACert(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "Hello, client")
+	}))
+	defer ts.Close()
+
+	f, err := ioutil.TempFile("", "check-http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	f.Close()
+
+	testCases := []struct {
+		args []string
+		want checkers.Status
+	}{
+		{
+			// the self-signed certificate is not trusted
+			args: []string{"-u", ts.URL},
+			want: checkers.CRITICAL,
+		},
+		{
+			args: []string{"-u", ts.URL, "--ca-cert", f.Name()},
+			want: checkers.OK,
+		},
+		{
+			args: []string{"-u", ts.URL, "--ca-cert", f.Name() + ".notexist"},
+			want: checkers.UNKNOWN,
+		},
+		{
+			args: []string{"-u", ts.URL, "--client-cert", f.Name()},
+			want: checkers.UNKNOWN,
+		},
+		{
+			// the certificate without its private key
+			args: []string{"-u", ts.URL, "--client-cert", f.Name(), "--client-key", f.Name()},
+			want: checkers.UNKNOWN,
+		},
+	}
+
+	for i, tc := range testCases {
+		ckr := Run(tc.args)
+		assert.Equal(t, ckr.Status, tc.want, "#%d: Status should be %s, %s", i, tc.want, ckr.Message)
+	}
+}
+
+func TestURLFile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/notfound":
+			http.NotFound(w, r)
+		case "/error":
+			http.Error(w, "error", 500)
+		}
+	}))
+	defer ts.Close()
+
+	f, err := ioutil.TempFile("", "check-http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	fmt.Fprintf(f, "# smoke test\n%s/\n\n  %s/notfound\n#%s/error\n", ts.URL, ts.URL, ts.URL)
+	f.Close()
+
+	ckr := Run([]string{"--url-file", f.Name(), "--concurrency", "1"})
+	assert.Equal(t, checkers.WARNING, ckr.Status, "ckr.Status should be WARNING")
+	msgs := strings.Split(ckr.Message, "\n")
+	assert.Len(t, msgs, 2)
+	assert.True(t, strings.HasPrefix(msgs[0], "OK "+ts.URL+"/: HTTP/1.1 200 OK"), msgs[0])
+	assert.True(t, strings.HasPrefix(msgs[1], "WARNING "+ts.URL+"/notfound: HTTP/1.1 404 Not Found"), msgs[1])
+
+	ckr = Run([]string{"--url-file", f.Name() + ".notexist"})
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status, "ckr.Status should be UNKNOWN")
+
+	ckr = Run([]string{})
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status, "ckr.Status should be UNKNOWN")
+}
+
+func TestSeverity(t *testing.T) {
+	assert.True(t, severity(checkers.CRITICAL) > severity(checkers.UNKNOWN))
+	assert.True(t, severity(checkers.UNKNOWN) > severity(checkers.WARNING))
+	assert.True(t, severity(checkers.WARNING) > severity(checkers.OK))
+}
+
 func TestSourceIP(t *testing.T) {
 	ckr := Run([]string{"-u", "hoge", "-i", "1.2.3"})
 	assert.Equal(t, ckr.Status, checkers.UNKNOWN, "chr.Status should be UNKNOWN")
@@ -99,6 +191,128 @@ func TestHost(t *testing.T) {
 	assert.Equal(t, ckr.Status, checkers.OK, "ckr.Status should be OK")
 }
 
+func TestHeaders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("X-Api-Key") != "a:b" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		args []string
+		want checkers.Status
+	}{
+		{
+			args: []string{"-u", ts.URL, "--header", "Authorization: Bearer token", "-H", "X-API-Key:  a:b "},
+			want: checkers.OK,
+		},
+		{
+			args: []string{"-u", ts.URL, "--header", "Authorization: Bearer token"},
+			want: checkers.WARNING,
+		},
+		{
+			args: []string{"-u", ts.URL, "--header", "Authorization"},
+			want: checkers.UNKNOWN,
+		},
+		{
+			args: []string{"-u", ts.URL, "--header", ": Bearer token"},
+			want: checkers.UNKNOWN,
+		},
+	}
+
+	for i, tc := range testCases {
+		ckr := Run(tc.args)
+		assert.Equal(t, ckr.Status, tc.want, "#%d: Status should be %s, %s", i, tc.want, ckr.Message)
+	}
+
+	ckr := Run([]string{"-u", ts.URL, "-H", "Authorization"})
+	assert.Equal(t, "Invalid header: Authorization", ckr.Message)
+}
+
+func TestMethodAndBody(t *testing.T) {
+	body := `{"probe": "it's me"}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.Header.Get("Content-Type"), b)
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		args []string
+		want checkers.Status
+	}{
+		{
+			args: []string{"-u", ts.URL, "-p", "^GET  $"},
+			want: checkers.OK,
+		},
+		{
+			args: []string{"-u", ts.URL, "--method", "POST", "-d", body,
+				"-p", "^POST application/json " + regexp.QuoteMeta(body) + "$"},
+			want: checkers.OK,
+		},
+		{
+			args: []string{"-u", ts.URL, "--method", "put", "--body", "a=b",
+				"--content-type", "application/x-www-form-urlencoded",
+				"-p", "^PUT application/x-www-form-urlencoded a=b$"},
+			want: checkers.OK,
+		},
+		{
+			args: []string{"-u", ts.URL, "--method", "POST", "-d", "a=b", "-H", "Content-Type: text/plain",
+				"-p", "^POST text/plain a=b$"},
+			want: checkers.OK,
+		},
+		{
+			args: []string{"-u", ts.URL, "-d", body},
+			want: checkers.UNKNOWN,
+		},
+	}
+
+	for i, tc := range testCases {
+		ckr := Run(tc.args)
+		assert.Equal(t, ckr.Status, tc.want, "#%d: Status should be %s, %s", i, tc.want, ckr.Message)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "somename" || pass != "somepassword" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="check-http"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		args []string
+		want checkers.Status
+	}{
+		{
+			args: []string{"-u", ts.URL},
+			want: checkers.WARNING,
+		},
+		{
+			args: []string{"-u", ts.URL, "--username", "somename", "--password", "somepassword"},
+			want: checkers.OK,
+		},
+		{
+			args: []string{"-u", ts.URL, "--username", "somename", "--password", "wrong"},
+			want: checkers.WARNING,
+		},
+		{
+			args: []string{"-u", ts.URL, "--username", "somename"},
+			want: checkers.UNKNOWN,
+		},
+	}
+
+	for i, tc := range testCases {
+		ckr := Run(tc.args)
+		assert.Equal(t, ckr.Status, tc.want, "#%d: Status should be %s, %s", i, tc.want, ckr.Message)
+		assert.NotContains(t, ckr.Message, "somepassword")
+	}
+}
+
 func TestExpectedContent(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintln(w, "Hello, client")
@@ -137,6 +351,216 @@ func TestExpectedContent(t *testing.T) {
 	}
 }
 
+func TestInvertPattern(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"status":"ok"}`)
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		args    []string
+		want    checkers.Status
+		message string
+	}{
+		{
+			args: []string{"-u", ts.URL, "-p", `status":"ok`},
+			want: checkers.OK,
+		},
+		{
+			args: []string{"-u", ts.URL, "-p", `status":"ok`, "--invert-pattern"},
+			want: checkers.CRITICAL,
+		},
+		{
+			args: []string{"-u", ts.URL, "-p", `status":"ng`, "--invert-pattern"},
+			want: checkers.OK,
+		},
+		{
+			args:    []string{"-u", ts.URL, "-p", `status":"ng`},
+			want:    checkers.CRITICAL,
+			message: `'status":"ng' not found in the content`,
+		},
+		{
+			// only the first 10 bytes are read
+			args:    []string{"-u", ts.URL, "-p", `ok"}`, "--max-body-bytes", "10"},
+			want:    checkers.CRITICAL,
+			message: `'ok"}' not found in the first 10 bytes of the content (truncated by --max-body-bytes)`,
+		},
+		{
+			args:    []string{"-u", ts.URL, "-p", `ok"}`, "--max-body-bytes", "0"},
+			want:    checkers.UNKNOWN,
+			message: "--max-body-bytes must be greater than 0",
+		},
+	}
+
+	for i, tc := range testCases {
+		ckr := Run(tc.args)
+		assert.Equal(t, ckr.Status, tc.want, "#%d: Status should be %s, %s", i, tc.want, ckr.Message)
+		assert.Contains(t, ckr.Message, tc.message)
+	}
+}
+
+func TestContentSize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/empty":
+			return
+		case "/chunked":
+			// flushing before writing the whole body omits Content-Length
+			for i := 0; i < 4; i++ {
+				fmt.Fprint(w, "0123456789")
+				w.(http.Flusher).Flush()
+			}
+			return
+		}
+		fmt.Fprint(w, "0123456789")
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		args    []string
+		want    checkers.Status
+		message string
+	}{
+		{
+			args: []string{"-u", ts.URL, "--warning-size", "10", "--critical-size", "20", "--min-size", "10"},
+			want: checkers.OK,
+		},
+		{
+			args:    []string{"-u", ts.URL, "--warning-size", "5", "--critical-size", "20"},
+			want:    checkers.WARNING,
+			message: "content size 10 bytes is larger than 5 bytes",
+		},
+		{
+			args:    []string{"-u", ts.URL, "--warning-size", "5", "--critical-size", "8"},
+			want:    checkers.CRITICAL,
+			message: "content size 10 bytes is larger than 8 bytes",
+		},
+		{
+			args:    []string{"-u", ts.URL + "/empty", "--min-size", "1"},
+			want:    checkers.WARNING,
+			message: "content size 0 bytes is smaller than 1 bytes",
+		},
+		{
+			args:    []string{"-u", ts.URL, "--max-body-bytes", "4", "--critical-size", "8"},
+			want:    checkers.CRITICAL,
+			message: "content size 10 bytes is larger than 8 bytes",
+		},
+		{
+			args:    []string{"-u", ts.URL + "/chunked", "--max-body-bytes", "4", "--warning-size", "30"},
+			want:    checkers.WARNING,
+			message: "content size 40 bytes is larger than 30 bytes",
+		},
+	}
+
+	for i, tc := range testCases {
+		ckr := Run(tc.args)
+		assert.Equal(t, ckr.Status, tc.want, "#%d: Status should be %s, %s", i, tc.want, ckr.Message)
+		assert.Contains(t, ckr.Message, tc.message)
+	}
+}
+
+func TestLookupJSONNumber(t *testing.T) {
+	body := []byte(`{"data": {"queue": {"depth": 1500, "name": "jobs"}, "nodes": [{"load": 0.5}]}}`)
+
+	v, err := lookupJSONNumber(body, "data.queue.depth")
+	assert.Nil(t, err)
+	assert.Equal(t, 1500.0, v)
+
+	v, err = lookupJSONNumber(body, "data.nodes.0.load")
+	assert.Nil(t, err)
+	assert.Equal(t, 0.5, v)
+
+	_, err = lookupJSONNumber(body, "data.queue.size")
+	assert.Equal(t, errJSONKeyNotFound, err)
+
+	_, err = lookupJSONNumber(body, "data.nodes.1.load")
+	assert.Equal(t, errJSONKeyNotFound, err)
+
+	_, err = lookupJSONNumber(body, "data.queue.name")
+	assert.EqualError(t, err, "JSON value of data.queue.name is not a number: jobs")
+
+	_, err = lookupJSONNumber([]byte("<html>"), "data")
+	assert.NotNil(t, err)
+}
+
+func TestJSONKey(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"queue_depth": 1500, "status": "ok"}`)
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		args []string
+		want checkers.Status
+	}{
+		{
+			args: []string{"-u", ts.URL, "-j", "queue_depth", "--json-warning", "2000", "--json-critical", "3000"},
+			want: checkers.OK,
+		},
+		{
+			args: []string{"-u", ts.URL, "-j", "queue_depth", "--json-warning", "1000", "--json-critical", "3000"},
+			want: checkers.WARNING,
+		},
+		{
+			args: []string{"-u", ts.URL, "-j", "queue_depth", "--json-warning", "1000", "--json-critical", "1499.5"},
+			want: checkers.CRITICAL,
+		},
+		{
+			args: []string{"-u", ts.URL, "-j", "queue_size", "--json-critical", "3000"},
+			want: checkers.CRITICAL,
+		},
+		{
+			args: []string{"-u", ts.URL, "-j", "status", "--json-critical", "3000"},
+			want: checkers.UNKNOWN,
+		},
+	}
+
+	for i, tc := range testCases {
+		ckr := Run(tc.args)
+		assert.Equal(t, ckr.Status, tc.want, "#%d: Status should be %s, %s", i, tc.want, ckr.Message)
+	}
+}
+
+func TestHeaderMatches(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Cache", "HIT")
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		args    []string
+		want    checkers.Status
+		message string
+	}{
+		{
+			args: []string{"-u", ts.URL, "--header-match", "X-Cache: ^HIT$",
+				"--header-match", "strict-transport-security: max-age=\\d+"},
+			want: checkers.OK,
+		},
+		{
+			args: []string{"-u", ts.URL, "--header-match", "X-Cache: ^MISS$",
+				"--header-match", "X-Frame-Options: DENY"},
+			want:    checkers.CRITICAL,
+			message: "header X-Cache: 'HIT' does not match '^MISS$'\nheader X-Frame-Options not found\n",
+		},
+		{
+			args: []string{"-u", ts.URL, "--header-match", "X-Cache"},
+			want: checkers.UNKNOWN,
+		},
+		{
+			args: []string{"-u", ts.URL, "--header-match", "X-Cache: ???"},
+			want: checkers.UNKNOWN,
+		},
+	}
+
+	for i, tc := range testCases {
+		ckr := Run(tc.args)
+		assert.Equal(t, ckr.Status, tc.want, "#%d: Status should be %s, %s", i, tc.want, ckr.Message)
+		assert.Contains(t, ckr.Message, tc.message)
+	}
+}
+
 func TestMaxRedirects(t *testing.T) {
 	redirectedPath := "/redirected"
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -173,6 +597,36 @@ func TestMaxRedirects(t *testing.T) {
 	}
 }
 
+func TestRedirects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loop1":
+			http.Redirect(w, r, "/loop2", 301)
+		case "/loop2":
+			http.Redirect(w, r, "/loop1", 301)
+		case "/redirect":
+			http.Redirect(w, r, "/redirected", 302)
+		}
+	}))
+	defer ts.Close()
+
+	ckr := Run([]string{"-u", ts.URL + "/redirect"})
+	assert.Equal(t, checkers.OK, ckr.Status, "ckr.Status should be OK")
+	assert.Contains(t, ckr.Message, "(redirected to "+ts.URL+"/redirected)")
+
+	ckr = Run([]string{"-u", ts.URL + "/redirect", "--no-follow-redirects", "-s", "302=critical"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status, "ckr.Status should be CRITICAL")
+	assert.NotContains(t, ckr.Message, "redirected to")
+
+	ckr = Run([]string{"-u", ts.URL + "/loop1"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status, "ckr.Status should be CRITICAL")
+	assert.Contains(t, ckr.Message, "stopped after 10 redirects")
+
+	ckr = Run([]string{"-u", ts.URL + "/loop1", "--max-redirects", "3"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status, "ckr.Status should be CRITICAL")
+	assert.Contains(t, ckr.Message, "stopped after 3 redirects")
+}
+
 func TestConnectTos(t *testing.T) {
 	// expected server
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -386,3 +840,55 @@ func TestProxy_Auth(t *testing.T) {
 		assert.Equal(t, ckr.Status, tc.want, "#%d: Status should be %s, %s", i, tc.want, ckr.Message)
 	}
 }
+
+func TestResponseTime(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		fmt.Fprintln(w, "Hello, client")
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		args    []string
+		want    checkers.Status
+		message string
+	}{
+		{
+			args:    []string{"-u", ts.URL, "-w", "5000", "-c", "10000"},
+			want:    checkers.OK,
+			message: `^HTTP/1\.1 200 OK`,
+		},
+		{
+			args:    []string{"-u", ts.URL, "-w", "50", "-c", "10000"},
+			want:    checkers.WARNING,
+			message: `^response time [\d.]+ ms is over 50 ms\n`,
+		},
+		{
+			args:    []string{"-u", ts.URL, "-w", "50", "-c", "80"},
+			want:    checkers.CRITICAL,
+			message: `^response time [\d.]+ ms is over 80 ms\n`,
+		},
+		{
+			// the status mapping is not loosened by the response time
+			args:    []string{"-u", ts.URL, "-s", "200=critical", "-w", "50"},
+			want:    checkers.CRITICAL,
+			message: `^response time [\d.]+ ms is over 50 ms\n`,
+		},
+	}
+
+	for i, tc := range testCases {
+		ckr := Run(tc.args)
+		assert.Equal(t, ckr.Status, tc.want, "#%d: Status should be %s, %s", i, tc.want, ckr.Message)
+		assert.Regexp(t, tc.message, ckr.Message, "#%d", i)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(1500 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	ckr := Run([]string{"-u", ts.URL, "-t", "1"})
+	assert.Equal(t, ckr.Status, checkers.CRITICAL, "ckr.Status should be CRITICAL")
+}
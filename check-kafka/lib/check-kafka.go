@@ -0,0 +1,220 @@
+package checkkafka
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+type kafkaOpts struct {
+	Brokers       []string `short:"b" long:"broker" value-name:"HOST:PORT" default:"localhost:9092" description:"Broker address (repeatable)"`
+	Topic         string   `short:"t" long:"topic" description:"Topic name (required with --consumer-group, all the topics by default otherwise)"`
+	ConsumerGroup string   `short:"g" long:"consumer-group" description:"Consumer group to check the lag of instead of the partitions"`
+	Warning       *int64   `short:"w" long:"warning" value-name:"MESSAGES" description:"warning if the total lag of the consumer group is over"`
+	Critical      *int64   `short:"c" long:"critical" value-name:"MESSAGES" description:"critical if the total lag of the consumer group is over"`
+	Timeout       int      `long:"timeout" value-name:"SECONDS" default:"10" description:"Timeout in seconds"`
+	TLS           bool     `long:"tls" description:"Connect to the brokers with TLS"`
+	SASLUsername  string   `long:"sasl-username" description:"Username for SASL/PLAIN authentication"`
+	SASLPassword  string   `long:"sasl-password" description:"Password for SASL/PLAIN authentication" env:"KAFKA_SASL_PASSWORD"`
+
+	UnderReplicatedWarning    int `long:"under-replicated-warning" value-name:"PARTITIONS" default:"0" description:"warning if the number of the under-replicated partitions is over"`
+	UnderReplicatedCritical   int `long:"under-replicated-critical" value-name:"PARTITIONS" default:"1" description:"critical if the number of the under-replicated partitions is over"`
+	OfflinePartitionsCritical int `long:"offline-partitions-critical" value-name:"PARTITIONS" default:"0" description:"critical if the number of the partitions without the leader is over"`
+}
+
+// Do the plugin
+func Do() {
+	ckr := run(os.Args[1:])
+	ckr.Name = "Kafka"
+	ckr.Exit()
+}
+
+func newConfig(opts *kafkaOpts) *sarama.Config {
+	conf := sarama.NewConfig()
+	conf.ClientID = "check-kafka"
+	timeout := time.Duration(opts.Timeout) * time.Second
+	conf.Net.DialTimeout = timeout
+	conf.Net.ReadTimeout = timeout
+	conf.Net.WriteTimeout = timeout
+	if opts.TLS {
+		conf.Net.TLS.Enable = true
+		conf.Net.TLS.Config = &tls.Config{}
+	}
+	if opts.SASLUsername != "" {
+		conf.Net.SASL.Enable = true
+		conf.Net.SASL.User = opts.SASLUsername
+		conf.Net.SASL.Password = opts.SASLPassword
+	}
+	// only reads the committed offsets
+	conf.Consumer.Offsets.AutoCommit.Enable = false
+	conf.Consumer.Offsets.Initial = sarama.OffsetOldest
+	return conf
+}
+
+type partitionLag struct {
+	partition int32
+	committed int64 // sarama.OffsetOldest if no offset is committed
+	latest    int64
+	lag       int64
+}
+
+// fetchLags returns the lag of the consumer group in each partition of the
+// topic. The lag of the partition without the committed offset is counted
+// from the oldest offset.
+func fetchLags(client sarama.Client, group, topic string) ([]partitionLag, error) {
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return nil, err
+	}
+	om, err := sarama.NewOffsetManagerFromClient(group, client)
+	if err != nil {
+		return nil, err
+	}
+	defer om.Close()
+
+	var lags []partitionLag
+	for _, p := range partitions {
+		pom, err := om.ManagePartition(topic, p)
+		if err != nil {
+			return nil, err
+		}
+		committed, _ := pom.NextOffset()
+		pom.Close()
+
+		latest, err := client.GetOffset(topic, p, sarama.OffsetNewest)
+		if err != nil {
+			return nil, err
+		}
+		from := committed
+		if committed == sarama.OffsetOldest {
+			from, err = client.GetOffset(topic, p, sarama.OffsetOldest)
+			if err != nil {
+				return nil, err
+			}
+		}
+		lag := latest - from
+		if lag < 0 {
+			lag = 0
+		}
+		lags = append(lags, partitionLag{partition: p, committed: committed, latest: latest, lag: lag})
+	}
+	sort.Slice(lags, func(i, j int) bool { return lags[i].partition < lags[j].partition })
+	return lags, nil
+}
+
+func evalLags(opts *kafkaOpts, lags []partitionLag) *checkers.Checker {
+	var total int64
+	var details []string
+	for _, l := range lags {
+		total += l.lag
+		committed := fmt.Sprint(l.committed)
+		if l.committed == sarama.OffsetOldest {
+			committed = "none"
+		}
+		details = append(details, fmt.Sprintf("partition %d: committed %s, latest %d, lag %d", l.partition, committed, l.latest, l.lag))
+	}
+
+	checkSt := checkers.OK
+	if opts.Critical != nil && total > *opts.Critical {
+		checkSt = checkers.CRITICAL
+	} else if opts.Warning != nil && total > *opts.Warning {
+		checkSt = checkers.WARNING
+	}
+	msg := fmt.Sprintf("total lag %d of the consumer group %s on the topic %s\n%s", total, opts.ConsumerGroup, opts.Topic, strings.Join(details, "\n"))
+	return checkers.NewChecker(checkSt, msg)
+}
+
+// evalPartitions counts the under-replicated partitions, whose ISR is
+// smaller than the replicas, and the offline partitions without the leader
+func evalPartitions(opts *kafkaOpts, topics []*sarama.TopicMetadata) *checkers.Checker {
+	var partitions int
+	var underReplicated, offline []string
+	for _, topic := range topics {
+		for _, p := range topic.Partitions {
+			partitions++
+			name := fmt.Sprintf("%s-%d", topic.Name, p.ID)
+			if len(p.Isr) < len(p.Replicas) {
+				underReplicated = append(underReplicated, name)
+			}
+			if p.Leader < 0 || p.Err == sarama.ErrLeaderNotAvailable {
+				offline = append(offline, name)
+			}
+		}
+	}
+	sort.Strings(underReplicated)
+	sort.Strings(offline)
+
+	checkSt := checkers.OK
+	if len(underReplicated) > opts.UnderReplicatedCritical || len(offline) > opts.OfflinePartitionsCritical {
+		checkSt = checkers.CRITICAL
+	} else if len(underReplicated) > opts.UnderReplicatedWarning {
+		checkSt = checkers.WARNING
+	}
+	msg := fmt.Sprintf("%d under-replicated, %d offline in %d partitions of %d topics", len(underReplicated), len(offline), partitions, len(topics))
+	if len(underReplicated) > 0 {
+		msg += "\nunder-replicated: " + strings.Join(underReplicated, ", ")
+	}
+	if len(offline) > 0 {
+		msg += "\noffline: " + strings.Join(offline, ", ")
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func checkPartitions(opts *kafkaOpts, client sarama.Client) *checkers.Checker {
+	topics := []string{opts.Topic}
+	if opts.Topic == "" {
+		var err error
+		topics, err = client.Topics()
+		if err != nil {
+			return checkers.Critical(fmt.Sprintf("couldn't fetch the topics: %s", err))
+		}
+	}
+	// Close of the admin closes the client too
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		return checkers.Critical(err.Error())
+	}
+	metadata, err := admin.DescribeTopics(topics)
+	if err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't fetch the metadata: %s", err))
+	}
+	for _, topic := range metadata {
+		if topic.Err != sarama.ErrNoError {
+			return checkers.Critical(fmt.Sprintf("topic %s: %s", topic.Name, topic.Err))
+		}
+	}
+	return evalPartitions(opts, metadata)
+}
+
+func run(args []string) *checkers.Checker {
+	opts := kafkaOpts{}
+	_, err := flags.ParseArgs(&opts, args)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	client, err := sarama.NewClient(opts.Brokers, newConfig(&opts))
+	if err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't connect to the brokers: %s", err))
+	}
+	defer client.Close()
+
+	if opts.ConsumerGroup == "" {
+		return checkPartitions(&opts, client)
+	}
+	if opts.Topic == "" {
+		return checkers.Unknown("--topic is required with --consumer-group")
+	}
+	lags, err := fetchLags(client, opts.ConsumerGroup, opts.Topic)
+	if err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't fetch the offsets: %s", err))
+	}
+	return evalLags(&opts, lags)
+}
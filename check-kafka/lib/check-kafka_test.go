@@ -0,0 +1,71 @@
+package checkkafka
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvalLags(t *testing.T) {
+	lags := []partitionLag{
+		{partition: 0, committed: 100, latest: 150, lag: 50},
+		{partition: 1, committed: sarama.OffsetOldest, latest: 30, lag: 30},
+	}
+	warn, crit := int64(50), int64(100)
+
+	ckr := evalLags(&kafkaOpts{Topic: "events", ConsumerGroup: "indexer", Warning: &warn, Critical: &crit}, lags)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "total lag 80 of the consumer group indexer on the topic events\npartition 0: committed 100, latest 150, lag 50\npartition 1: committed none, latest 30, lag 30", ckr.Message)
+
+	crit = 70
+	ckr = evalLags(&kafkaOpts{Topic: "events", ConsumerGroup: "indexer", Warning: &warn, Critical: &crit}, lags)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = evalLags(&kafkaOpts{Topic: "events", ConsumerGroup: "indexer"}, lags)
+	assert.Equal(t, checkers.OK, ckr.Status)
+}
+
+func TestNewConfig(t *testing.T) {
+	conf := newConfig(&kafkaOpts{Timeout: 5, TLS: true, SASLUsername: "monitor", SASLPassword: "secret"})
+	assert.True(t, conf.Net.TLS.Enable)
+	assert.True(t, conf.Net.SASL.Enable)
+	assert.Equal(t, "monitor", conf.Net.SASL.User)
+	assert.False(t, conf.Consumer.Offsets.AutoCommit.Enable)
+	assert.Nil(t, conf.Validate())
+}
+
+func TestEvalPartitions(t *testing.T) {
+	opts := &kafkaOpts{UnderReplicatedWarning: 0, UnderReplicatedCritical: 1, OfflinePartitionsCritical: 0}
+	topics := []*sarama.TopicMetadata{
+		{Name: "events", Partitions: []*sarama.PartitionMetadata{
+			{ID: 0, Leader: 1, Replicas: []int32{1, 2, 3}, Isr: []int32{1, 2, 3}},
+			{ID: 1, Leader: 2, Replicas: []int32{2, 3, 1}, Isr: []int32{2, 3, 1}},
+		}},
+		{Name: "logs", Partitions: []*sarama.PartitionMetadata{
+			{ID: 0, Leader: 3, Replicas: []int32{3, 1, 2}, Isr: []int32{3, 1, 2}},
+		}},
+	}
+	ckr := evalPartitions(opts, topics)
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "0 under-replicated, 0 offline in 3 partitions of 2 topics", ckr.Message)
+
+	topics[0].Partitions[1].Isr = []int32{2, 3}
+	ckr = evalPartitions(opts, topics)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "1 under-replicated, 0 offline in 3 partitions of 2 topics\nunder-replicated: events-1", ckr.Message)
+
+	topics[1].Partitions[0].Isr = []int32{3}
+	ckr = evalPartitions(opts, topics)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	topics = []*sarama.TopicMetadata{
+		{Name: "events", Partitions: []*sarama.PartitionMetadata{
+			{ID: 0, Leader: -1, Replicas: []int32{1}, Isr: []int32{1}, Err: sarama.ErrLeaderNotAvailable},
+		}},
+	}
+	ckr = evalPartitions(opts, topics)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "0 under-replicated, 1 offline in 1 partitions of 1 topics\noffline: events-0", ckr.Message)
+}
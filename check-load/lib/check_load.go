@@ -12,30 +12,61 @@ import (
 	"github.com/mackerelio/checkers"
 )
 
-var opts struct {
-	WarningThreshold  string `short:"w" long:"warning" required:"true" value-name:"WL1,WL5,WL15" description:"Warning threshold for loadavg1,5,15"`
-	CriticalThreshold string `short:"c" long:"critical" required:"true" value-name:"CL1,CL5,CL15" description:"Critical threshold for loadavg1,5,15"`
-	PerCPU            bool   `short:"r" long:"percpu" description:"Divide the load averages by cpu count"`
+type loadOpts struct {
+	WarningThreshold    string   `short:"w" long:"warning" value-name:"WL1[,WL5,WL15]" description:"Warning threshold for loadavg1, or loadavg1,5,15"`
+	CriticalThreshold   string   `short:"c" long:"critical" value-name:"CL1[,CL5,CL15]" description:"Critical threshold for loadavg1, or loadavg1,5,15"`
+	WarningThreshold5   *float64 `long:"warning5" value-name:"WL5" description:"Warning threshold for loadavg5"`
+	CriticalThreshold5  *float64 `long:"critical5" value-name:"CL5" description:"Critical threshold for loadavg5"`
+	WarningThreshold15  *float64 `long:"warning15" value-name:"WL15" description:"Warning threshold for loadavg15"`
+	CriticalThreshold15 *float64 `long:"critical15" value-name:"CL15" description:"Critical threshold for loadavg15"`
+	PerCPU              bool     `short:"r" long:"percpu" description:"Divide the load averages by cpu count. Without any thresholds, warning at 0.7 and critical at 1.0 per CPU are used"`
+	WarningPerCPU       string   `long:"warning-percpu" value-name:"WL1[,WL5,WL15]" description:"Warning threshold per CPU, which is the same as --warning with --percpu"`
+	CriticalPerCPU      string   `long:"critical-percpu" value-name:"CL1[,CL5,CL15]" description:"Critical threshold per CPU, which is the same as --critical with --percpu"`
 }
 
-func parseThreshold(str string) ([3]float64, error) {
-	thresholds := [3]float64{0, 0, 0}
+// default thresholds per CPU when no threshold is given
+const (
+	defaultWarningPerCPU  = 0.7
+	defaultCriticalPerCPU = 1.0
+)
+
+// parseThreshold parses a threshold for loadavg1 or comma-separated
+// thresholds for loadavg1,5,15. The thresholds not given are nil.
+func parseThreshold(str string) ([3]*float64, error) {
+	var thresholds [3]*float64
+	if str == "" {
+		return thresholds, nil
+	}
 
 	thSt := strings.Split(str, ",")
-	if len(thSt) != 3 {
-		return thresholds, errors.New("Threshold must be comma-separated 3 numbers")
+	if len(thSt) != 1 && len(thSt) != 3 {
+		return thresholds, errors.New("Threshold must be a number or comma-separated 3 numbers")
 	}
 
-	var err error
 	for i, v := range thSt {
-		thresholds[i], err = strconv.ParseFloat(v, 64)
+		th, err := strconv.ParseFloat(v, 64)
 		if err != nil {
 			return thresholds, err
 		}
+		thresholds[i] = &th
 	}
 	return thresholds, nil
 }
 
+// evalLoad returns the worst status of the load averages
+func evalLoad(loadavgs [3]float64, wload, cload [3]*float64) checkers.Status {
+	result := checkers.OK
+	for i, load := range loadavgs {
+		if cload[i] != nil && load > *cload[i] {
+			return checkers.CRITICAL
+		}
+		if wload[i] != nil && load > *wload[i] {
+			result = checkers.WARNING
+		}
+	}
+	return result
+}
+
 // Do the plugin
 func Do() {
 	ckr := run(os.Args[1:])
@@ -44,11 +75,21 @@ func Do() {
 }
 
 func run(args []string) *checkers.Checker {
+	opts := loadOpts{}
 	_, err := flags.ParseArgs(&opts, args)
 	if err != nil {
 		os.Exit(1)
 	}
 
+	if opts.WarningPerCPU != "" || opts.CriticalPerCPU != "" {
+		if opts.WarningThreshold != "" || opts.CriticalThreshold != "" {
+			return checkers.Unknown("--warning-percpu and --critical-percpu can't be used with --warning and --critical")
+		}
+		opts.WarningThreshold = opts.WarningPerCPU
+		opts.CriticalThreshold = opts.CriticalPerCPU
+		opts.PerCPU = true
+	}
+
 	wload, err := parseThreshold(opts.WarningThreshold)
 	if err != nil {
 		return checkers.Unknown(err.Error())
@@ -57,27 +98,45 @@ func run(args []string) *checkers.Checker {
 	if err != nil {
 		return checkers.Unknown(err.Error())
 	}
+	for _, v := range []struct {
+		th   *float64
+		dest **float64
+	}{
+		{opts.WarningThreshold5, &wload[1]},
+		{opts.CriticalThreshold5, &cload[1]},
+		{opts.WarningThreshold15, &wload[2]},
+		{opts.CriticalThreshold15, &cload[2]},
+	} {
+		if v.th != nil {
+			*v.dest = v.th
+		}
+	}
+	if wload == [3]*float64{} && cload == [3]*float64{} {
+		// adapt to the number of CPUs
+		opts.PerCPU = true
+		for i := range wload {
+			w, c := defaultWarningPerCPU, defaultCriticalPerCPU
+			wload[i], cload[i] = &w, &c
+		}
+	}
 
 	loadavgs, err := getloadavg()
 	if err != nil {
 		return checkers.Unknown(err.Error())
 	}
 
-	result := checkers.OK
-	for i, load := range loadavgs {
-		if opts.PerCPU {
-			numCPU := runtime.NumCPU()
-			load = load / float64(numCPU)
-		}
-		if load > cload[i] {
-			result = checkers.CRITICAL
-			break
-		}
-		if load > wload[i] {
-			result = checkers.WARNING
+	numCPU := runtime.NumCPU()
+	loads := loadavgs
+	if opts.PerCPU {
+		for i := range loads {
+			loads[i] = loads[i] / float64(numCPU)
 		}
 	}
+	result := evalLoad(loads, wload, cload)
 
-	msg := fmt.Sprintf("load average: %.2f, %.2f, %.2f", loadavgs[0], loadavgs[1], loadavgs[2])
+	msg := fmt.Sprintf("load average: %.2f, %.2f, %.2f (%d CPUs)", loadavgs[0], loadavgs[1], loadavgs[2], numCPU)
+	if opts.PerCPU {
+		msg += fmt.Sprintf(", per CPU: %.2f, %.2f, %.2f", loads[0], loads[1], loads[2])
+	}
 	return checkers.NewChecker(result, msg)
 }
@@ -1,6 +1,9 @@
+// +build darwin freebsd
+
 package checkload
 
 import (
+	"fmt"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -17,7 +20,7 @@ func getloadavg() (loadavgs [3]float64, err error) {
 	// fields will be "{", <loadavg1>, <loadavg5>, <loadavg15>, "}"
 	fields := strings.Fields(output)
 	if len(fields) != 5 || fields[0] != "{" || fields[len(fields)-1] != "}" {
-		return loadavgs, err
+		return loadavgs, fmt.Errorf("Failed to parse vm.loadavg: %s", output)
 	}
 
 	for i := 0; i < 3; i++ {
@@ -0,0 +1,44 @@
+package checkload
+
+import (
+	"testing"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseThreshold(t *testing.T) {
+	th, err := parseThreshold("4,3,2")
+	assert.Nil(t, err)
+	assert.Equal(t, 4.0, *th[0])
+	assert.Equal(t, 3.0, *th[1])
+	assert.Equal(t, 2.0, *th[2])
+
+	th, err = parseThreshold("1.5")
+	assert.Nil(t, err)
+	assert.Equal(t, 1.5, *th[0])
+	assert.Nil(t, th[1])
+	assert.Nil(t, th[2])
+
+	th, err = parseThreshold("")
+	assert.Nil(t, err)
+	assert.Equal(t, [3]*float64{}, th)
+
+	_, err = parseThreshold("4,3")
+	assert.NotNil(t, err)
+
+	_, err = parseThreshold("a,b,c")
+	assert.NotNil(t, err)
+}
+
+func TestEvalLoad(t *testing.T) {
+	wload, _ := parseThreshold("4,3,2")
+	cload, _ := parseThreshold("8,6,4")
+
+	assert.Equal(t, checkers.OK, evalLoad([3]float64{1, 1, 1}, wload, cload))
+	assert.Equal(t, checkers.WARNING, evalLoad([3]float64{1, 1, 3}, wload, cload))
+	assert.Equal(t, checkers.CRITICAL, evalLoad([3]float64{9, 1, 3}, wload, cload))
+
+	w1, _ := parseThreshold("4")
+	assert.Equal(t, checkers.OK, evalLoad([3]float64{1, 100, 100}, w1, [3]*float64{}), "loadavg5,15 should not be checked")
+}
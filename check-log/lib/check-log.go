@@ -3,6 +3,7 @@ package checklog
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/md5"
 	"encoding/json"
@@ -14,8 +15,10 @@ import (
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/mackerelio/checkers"
@@ -31,14 +34,18 @@ var defaultSignal = os.Interrupt
 
 type logOpts struct {
 	LogFile             string   `short:"f" long:"file" value-name:"FILE" description:"Path to log file"`
-	Pattern             []string `short:"p" long:"pattern" required:"true" value-name:"PAT" description:"Pattern to search for. If specified multiple, they will be treated together with the AND operator"`
+	Pattern             []string `short:"p" long:"pattern" required:"true" value-name:"PAT" description:"Pattern to search for. If specified multiple, they will be treated together with the OR operator (or the AND operator with --all-patterns)"`
+	PatternName         []string `long:"pattern-name" value-name:"NAME" description:"Name of the pattern to display, in the same order as --pattern"`
+	AllPatterns         bool     `long:"all-patterns" description:"Count lines matching all the patterns instead of any of them"`
 	SuppressPattern     bool     `long:"suppress-pattern" description:"Suppress pattern display"`
 	Exclude             string   `short:"E" long:"exclude" value-name:"PAT" description:"Pattern to exclude from matching"`
+	ExcludePattern      []string `long:"exclude-pattern" value-name:"PAT" description:"Pattern to exclude from matching. If specified multiple, lines matching any of them are excluded"`
 	WarnOver            int64    `short:"w" long:"warning-over" description:"Trigger a warning if matched lines is over a number"`
 	CritOver            int64    `short:"c" long:"critical-over" description:"Trigger a critical if matched lines is over a number"`
 	WarnLevel           float64  `long:"warning-level" value-name:"N" description:"Warning level if pattern has a group"`
 	CritLevel           float64  `long:"critical-level" value-name:"N" description:"Critical level if pattern has a group"`
 	ReturnContent       bool     `short:"r" long:"return" description:"Return matched line"`
+	MaxOutputLines      int      `long:"max-output-lines" value-name:"N" description:"Max number of matched lines to return with --return (0 means unlimited)"`
 	FilePattern         string   `short:"F" long:"file-pattern" value-name:"FILE" description:"Check a pattern of files, instead of one file"`
 	CaseInsensitive     bool     `short:"i" long:"icase" description:"Run a case insensitive match"`
 	StateDir            string   `short:"s" long:"state-dir" value-name:"DIR" description:"Dir to keep state files under"`
@@ -46,8 +53,19 @@ type logOpts struct {
 	Encoding            string   `long:"encoding" description:"Encoding of log file"`
 	Missing             string   `long:"missing" default:"UNKNOWN" value-name:"(CRITICAL|WARNING|OK|UNKNOWN)" description:"Exit status when log files missing"`
 	CheckFirst          bool     `long:"check-first" description:"Check the log on the first run"`
+	TimeWindow          int64    `long:"time-window" value-name:"SEC" description:"Count matched lines within the last SEC seconds, instead of since the last check"`
+	WindowWarn          int64    `long:"window-warning" value-name:"N" description:"Trigger a warning if matched lines within the time window is over a number (with --time-window)"`
+	WindowCrit          int64    `long:"window-critical" value-name:"N" description:"Trigger a critical if matched lines within the time window is over a number (with --time-window)"`
+	JSONField           string   `long:"json-field" value-name:"PATH" description:"Dot-separated path of a numeric field in JSON lines to aggregate. The thresholds of --warning-over and --critical-over are applied to the aggregated value instead of matched lines"`
+	JSONAgg             string   `long:"json-agg" default:"max" choice:"max" choice:"sum" choice:"avg" description:"Aggregation of the values of --json-field"`
+	MultilineStart      string   `long:"multiline-start-pattern" value-name:"PAT" description:"Pattern of the first line of a log entry. The following lines not matching it are joined to the entry, and the entry is matched with the patterns"`
 	patternReg          []*regexp.Regexp
-	excludeReg          *regexp.Regexp
+	patternCounts       []int64
+	excludeReg          []*regexp.Regexp
+	excludedNum         int64
+	windowNum           int64
+	jsonAgg             *jsonAggregator
+	multilineReg        *regexp.Regexp
 	fileListFromGlob    []string
 	fileListFromPattern []string
 	origArgs            []string
@@ -70,15 +88,25 @@ func (opts *logOpts) prepare() error {
 		opts.patternReg = append(opts.patternReg, reg)
 	}
 
+	opts.patternCounts = make([]int64, len(opts.patternReg))
+
+	if len(opts.PatternName) > len(opts.Pattern) {
+		return fmt.Errorf("--pattern-name is specified more than --pattern")
+	}
+
 	if len(opts.patternReg) > 1 && (opts.WarnLevel > 0 || opts.CritLevel > 0) {
 		return fmt.Errorf("When multiple patterns specified, --warning-level --critical-level can not be used")
 	}
 
+	excludes := opts.ExcludePattern
 	if opts.Exclude != "" {
-		opts.excludeReg, err = regCompileWithCase(opts.Exclude, opts.CaseInsensitive)
-		if err != nil {
+		excludes = append([]string{opts.Exclude}, excludes...)
+	}
+	for _, ptn := range excludes {
+		if reg, err = regCompileWithCase(ptn, opts.CaseInsensitive); err != nil {
 			return fmt.Errorf("exclude pattern is invalid")
 		}
+		opts.excludeReg = append(opts.excludeReg, reg)
 	}
 
 	if opts.LogFile != "" {
@@ -112,6 +140,23 @@ func (opts *logOpts) prepare() error {
 			}
 		}
 	}
+	if opts.MultilineStart != "" {
+		opts.multilineReg, err = regCompileWithCase(opts.MultilineStart, opts.CaseInsensitive)
+		if err != nil {
+			return fmt.Errorf("multiline start pattern is invalid")
+		}
+	}
+
+	if opts.JSONField != "" {
+		if opts.WarnLevel > 0 || opts.CritLevel > 0 || opts.TimeWindow > 0 {
+			return fmt.Errorf("--json-field can not be used with --warning-level --critical-level --time-window")
+		}
+		opts.jsonAgg = newJSONAggregator(opts.JSONField)
+	}
+
+	if opts.TimeWindow > 0 && opts.NoState {
+		return fmt.Errorf("--time-window can not be used with --no-state")
+	}
 	if !validateMissing(opts.Missing) {
 		return fmt.Errorf("missing option is invalid")
 	}
@@ -179,12 +224,13 @@ func run(ctx context.Context, args []string) *checkers.Checker {
 	critNum := int64(0)
 	var missingFiles []string
 	errorOverall := ""
+	outputLines, omittedLines := 0, 0
 
 	if opts.LogFile != "" && len(opts.fileListFromGlob) == 0 {
 		missingFiles = append(missingFiles, opts.LogFile)
 	}
 
-	for _, f := range append(opts.fileListFromGlob, opts.fileListFromPattern...) {
+	for _, f := range sortByModTime(append(opts.fileListFromGlob, opts.fileListFromPattern...)) {
 		if ctx.Err() != nil {
 			break
 		}
@@ -200,20 +246,58 @@ func run(ctx context.Context, args []string) *checkers.Checker {
 		warnNum += w
 		critNum += c
 		if opts.ReturnContent && errLines != "" {
-			errorOverall += "[" + f + "]\n" + errLines
+			if opts.MaxOutputLines > 0 {
+				var n int
+				errLines, n = truncateLines(errLines, opts.MaxOutputLines-outputLines)
+				omittedLines += n
+			}
+			if errLines != "" {
+				outputLines += strings.Count(errLines, "\n")
+				errorOverall += "[" + f + "]\n" + errLines
+			}
 		}
 	}
+	if omittedLines > 0 {
+		errorOverall += fmt.Sprintf("... and %d more lines\n", omittedLines)
+	}
 
 	var patterns []string
 	for _, ptn := range opts.Pattern {
 		patterns = append(patterns, fmt.Sprintf("/%s/", ptn))
 	}
+	operator := " or "
+	if opts.AllPatterns {
+		operator = " and "
+	}
 	var msg string
 	if opts.SuppressPattern {
-		msg = fmt.Sprintf("%d warnings, %d criticals.", warnNum, critNum)
+		msg = fmt.Sprintf("%d warnings, %d criticals", warnNum, critNum)
 	} else {
-		msg = fmt.Sprintf("%d warnings, %d criticals for pattern %s.", warnNum, critNum, strings.Join(patterns, " and "))
+		msg = fmt.Sprintf("%d warnings, %d criticals for pattern %s", warnNum, critNum, strings.Join(patterns, operator))
+	}
+	if (len(patterns) > 1 && !opts.AllPatterns) || len(opts.PatternName) > 0 {
+		var counts []string
+		for i, n := range opts.patternCounts {
+			label := patterns[i]
+			if i < len(opts.PatternName) {
+				label = opts.PatternName[i]
+			} else if opts.SuppressPattern {
+				label = fmt.Sprintf("#%d", i+1)
+			}
+			counts = append(counts, fmt.Sprintf("%s: %d", label, n))
+		}
+		msg += " (" + strings.Join(counts, ", ") + ")"
 	}
+	if len(opts.excludeReg) > 0 {
+		msg += fmt.Sprintf(", %d lines excluded", opts.excludedNum)
+	}
+	if opts.TimeWindow > 0 {
+		msg += fmt.Sprintf(", %d lines in the last %d seconds", opts.windowNum, opts.TimeWindow)
+	}
+	if opts.jsonAgg != nil {
+		msg += ", " + opts.jsonAgg.message(opts.JSONAgg)
+	}
+	msg += "."
 	if errorOverall != "" {
 		msg += "\n" + errorOverall
 	}
@@ -233,21 +317,66 @@ func run(ctx context.Context, args []string) *checkers.Checker {
 			msg += "\n" + f
 		}
 	}
-	if warnNum > opts.WarnOver {
-		checkSt = checkers.WARNING
-	}
-	if critNum > opts.CritOver {
-		checkSt = checkers.CRITICAL
+	if opts.jsonAgg != nil {
+		value := opts.jsonAgg.value(opts.JSONAgg)
+		if value > float64(opts.WarnOver) {
+			checkSt = checkers.WARNING
+		}
+		if value > float64(opts.CritOver) {
+			checkSt = checkers.CRITICAL
+		}
+	} else if opts.TimeWindow > 0 {
+		if opts.windowNum > opts.WindowWarn {
+			checkSt = checkers.WARNING
+		}
+		if opts.windowNum > opts.WindowCrit {
+			checkSt = checkers.CRITICAL
+		}
+	} else {
+		if warnNum > opts.WarnOver {
+			checkSt = checkers.WARNING
+		}
+		if critNum > opts.CritOver {
+			checkSt = checkers.CRITICAL
+		}
 	}
 	return checkers.NewChecker(checkSt, msg)
 }
 
+// sortByModTime sorts the files in chronological order of the modification
+// time, so that rotated files are checked before the current one
+func sortByModTime(files []string) []string {
+	modTimes := make(map[string]time.Time, len(files))
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			modTimes[f] = fi.ModTime()
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return modTimes[files[i]].Before(modTimes[files[j]])
+	})
+	return files
+}
+
+// truncateLines returns the first max lines of s and the number of the rest
+func truncateLines(s string, max int) (string, int) {
+	lines := strings.SplitAfter(strings.TrimSuffix(s, "\n"), "\n")
+	if max < 0 {
+		max = 0
+	}
+	if len(lines) <= max {
+		return s, 0
+	}
+	return strings.Join(lines[:max], ""), len(lines) - max
+}
+
 func (opts *logOpts) searchLog(ctx context.Context, logFile string) (int64, int64, string, error) {
 	if ctx.Err() != nil {
 		return 0, 0, "", nil
 	}
 	stateFile := getStateFile(opts.StateDir, logFile, opts.origArgs)
 	skipBytes, inode := int64(0), uint(0)
+	var timestamps []int64
 	if !opts.NoState {
 		s, err := getBytesToSkip(stateFile)
 		if err != nil {
@@ -260,6 +389,16 @@ func (opts *logOpts) searchLog(ctx context.Context, logFile string) (int64, int6
 			return 0, 0, "", err
 		}
 		inode = i
+
+		if opts.TimeWindow > 0 {
+			s, err := loadState(stateFile)
+			if err != nil {
+				return 0, 0, "", err
+			}
+			if s != nil {
+				timestamps = s.Timestamps
+			}
+		}
 	}
 
 	f, err := os.Open(logFile)
@@ -268,8 +407,12 @@ func (opts *logOpts) searchLog(ctx context.Context, logFile string) (int64, int6
 	}
 	defer f.Close()
 
+	// the offset of a gzip compressed file is in the decompressed stream,
+	// which can't be compared with the file size nor sought
+	isGzip := strings.HasSuffix(logFile, ".gz")
+
 	var oldf *os.File
-	if !opts.NoState {
+	if !opts.NoState && !isGzip {
 		oldf, err = openOldFile(logFile, &state{SkipBytes: skipBytes, Inode: inode})
 		if err != nil {
 			return 0, 0, "", err
@@ -282,20 +425,55 @@ func (opts *logOpts) searchLog(ctx context.Context, logFile string) (int64, int6
 		return 0, 0, "", err
 	}
 
+	skipAll := false
 	if !opts.NoState && !opts.CheckFirst {
 		if _, err = os.Stat(stateFile); os.IsNotExist(err) {
 			skipBytes = stat.Size()
+			skipAll = true
 		}
 	}
 
+	// the log file is rotated if the inode is changed, or truncated if it
+	// shrinks, and in both cases it should be read from the beginning
 	rotated := false
-	if stat.Size() < skipBytes {
+	if inode > 0 && detectInode(stat) != inode {
 		rotated = true
-	} else if skipBytes > 0 {
-		f.Seek(skipBytes, 0)
+	} else if !isGzip {
+		if stat.Size() < skipBytes {
+			rotated = true
+		} else if skipBytes > 0 {
+			f.Seek(skipBytes, 0)
+		}
 	}
 
 	var r io.Reader = f
+	if isGzip && stat.Size() > 0 {
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return 0, 0, "", fmt.Errorf("%s: %s", logFile, err)
+		}
+		defer gr.Close()
+		if skipAll {
+			n, err := io.Copy(ioutil.Discard, gr)
+			if err != nil {
+				return 0, 0, "", fmt.Errorf("%s: %s", logFile, err)
+			}
+			skipBytes = n
+		} else if !rotated && skipBytes > 0 {
+			_, err := io.CopyN(ioutil.Discard, gr, skipBytes)
+			if err == io.EOF {
+				// the file is replaced with a shorter one
+				f.Seek(0, io.SeekStart)
+				if err = gr.Reset(f); err != nil {
+					return 0, 0, "", fmt.Errorf("%s: %s", logFile, err)
+				}
+				rotated = true
+			} else if err != nil {
+				return 0, 0, "", fmt.Errorf("%s: %s", logFile, err)
+			}
+		}
+		r = gr
+	}
 	var oldr io.Reader = oldf
 	if opts.Encoding != "" {
 		e := encoding.GetEncoding(opts.Encoding)
@@ -305,7 +483,9 @@ func (opts *logOpts) searchLog(ctx context.Context, logFile string) (int64, int6
 		opts.decoder = e.NewDecoder()
 	}
 
-	warnNum, critNum, readBytes, errLines, err := opts.searchReader(ctx, r)
+	// the last entry is complete if the file is compressed, which is never
+	// appended, or if it will not be read again without the state
+	warnNum, critNum, readBytes, matchedNum, errLines, err := opts.searchEntries(ctx, r, isGzip || opts.NoState)
 	if err != nil {
 		return warnNum, critNum, errLines, err
 	}
@@ -313,16 +493,17 @@ func (opts *logOpts) searchLog(ctx context.Context, logFile string) (int64, int6
 	if oldf != nil {
 		// search old file
 		var (
-			oldWarnNum, oldCritNum int64
-			oldErrLines            string
+			oldWarnNum, oldCritNum, oldMatchedNum int64
+			oldErrLines                           string
 		)
 		// ignore readBytes under the premise that the old file will never be updated.
-		oldWarnNum, oldCritNum, _, oldErrLines, err := opts.searchReader(ctx, oldr)
+		oldWarnNum, oldCritNum, _, oldMatchedNum, oldErrLines, err := opts.searchEntries(ctx, oldr, true)
 		if err != nil {
 			return oldWarnNum, critNum, errLines, err
 		}
 		warnNum += oldWarnNum
 		critNum += oldCritNum
+		matchedNum += oldMatchedNum
 		errLines += oldErrLines
 	}
 
@@ -333,7 +514,12 @@ func (opts *logOpts) searchLog(ctx context.Context, logFile string) (int64, int6
 	}
 
 	if !opts.NoState {
-		err = saveState(stateFile, &state{SkipBytes: skipBytes, Inode: detectInode(stat)})
+		st := &state{SkipBytes: skipBytes, Inode: detectInode(stat)}
+		if opts.TimeWindow > 0 {
+			st.Timestamps = windowTimestamps(timestamps, int(matchedNum), time.Now(), opts.TimeWindow)
+			opts.windowNum += int64(len(st.Timestamps))
+		}
+		err = saveState(stateFile, st)
 		if err != nil {
 			log.Printf("writeByteToSkip failed: %s\n", err.Error())
 		}
@@ -346,35 +532,31 @@ func newBufferedReader(r io.Reader) *bufio.Reader {
 }
 
 func (opts *logOpts) searchReader(ctx context.Context, rdr io.Reader) (warnNum, critNum, readBytes int64, errLines string, err error) {
+	warnNum, critNum, readBytes, _, errLines, err = opts.searchEntries(ctx, rdr, false)
+	return
+}
+
+// searchEntries searches the lines, or the multiline entries with
+// --multiline-start-pattern, in rdr. The last multiline entry is searched
+// only if flush is true, because its continuation lines may be written
+// later. Otherwise readBytes excludes the entry to read it again in the next
+// check. matchedNum is the number of the entries added to errLines, which
+// differs from the number of the lines in errLines for multiline entries.
+func (opts *logOpts) searchEntries(ctx context.Context, rdr io.Reader, flush bool) (warnNum, critNum, readBytes, matchedNum int64, errLines string, err error) {
 	newReader := opts.testHookNewBufferedReader
 	if newReader == nil {
 		newReader = newBufferedReader
 	}
 
-	r := newReader(rdr)
-	for ctx.Err() == nil {
-		lineBytes, rErr := r.ReadBytes('\n')
-		if rErr != nil {
-			if rErr != io.EOF {
-				err = rErr
-			}
-			break
-		}
-		readBytes += int64(len(lineBytes))
-
-		if opts.decoder != nil {
-			lineBytes, err = opts.decoder.Bytes(lineBytes)
-			if err != nil {
-				break
-			}
-		}
-		line := strings.Trim(string(lineBytes), "\r\n")
-		if matched, matches := opts.match(line); matched {
+	search := func(line string) {
+		if matched, matches, hits := opts.match(line); matched {
+			counted := true
 			if len(matches) > 1 && (opts.WarnLevel > 0 || opts.CritLevel > 0) {
 				level, err := strconv.ParseFloat(matches[1], 64)
 				if err != nil {
 					warnNum++
 					critNum++
+					matchedNum++
 					errLines += line + "\n"
 				} else {
 					levelOver := false
@@ -387,35 +569,125 @@ func (opts *logOpts) searchReader(ctx context.Context, rdr io.Reader) (warnNum,
 						critNum++
 					}
 					if levelOver {
+						matchedNum++
 						errLines += line + "\n"
 					}
+					counted = levelOver
 				}
 			} else {
 				warnNum++
 				critNum++
+				matchedNum++
 				errLines += line + "\n"
 			}
+			if counted {
+				for _, i := range hits {
+					opts.patternCounts[i]++
+				}
+				if opts.jsonAgg != nil {
+					opts.jsonAgg.add(line)
+				}
+			}
 		}
 	}
+
+	var entry []string
+	var entryBytes int64
+	r := newReader(rdr)
+	for ctx.Err() == nil {
+		lineBytes, rErr := r.ReadBytes('\n')
+		if rErr != nil {
+			if rErr != io.EOF {
+				err = rErr
+			}
+			break
+		}
+		n := int64(len(lineBytes))
+		if opts.multilineReg == nil {
+			readBytes += n
+		}
+
+		if opts.decoder != nil {
+			lineBytes, err = opts.decoder.Bytes(lineBytes)
+			if err != nil {
+				break
+			}
+		}
+		line := strings.Trim(string(lineBytes), "\r\n")
+		if opts.multilineReg == nil {
+			search(line)
+			continue
+		}
+
+		if len(entry) > 0 && opts.multilineReg.MatchString(line) {
+			search(strings.Join(entry, "\n"))
+			readBytes += entryBytes
+			entry, entryBytes = nil, 0
+		}
+		entry = append(entry, line)
+		entryBytes += n
+	}
+	if flush && len(entry) > 0 && err == nil && ctx.Err() == nil {
+		search(strings.Join(entry, "\n"))
+		readBytes += entryBytes
+	}
 	return
 }
 
-func (opts *logOpts) match(line string) (bool, []string) {
+// match reports whether the line matches any (or all with --all-patterns)
+// of the patterns and none of the exclude patterns, with the submatches and
+// the indexes of matched patterns
+func (opts *logOpts) match(line string) (bool, []string, []int) {
 	var matches []string
-	for _, pReg := range opts.patternReg {
-		eReg := opts.excludeReg
-
-		matches = pReg.FindStringSubmatch(line)
-		if len(matches) == 0 || (eReg != nil && eReg.MatchString(line)) {
-			return false, nil
+	var hits []int
+	for i, pReg := range opts.patternReg {
+		m := pReg.FindStringSubmatch(line)
+		if len(m) == 0 {
+			if opts.AllPatterns {
+				return false, nil, nil
+			}
+			continue
 		}
+		matches = m
+		hits = append(hits, i)
 	}
-	return true, matches
+	if len(hits) == 0 {
+		return false, nil, nil
+	}
+	for _, eReg := range opts.excludeReg {
+		if eReg.MatchString(line) {
+			opts.excludedNum++
+			return false, nil, nil
+		}
+	}
+	return true, matches, hits
 }
 
 type state struct {
-	SkipBytes int64 `json:"skip_bytes"`
-	Inode     uint  `json:"inode"`
+	SkipBytes  int64   `json:"skip_bytes"`
+	Inode      uint    `json:"inode"`
+	Timestamps []int64 `json:"timestamps,omitempty"`
+}
+
+// maxWindowTimestamps limits the timestamps kept in a state file
+const maxWindowTimestamps = 100000
+
+// windowTimestamps appends the time of matched lines to timestamps (unix
+// time in milliseconds) and drops the ones older than the window
+func windowTimestamps(timestamps []int64, matched int, now time.Time, window int64) []int64 {
+	nowMsec := now.UnixNano() / int64(time.Millisecond)
+	for i := 0; i < matched; i++ {
+		timestamps = append(timestamps, nowMsec)
+	}
+	since := nowMsec - window*1000
+	i := 0
+	for i < len(timestamps) && timestamps[i] <= since {
+		i++
+	}
+	if len(timestamps)-i > maxWindowTimestamps {
+		i = len(timestamps) - maxWindowTimestamps
+	}
+	return timestamps[i:]
 }
 
 func loadState(fname string) (*state, error) {
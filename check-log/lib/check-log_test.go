@@ -2,6 +2,7 @@ package checklog
 
 import (
 	"bufio"
+	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
@@ -522,6 +523,7 @@ func TestSearchReaderWithLevel(t *testing.T) {
 		WarnLevel:       11,
 		CritLevel:       17,
 		Missing:         "UNKNOWN",
+		JSONAgg:         "max",
 		origArgs:        origArgs,
 	}, opts) {
 		t.Errorf("something went wrong: %#v", opts)
@@ -751,7 +753,7 @@ func TestRunMultiplePattern(t *testing.T) {
 
 	ptn1 := `FATAL`
 	ptn2 := `TESTAPPLICATION`
-	params := []string{"-s", dir, "-f", logf, "-p", ptn1, "-p", ptn2}
+	params := []string{"-s", dir, "-f", logf, "-p", ptn1, "-p", ptn2, "--all-patterns"}
 	opts, _ := parseArgs(params)
 	opts.prepare()
 
@@ -822,7 +824,7 @@ func TestRunWithSuppressOption(t *testing.T) {
 
 	ptn1 := `FATAL`
 	ptn2 := `TESTAPPLICATION`
-	params := []string{"-s", dir, "-f", logf, "-p", ptn1, "-p", ptn2, "--suppress-pattern"}
+	params := []string{"-s", dir, "-f", logf, "-p", ptn1, "-p", ptn2, "--all-patterns", "--suppress-pattern"}
 	opts, _ := parseArgs(params)
 	opts.prepare()
 
@@ -879,3 +881,317 @@ func TestRunWithSuppressOption(t *testing.T) {
 	}
 	testInvalidPattern()
 }
+
+func TestRunWithMaxOutputLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check-log-test")
+	if err != nil {
+		t.Errorf("something went wrong")
+	}
+	defer os.RemoveAll(dir)
+
+	logf := filepath.Join(dir, "dummy")
+	fh, _ := os.Create(logf)
+	defer fh.Close()
+
+	params := []string{"-s", dir, "-f", logf, "-p", "FATAL", "-r", "--max-output-lines", "2", "--check-first"}
+	fh.WriteString("FATAL 1\nOK\nFATAL 2\nFATAL 3\nFATAL 4\n")
+
+	ckr := run(context.Background(), params)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status, "ckr.Status should be CRITICAL")
+	msg := "4 warnings, 4 criticals for pattern /FATAL/.\n[" + logf + "]\nFATAL 1\nFATAL 2\n... and 2 more lines\n"
+	assert.Equal(t, msg, ckr.Message, "matched lines should be capped")
+
+	fh.WriteString("FATAL 5\n")
+	ckr = run(context.Background(), params)
+	msg = "1 warnings, 1 criticals for pattern /FATAL/.\n[" + logf + "]\nFATAL 5\n"
+	assert.Equal(t, msg, ckr.Message, "matched lines under the cap should not be omitted")
+}
+
+func TestTruncateLines(t *testing.T) {
+	s, n := truncateLines("a\nb\nc\n", 2)
+	assert.Equal(t, "a\nb\n", s)
+	assert.Equal(t, 1, n)
+
+	s, n = truncateLines("a\nb\n", 2)
+	assert.Equal(t, "a\nb\n", s)
+	assert.Equal(t, 0, n)
+
+	s, n = truncateLines("a\nb\n", -1)
+	assert.Equal(t, "", s)
+	assert.Equal(t, 2, n)
+}
+
+func TestRunMultiplePatternWithOr(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check-log-test")
+	if err != nil {
+		t.Errorf("something went wrong")
+	}
+	defer os.RemoveAll(dir)
+
+	logf := filepath.Join(dir, "dummy")
+	fh, _ := os.Create(logf)
+	defer fh.Close()
+
+	l1 := "FATAL\nERROR\nOK\nFATAL ERROR\n"
+	fh.WriteString(l1)
+
+	params := []string{"-s", dir, "-f", logf, "-p", "FATAL", "-p", "ERROR", "--check-first", "--no-state"}
+	ckr := run(context.Background(), params)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status, "ckr.Status should be CRITICAL")
+	msg := "3 warnings, 3 criticals for pattern /FATAL/ or /ERROR/ (/FATAL/: 2, /ERROR/: 2)."
+	assert.Equal(t, msg, ckr.Message, "a line matching any pattern should be counted")
+
+	params = append(params, "--pattern-name", "fatal", "--pattern-name", "error")
+	ckr = run(context.Background(), params)
+	msg = "3 warnings, 3 criticals for pattern /FATAL/ or /ERROR/ (fatal: 2, error: 2)."
+	assert.Equal(t, msg, ckr.Message, "pattern names should be displayed")
+
+	params = []string{"-s", dir, "-f", logf, "-p", "FATAL", "-p", "ERROR", "--no-state", "--suppress-pattern", "--pattern-name", "fatal"}
+	ckr = run(context.Background(), params)
+	msg = "3 warnings, 3 criticals (fatal: 2, #2: 2)."
+	assert.Equal(t, msg, ckr.Message, "patterns should be suppressed")
+
+	params = []string{"-s", dir, "-f", logf, "-p", "FATAL", "--no-state", "--pattern-name", "fatal", "--pattern-name", "error"}
+	ckr = run(context.Background(), params)
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status, "ckr.Status should be UNKNOWN")
+	assert.Equal(t, "--pattern-name is specified more than --pattern", ckr.Message)
+}
+
+func TestRunWithExcludePattern(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check-log-test")
+	if err != nil {
+		t.Errorf("something went wrong")
+	}
+	defer os.RemoveAll(dir)
+
+	logf := filepath.Join(dir, "dummy")
+	fh, _ := os.Create(logf)
+	defer fh.Close()
+
+	l1 := "error occurred\nerror recovery successful\nerror ignored\nOK\n"
+	fh.WriteString(l1)
+
+	params := []string{"-s", dir, "-f", logf, "-p", "error", "--no-state", "--exclude-pattern", "recovery", "--exclude-pattern", "ignored"}
+	ckr := run(context.Background(), params)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status, "ckr.Status should be CRITICAL")
+	msg := "1 warnings, 1 criticals for pattern /error/, 2 lines excluded."
+	assert.Equal(t, msg, ckr.Message, "lines matching exclude patterns should not be counted")
+
+	params = []string{"-s", dir, "-f", logf, "-p", "error", "--no-state", "-E", "occurred", "--exclude-pattern", "recovery|ignored"}
+	ckr = run(context.Background(), params)
+	assert.Equal(t, checkers.OK, ckr.Status, "ckr.Status should be OK")
+	msg = "0 warnings, 0 criticals for pattern /error/, 3 lines excluded."
+	assert.Equal(t, msg, ckr.Message, "--exclude and --exclude-pattern should be used together")
+
+	params = []string{"-s", dir, "-f", logf, "-p", "error", "--no-state", "--exclude-pattern", "("}
+	ckr = run(context.Background(), params)
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status, "ckr.Status should be UNKNOWN")
+	assert.Equal(t, "exclude pattern is invalid", ckr.Message)
+}
+
+func TestWindowTimestamps(t *testing.T) {
+	now := time.Unix(1000, 500*int64(time.Millisecond))
+	ts := windowTimestamps([]int64{930000, 940500, 940600, 999000}, 2, now, 60)
+	assert.Equal(t, []int64{940600, 999000, 1000500, 1000500}, ts, "timestamps older than the window should be dropped")
+
+	ts = windowTimestamps(nil, 0, now, 60)
+	assert.Equal(t, 0, len(ts))
+}
+
+func TestRunWithTimeWindow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check-log-test")
+	if err != nil {
+		t.Errorf("something went wrong")
+	}
+	defer os.RemoveAll(dir)
+
+	logf := filepath.Join(dir, "dummy")
+	fh, _ := os.Create(logf)
+	defer fh.Close()
+
+	params := []string{"-s", dir, "-f", logf, "-p", "FATAL", "--check-first", "--time-window", "300", "--window-warning", "1", "--window-critical", "2"}
+	opts, _ := parseArgs(params)
+	stateFile := getStateFile(opts.StateDir, logf, opts.origArgs)
+
+	fh.WriteString("FATAL\nFATAL\nOK\n")
+	ckr := run(context.Background(), params)
+	assert.Equal(t, checkers.WARNING, ckr.Status, "ckr.Status should be WARNING")
+	assert.Equal(t, "2 warnings, 2 criticals for pattern /FATAL/, 2 lines in the last 300 seconds.", ckr.Message)
+
+	fh.WriteString("FATAL\n")
+	ckr = run(context.Background(), params)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status, "matched lines in the previous checks should be counted")
+	assert.Equal(t, "1 warnings, 1 criticals for pattern /FATAL/, 3 lines in the last 300 seconds.", ckr.Message)
+
+	// make the matched lines out of the window
+	s, _ := loadState(stateFile)
+	for i := range s.Timestamps {
+		s.Timestamps[i] -= 301 * 1000
+	}
+	saveState(stateFile, s)
+
+	ckr = run(context.Background(), params)
+	assert.Equal(t, checkers.OK, ckr.Status, "ckr.Status should be OK")
+	assert.Equal(t, "0 warnings, 0 criticals for pattern /FATAL/, 0 lines in the last 300 seconds.", ckr.Message)
+
+	ckr = run(context.Background(), append(params, "--no-state"))
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status, "ckr.Status should be UNKNOWN")
+	assert.Equal(t, "--time-window can not be used with --no-state", ckr.Message)
+}
+
+func TestRunWithTimeWindowMultiline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check-log-test")
+	if err != nil {
+		t.Errorf("something went wrong")
+	}
+	defer os.RemoveAll(dir)
+
+	logf := filepath.Join(dir, "dummy")
+	fh, _ := os.Create(logf)
+	defer fh.Close()
+
+	params := []string{"-s", dir, "-f", logf, "-p", "NullPointerException", "--check-first", "--multiline-start-pattern", `^\d{4}-\d{2}-\d{2} `,
+		"--time-window", "300", "--window-warning", "1", "--window-critical", "2"}
+
+	fh.WriteString("2018-04-01 12:00:00 ERROR java.lang.NullPointerException\n\tat Foo.bar(Foo.java:10)\n\tat Foo.main(Foo.java:3)\n2018-04-01 12:00:01 INFO ok\n")
+	ckr := run(context.Background(), params)
+	assert.Equal(t, checkers.OK, ckr.Status, "a multiline entry should be counted once in the window")
+	assert.Equal(t, "1 warnings, 1 criticals for pattern /NullPointerException/, 1 lines in the last 300 seconds.", ckr.Message)
+}
+
+func TestRunWithGzip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check-log-test")
+	if err != nil {
+		t.Errorf("something went wrong")
+	}
+	defer os.RemoveAll(dir)
+
+	writeGzip := func(name, content string, mtime time.Time) {
+		fh, _ := os.Create(name)
+		gw := gzip.NewWriter(fh)
+		gw.Write([]byte(content))
+		gw.Close()
+		fh.Close()
+		os.Chtimes(name, mtime, mtime)
+	}
+
+	now := time.Now()
+	gzf := filepath.Join(dir, "app.log.2.gz")
+	writeGzip(gzf, "FATAL 1\nOK\n", now.Add(-2*time.Hour))
+	logf := filepath.Join(dir, "app.log")
+	ioutil.WriteFile(logf, []byte("FATAL 3\n"), 0644)
+	gzf2 := filepath.Join(dir, "app.log.1.gz")
+	writeGzip(gzf2, "FATAL 2\n", now.Add(-1*time.Hour))
+
+	params := []string{"-s", dir, "--file", filepath.Join(dir, "app.log*"), "-p", "FATAL", "-r", "--check-first"}
+	ckr := run(context.Background(), params)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status, "ckr.Status should be CRITICAL")
+	msg := "3 warnings, 3 criticals for pattern /FATAL/.\n[" + gzf + "]\nFATAL 1\n[" + gzf2 + "]\nFATAL 2\n[" + logf + "]\nFATAL 3\n"
+	assert.Equal(t, msg, ckr.Message, "files should be checked in order of the modification time")
+
+	opts, _ := parseArgs(params)
+	bytes, _ := getBytesToSkip(getStateFile(opts.StateDir, gzf, opts.origArgs))
+	assert.Equal(t, int64(len("FATAL 1\nOK\n")), bytes, "the offset of gzip should be in the decompressed stream")
+
+	ckr = run(context.Background(), params)
+	assert.Equal(t, checkers.OK, ckr.Status, "ckr.Status should be OK")
+
+	// replaced with a shorter file
+	writeGzip(gzf, "FATAL 4\n", now)
+	ckr = run(context.Background(), params)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status, "ckr.Status should be CRITICAL")
+	msg = "1 warnings, 1 criticals for pattern /FATAL/.\n[" + gzf + "]\nFATAL 4\n"
+	assert.Equal(t, msg, ckr.Message, "something went wrong")
+
+	// without --check-first, the existing content is skipped on the first run
+	params = []string{"-s", dir, "--file", gzf2, "-p", "FATAL"}
+	ckr = run(context.Background(), params)
+	assert.Equal(t, checkers.OK, ckr.Status, "ckr.Status should be OK")
+	opts, _ = parseArgs(params)
+	bytes, _ = getBytesToSkip(getStateFile(opts.StateDir, gzf2, opts.origArgs))
+	assert.Equal(t, int64(len("FATAL 2\n")), bytes, "something went wrong")
+}
+
+func TestRunWithJSONField(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check-log-test")
+	if err != nil {
+		t.Errorf("something went wrong")
+	}
+	defer os.RemoveAll(dir)
+
+	logf := filepath.Join(dir, "dummy")
+	content := `{"level":"error","response":{"time_ms":120}}
+{"level":"info","response":{"time_ms":5000}}
+{"level":"error","response":{"time_ms":"300"}}
+{"level":"error","response":{}}
+"level":"error" in a plain text
+{"level":"error","response":{"time_ms":180}}
+`
+	ioutil.WriteFile(logf, []byte(content), 0644)
+
+	params := []string{"-s", dir, "-f", logf, "-p", `level":"error"`, "--no-state", "--json-field", "response.time_ms", "-w", "250", "-c", "500"}
+	ckr := run(context.Background(), params)
+	assert.Equal(t, checkers.WARNING, ckr.Status, "ckr.Status should be WARNING")
+	msg := `5 warnings, 5 criticals for pattern /level":"error"/, max of response.time_ms: 300 (3 values, 2 lines skipped).`
+	assert.Equal(t, msg, ckr.Message, "something went wrong")
+
+	ckr = run(context.Background(), append(params, "--json-agg", "avg"))
+	assert.Equal(t, checkers.OK, ckr.Status, "ckr.Status should be OK")
+	assert.Contains(t, ckr.Message, "avg of response.time_ms: 200 (3 values, 2 lines skipped)")
+
+	ckr = run(context.Background(), append(params, "--json-agg", "sum"))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status, "ckr.Status should be CRITICAL")
+	assert.Contains(t, ckr.Message, "sum of response.time_ms: 600 (3 values, 2 lines skipped)")
+
+	ckr = run(context.Background(), append(params, "--warning-level", "1"))
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status, "ckr.Status should be UNKNOWN")
+	assert.Equal(t, "--json-field can not be used with --warning-level --critical-level --time-window", ckr.Message)
+}
+
+func TestRunWithMultiline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check-log-test")
+	if err != nil {
+		t.Errorf("something went wrong")
+	}
+	defer os.RemoveAll(dir)
+
+	logf := filepath.Join(dir, "dummy")
+	fh, _ := os.Create(logf)
+	defer fh.Close()
+
+	params := []string{"-s", dir, "-f", logf, "-p", "NullPointerException", "-r", "--check-first", "--multiline-start-pattern", `^\d{4}-\d{2}-\d{2} `}
+	opts, _ := parseArgs(params)
+	stateFile := getStateFile(opts.StateDir, logf, opts.origArgs)
+
+	l1 := "2018-01-01 00:00:00 ERROR request failed\n" +
+		"java.lang.NullPointerException\n" +
+		"\tat Main.main(Main.java:1)\n" +
+		"2018-01-01 00:00:01 INFO ok\n" +
+		"2018-01-01 00:00:02 ERROR request failed\n" +
+		"java.lang.NullPointerException\n"
+	fh.WriteString(l1)
+	ckr := run(context.Background(), params)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status, "ckr.Status should be CRITICAL")
+	msg := "1 warnings, 1 criticals for pattern /NullPointerException/.\n[" + logf + "]\n" +
+		"2018-01-01 00:00:00 ERROR request failed\njava.lang.NullPointerException\n\tat Main.main(Main.java:1)\n"
+	assert.Equal(t, msg, ckr.Message, "the last entry should not be searched until the next entry starts")
+
+	bytes, _ := getBytesToSkip(stateFile)
+	assert.Equal(t, int64(strings.Index(l1, "2018-01-01 00:00:02")), bytes, "the offset should be the start of the last entry")
+
+	l2 := "\tat Main.main(Main.java:2)\n" +
+		"2018-01-01 00:00:03 INFO ok\n"
+	fh.WriteString(l2)
+	ckr = run(context.Background(), params)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status, "ckr.Status should be CRITICAL")
+	msg = "1 warnings, 1 criticals for pattern /NullPointerException/.\n[" + logf + "]\n" +
+		"2018-01-01 00:00:02 ERROR request failed\njava.lang.NullPointerException\n\tat Main.main(Main.java:2)\n"
+	assert.Equal(t, msg, ckr.Message, "the entry should include the lines written after the previous check")
+
+	ckr = run(context.Background(), []string{"-s", dir, "-f", logf, "-p", "NullPointerException", "--no-state", "--multiline-start-pattern", `^\d{4}-\d{2}-\d{2} `})
+	assert.Equal(t, "2 warnings, 2 criticals for pattern /NullPointerException/.", ckr.Message, "entries should be counted instead of lines")
+
+	ckr = run(context.Background(), []string{"-s", dir, "-f", logf, "-p", "NullPointerException", "--multiline-start-pattern", "("})
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status, "ckr.Status should be UNKNOWN")
+	assert.Equal(t, "multiline start pattern is invalid", ckr.Message)
+}
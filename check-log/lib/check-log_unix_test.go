@@ -187,4 +187,67 @@ func TestRunTraceInode(t *testing.T) {
 		os.Remove(rotatedLogf)
 	}
 	testRotateDifferentDir()
+
+	// case of the new file growing larger than the offset of the old file
+	// before the check
+	testRotateLargerFile := func() {
+		// first check
+		fh.WriteString(l1)
+		opts.searchLog(context.Background(), logf)
+		fh.Close()
+
+		// logrotate
+		rotatedLogf := filepath.Join(dir, "dummy.1")
+		os.Rename(logf, rotatedLogf)
+		fh, _ = os.Create(logf)
+
+		l4 := "FATAL in new file\nSUCCESS\n"
+		fh.WriteString(l4)
+		// second check
+		w, c, errLines, err := opts.searchLog(context.Background(), logf)
+		assert.Equal(t, err, nil, "err should be nil")
+		assert.Equal(t, int64(1), w, "the new file should be read from the beginning")
+		assert.Equal(t, int64(1), c, "the new file should be read from the beginning")
+		assert.Equal(t, "FATAL in new file\n", errLines, "something went wrong")
+
+		bytes, _ = getBytesToSkip(stateFile)
+		assert.Equal(t, int64(len(l4)), bytes, "should not include oldfile skip bytes")
+
+		os.Remove(rotatedLogf)
+	}
+	testRotateLargerFile()
+}
+
+func TestRunTruncated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check-log-test")
+	if err != nil {
+		t.Errorf("something went wrong")
+	}
+	defer os.RemoveAll(dir)
+
+	logf := filepath.Join(dir, "dummy")
+	fh, _ := os.Create(logf)
+	defer fh.Close()
+
+	opts, _ := parseArgs([]string{"-s", dir, "-f", logf, "-p", `FATAL`})
+	opts.prepare()
+	stateFile := getStateFile(opts.StateDir, logf, opts.origArgs)
+
+	fh.WriteString("SUCCESS\nSUCCESS\n")
+	opts.searchLog(context.Background(), logf)
+
+	// truncate in place, which keeps the inode
+	fh.Truncate(0)
+	fh.Seek(0, 0)
+	l1 := "FATAL\n"
+	fh.WriteString(l1)
+
+	w, c, errLines, err := opts.searchLog(context.Background(), logf)
+	assert.Equal(t, err, nil, "err should be nil")
+	assert.Equal(t, int64(1), w, "the truncated file should be read from the beginning")
+	assert.Equal(t, int64(1), c, "the truncated file should be read from the beginning")
+	assert.Equal(t, "FATAL\n", errLines, "something went wrong")
+
+	bytes, _ := getBytesToSkip(stateFile)
+	assert.Equal(t, int64(len(l1)), bytes, "something went wrong")
 }
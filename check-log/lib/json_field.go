@@ -0,0 +1,83 @@
+package checklog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonAggregator aggregates the numeric values of a field in JSON lines
+type jsonAggregator struct {
+	path    []string
+	sum     float64
+	max     float64
+	num     int64
+	skipped int64
+}
+
+func newJSONAggregator(field string) *jsonAggregator {
+	return &jsonAggregator{path: strings.Split(field, ".")}
+}
+
+// add extracts the value of the field from the line, and counts the line as
+// skipped if it is not a JSON object or the field is not a number
+func (a *jsonAggregator) add(line string) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(line), &v); err != nil {
+		a.skipped++
+		return
+	}
+	for _, key := range a.path {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			a.skipped++
+			return
+		}
+		if v, ok = obj[key]; !ok {
+			a.skipped++
+			return
+		}
+	}
+
+	var value float64
+	switch x := v.(type) {
+	case float64:
+		value = x
+	case string:
+		f, err := strconv.ParseFloat(x, 64)
+		if err != nil {
+			a.skipped++
+			return
+		}
+		value = f
+	default:
+		a.skipped++
+		return
+	}
+
+	if a.num == 0 || value > a.max {
+		a.max = value
+	}
+	a.sum += value
+	a.num++
+}
+
+func (a *jsonAggregator) value(agg string) float64 {
+	switch agg {
+	case "sum":
+		return a.sum
+	case "avg":
+		if a.num == 0 {
+			return 0
+		}
+		return a.sum / float64(a.num)
+	default:
+		return a.max
+	}
+}
+
+func (a *jsonAggregator) message(agg string) string {
+	return fmt.Sprintf("%s of %s: %s (%d values, %d lines skipped)",
+		agg, strings.Join(a.path, "."), strconv.FormatFloat(a.value(agg), 'f', -1, 64), a.num, a.skipped)
+}
@@ -1,19 +1,35 @@
 package checkmemcached
 
 import (
+	"bufio"
+	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/jessevdk/go-flags"
 	"github.com/mackerelio/checkers"
+	"github.com/mackerelio/go-check-plugins/check-file-size/lib"
 )
 
-var opts struct {
-	Host    string `short:"H" long:"host" default:"localhost" description:"Hostname"`
-	Port    string `short:"p" long:"port" default:"11211" description:"Port"`
-	Timeout uint64 `short:"t" long:"timeout" default:"3" description:"Dial Timeout in sec"`
-	Key     string `short:"k" long:"key" required:"true" description:"Cache key used within set and get test"`
+type memcachedOpts struct {
+	Host          string        `short:"H" long:"host" default:"127.0.0.1" description:"Hostname"`
+	Port          string        `short:"p" long:"port" default:"11211" description:"Port"`
+	Timeout       uint64        `short:"t" long:"timeout" default:"3" description:"Dial Timeout in sec"`
+	Key           string        `short:"k" long:"key" description:"Cache key used within set and get test"`
+	Warn          float64       `short:"w" long:"warning" value-name:"MSEC" description:"warning if the response time of stats is over (ms)"`
+	Crit          float64       `short:"c" long:"critical" value-name:"MSEC" description:"critical if the response time of stats is over (ms)"`
+	CheckHitRatio bool          `long:"check-hit-ratio" description:"check the hit ratio of get commands"`
+	HitRatioWarn  float64       `long:"hit-ratio-warning" value-name:"PERCENT" default:"90" description:"warning if the hit ratio is under (with --check-hit-ratio)"`
+	HitRatioCrit  float64       `long:"hit-ratio-critical" value-name:"PERCENT" default:"80" description:"critical if the hit ratio is under (with --check-hit-ratio)"`
+	EvictionWarn  *float64      `long:"eviction-warning" value-name:"PER_SEC" default:"0" description:"warning if the evictions per second are over"`
+	EvictionCrit  *float64      `long:"eviction-critical" value-name:"PER_SEC" description:"critical if the evictions per second are over"`
+	Interval      time.Duration `long:"sample-interval" default:"1s" description:"interval between the two stats to compute the eviction rate"`
+	MemoryWarn    *float64      `long:"memory-warning" value-name:"PERCENT" description:"warning if bytes are over the percentage of limit_maxbytes"`
+	MemoryCrit    *float64      `long:"memory-critical" value-name:"PERCENT" description:"critical if bytes are over the percentage of limit_maxbytes"`
 }
 
 // Do the plugin
@@ -23,26 +39,143 @@ func Do() {
 	ckr.Exit()
 }
 
+// getStats sends the stats command and returns the STAT lines as a map
+func getStats(addr string, timeout time.Duration) (map[string]string, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.Write([]byte("stats\r\n")); err != nil {
+		return nil, err
+	}
+	stats := make(map[string]string)
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "END" {
+			return stats, nil
+		}
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 || fields[0] != "STAT" {
+			return nil, fmt.Errorf("unexpected response: %s", line)
+		}
+		stats[fields[1]] = fields[2]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("unexpected EOF")
+}
+
+// hitRatio returns get_hits / (get_hits + get_misses) in percent, which is
+// 100 if there are no get commands
+func hitRatio(stats map[string]string) float64 {
+	hits, _ := strconv.ParseFloat(stats["get_hits"], 64)
+	misses, _ := strconv.ParseFloat(stats["get_misses"], 64)
+	if hits+misses == 0 {
+		return 100
+	}
+	return hits / (hits + misses) * 100
+}
+
+// evictionRate returns the evictions per second between the two stats
+func evictionRate(prev, cur map[string]string, interval time.Duration) float64 {
+	if interval <= 0 {
+		return 0
+	}
+	p, _ := strconv.ParseFloat(prev["evictions"], 64)
+	c, _ := strconv.ParseFloat(cur["evictions"], 64)
+	return (c - p) / interval.Seconds()
+}
+
+func checkSetGet(addr, key string, timeout time.Duration) error {
+	mc := memcache.New(addr)
+	mc.Timeout = timeout
+
+	err := mc.Set(&memcache.Item{Key: key, Value: []byte("Check key"), Expiration: 240})
+	if err != nil {
+		return fmt.Errorf("couldn't set a key: %s", err)
+	}
+
+	item, err := mc.Get(key)
+	if err != nil {
+		return fmt.Errorf("couldn't get a key: %s", err)
+	}
+	if string(item.Value) != "Check key" {
+		return fmt.Errorf("not correct value")
+	}
+	return nil
+}
+
 func run(args []string) *checkers.Checker {
+	opts := memcachedOpts{}
 	_, err := flags.ParseArgs(&opts, args)
 	if err != nil {
 		os.Exit(1)
 	}
 
-	mc := memcache.New(opts.Host + ":" + opts.Port)
-	mc.Timeout = time.Duration(opts.Timeout) * time.Second
+	addr := net.JoinHostPort(opts.Host, opts.Port)
+	timeout := time.Duration(opts.Timeout) * time.Second
 
-	err = mc.Set(&memcache.Item{Key: opts.Key, Value: []byte("Check key"), Expiration: 240})
+	start := time.Now()
+	prev, err := getStats(addr, timeout)
 	if err != nil {
-		return checkers.Critical("couldn't set a key: " + err.Error())
+		return checkers.Critical("couldn't get stats: " + err.Error())
 	}
+	elapsed := float64(time.Since(start)) / float64(time.Millisecond)
 
-	item, err := mc.Get(opts.Key)
+	time.Sleep(opts.Interval)
+	stats, err := getStats(addr, timeout)
 	if err != nil {
-		return checkers.Critical("couldn't get a key: " + err.Error())
+		return checkers.Critical("couldn't get stats: " + err.Error())
 	}
-	if string(item.Value) != "Check key" {
-		return checkers.Critical("not correct value")
+
+	checkSt := checkers.OK
+	// raise makes the status worse according to the thresholds
+	raise := func(critical, warning bool) {
+		if critical {
+			checkSt = checkers.CRITICAL
+		} else if warning && checkSt == checkers.OK {
+			checkSt = checkers.WARNING
+		}
+	}
+	over := func(v float64, threshold *float64) bool {
+		return threshold != nil && v > *threshold
+	}
+
+	raise(opts.Crit > 0 && elapsed > opts.Crit, opts.Warn > 0 && elapsed > opts.Warn)
+
+	used, _ := strconv.ParseFloat(stats["bytes"], 64)
+	limit, _ := strconv.ParseFloat(stats["limit_maxbytes"], 64)
+	usage := 0.0
+	if limit > 0 {
+		usage = used / limit * 100
+	}
+	raise(over(usage, opts.MemoryCrit), over(usage, opts.MemoryWarn))
+
+	rate := evictionRate(prev, stats, opts.Interval)
+	raise(over(rate, opts.EvictionCrit), over(rate, opts.EvictionWarn))
+
+	msgs := []string{
+		fmt.Sprintf("version %s, %s items, %s / %s used (%.2f%%), evictions %.2f/s",
+			stats["version"], stats["curr_items"], checkfilesize.HumanizeBytes(used), checkfilesize.HumanizeBytes(limit), usage, rate),
+	}
+
+	if opts.CheckHitRatio {
+		ratio := hitRatio(stats)
+		msgs = append(msgs, fmt.Sprintf("hit ratio %.2f%%", ratio))
+		raise(ratio < opts.HitRatioCrit, ratio < opts.HitRatioWarn)
+	}
+	msgs = append(msgs, fmt.Sprintf("stats in %.3f ms", elapsed))
+
+	if opts.Key != "" {
+		if err := checkSetGet(addr, opts.Key, timeout); err != nil {
+			return checkers.Critical(err.Error())
+		}
+		msgs = append(msgs, "Get,Set OK")
 	}
-	return checkers.Ok("Get,Set OK")
+	return checkers.NewChecker(checkSt, strings.Join(msgs, ", "))
 }
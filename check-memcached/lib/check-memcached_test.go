@@ -1,14 +1,18 @@
 package checkmemcached
 
 import (
+	"bufio"
 	"fmt"
 	"log"
+	"net"
 	"os/exec"
 	"syscall"
 	"testing"
 	"time"
 
 	"github.com/lestrrat/go-tcptest"
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestMemd(t *testing.T) {
@@ -40,3 +44,85 @@ func TestMemd(t *testing.T) {
 	cmd.Process.Signal(syscall.SIGTERM)
 	server.Wait()
 }
+
+// fakeStats serves the stats command with the STAT lines, which are given by
+// the function for each stats command
+func fakeStats(t *testing.T, stats func() string) (host, port string, closer func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == "stats\r\n" {
+						fmt.Fprint(conn, stats()+"END\r\n")
+					}
+				}
+			}(conn)
+		}
+	}()
+	host, port, _ = net.SplitHostPort(ln.Addr().String())
+	return host, port, func() { ln.Close() }
+}
+
+func TestStats(t *testing.T) {
+	host, port, closer := fakeStats(t, func() string {
+		return "STAT pid 1\r\nSTAT version 1.6.9\r\nSTAT curr_items 42\r\nSTAT bytes 1572864\r\nSTAT limit_maxbytes 67108864\r\nSTAT get_hits 850\r\nSTAT get_misses 150\r\nSTAT evictions 0\r\n"
+	})
+	defer closer()
+
+	ckr := run([]string{"-H", host, "-p", port, "--sample-interval", "10ms"})
+	assert.Equal(t, checkers.OK, ckr.Status, ckr.Message)
+	assert.Regexp(t, `^version 1\.6\.9, 42 items, 1\.50 MB / 64\.00 MB used \(2\.34%\), evictions 0\.00/s, stats in [\d.]+ ms$`, ckr.Message)
+
+	ckr = run([]string{"-H", host, "-p", port, "--sample-interval", "10ms", "--check-hit-ratio"})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Contains(t, ckr.Message, "hit ratio 85.00%")
+
+	ckr = run([]string{"-H", host, "-p", port, "--sample-interval", "10ms", "--check-hit-ratio", "--hit-ratio-critical", "90"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = run([]string{"-H", host, "-p", port, "--sample-interval", "10ms", "--memory-warning", "2", "--memory-critical", "50"})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	closer()
+	ckr = run([]string{"-H", host, "-p", port, "--sample-interval", "10ms"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+}
+
+func TestEvictions(t *testing.T) {
+	evictions := 0
+	host, port, closer := fakeStats(t, func() string {
+		evictions += 5
+		return fmt.Sprintf("STAT version 1.6.9\r\nSTAT evictions %d\r\n", evictions)
+	})
+	defer closer()
+
+	// warn by default if items are evicted
+	ckr := run([]string{"-H", host, "-p", port, "--sample-interval", "100ms"})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Contains(t, ckr.Message, "evictions 50.00/s")
+
+	ckr = run([]string{"-H", host, "-p", port, "--sample-interval", "100ms", "--eviction-warning", "60"})
+	assert.Equal(t, checkers.OK, ckr.Status)
+
+	ckr = run([]string{"-H", host, "-p", port, "--sample-interval", "100ms", "--eviction-critical", "10"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+}
+
+func TestHitRatio(t *testing.T) {
+	assert.Equal(t, 100.0, hitRatio(map[string]string{"get_hits": "0", "get_misses": "0"}))
+	assert.Equal(t, 75.0, hitRatio(map[string]string{"get_hits": "3", "get_misses": "1"}))
+}
@@ -0,0 +1,131 @@
+package checkmemory
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+	"github.com/mackerelio/go-check-plugins/check-file-size/lib"
+)
+
+type memoryOpts struct {
+	Warning           float64  `short:"w" long:"warning" value-name:"PERCENT" default:"80" description:"warning if the memory usage is over (%)"`
+	Critical          float64  `short:"c" long:"critical" value-name:"PERCENT" default:"90" description:"critical if the memory usage is over (%)"`
+	Used              bool     `long:"used" description:"compute the usage as MemTotal - MemFree - Buffers - Cached (default)"`
+	Available         bool     `long:"available" description:"compute the usage as MemTotal - MemAvailable, which includes the cache which can't be reclaimed"`
+	IncludeSwap       bool     `long:"include-swap" description:"add the swap to the usage and the total"`
+	SwapWarning       *float64 `long:"swap-warning" value-name:"PERCENT" description:"warning if the swap usage is over (%)"`
+	SwapCritical      *float64 `long:"swap-critical" value-name:"PERCENT" description:"critical if the swap usage is over (%)"`
+	SwapBytesCritical string   `long:"swap-bytes-critical" value-name:"N[KMGT]" description:"critical if the used swap is over the size"`
+}
+
+type memoryStat struct {
+	total     uint64
+	free      uint64
+	available uint64
+	buffers   uint64
+	cached    uint64
+	swapTotal uint64
+	swapFree  uint64
+}
+
+func (m *memoryStat) swapUsed() uint64 {
+	if m.swapFree > m.swapTotal {
+		return 0
+	}
+	return m.swapTotal - m.swapFree
+}
+
+// Do the plugin
+func Do() {
+	ckr := run(os.Args[1:])
+	ckr.Name = "Memory"
+	ckr.Exit()
+}
+
+// memoryUsage returns the used memory in percent
+func memoryUsage(opts *memoryOpts, m *memoryStat) float64 {
+	total := float64(m.total)
+	var used float64
+	if opts.Available {
+		used = float64(m.total) - float64(m.available)
+	} else {
+		used = float64(m.total) - float64(m.free) - float64(m.buffers) - float64(m.cached)
+	}
+	if opts.IncludeSwap {
+		total += float64(m.swapTotal)
+		used += float64(m.swapUsed())
+	}
+	if total <= 0 {
+		return 0
+	}
+	return used / total * 100
+}
+
+// evalSwap checks the swap usage independently of the memory usage
+func evalSwap(opts *memoryOpts, m *memoryStat, bytesCrit *float64) (checkers.Status, string) {
+	if m.swapTotal == 0 {
+		return checkers.OK, "No swap configured"
+	}
+	used := float64(m.swapUsed())
+	usage := used / float64(m.swapTotal) * 100
+
+	checkSt := checkers.OK
+	if opts.SwapCritical != nil && usage > *opts.SwapCritical ||
+		bytesCrit != nil && used > *bytesCrit {
+		checkSt = checkers.CRITICAL
+	} else if opts.SwapWarning != nil && usage > *opts.SwapWarning {
+		checkSt = checkers.WARNING
+	}
+	return checkSt, fmt.Sprintf("swap %.2f%% used (total %s, free %s, used %s)",
+		usage, checkfilesize.HumanizeBytes(float64(m.swapTotal)), checkfilesize.HumanizeBytes(float64(m.swapFree)), checkfilesize.HumanizeBytes(used))
+}
+
+func evalMemory(opts *memoryOpts, m *memoryStat, swapBytesCrit *float64) *checkers.Checker {
+	usage := memoryUsage(opts, m)
+	checkSt := checkers.OK
+	if usage > opts.Critical {
+		checkSt = checkers.CRITICAL
+	} else if usage > opts.Warning {
+		checkSt = checkers.WARNING
+	}
+
+	msg := fmt.Sprintf("%.2f%% used (total %s, free %s, available %s, buffers %s, cached %s)",
+		usage, checkfilesize.HumanizeBytes(float64(m.total)), checkfilesize.HumanizeBytes(float64(m.free)),
+		checkfilesize.HumanizeBytes(float64(m.available)), checkfilesize.HumanizeBytes(float64(m.buffers)),
+		checkfilesize.HumanizeBytes(float64(m.cached)))
+
+	swapSt, swapMsg := evalSwap(opts, m, swapBytesCrit)
+	if swapSt > checkSt {
+		checkSt = swapSt
+	}
+	msg += "; " + swapMsg
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func run(args []string) *checkers.Checker {
+	opts := memoryOpts{}
+	_, err := flags.ParseArgs(&opts, args)
+	if err != nil {
+		os.Exit(1)
+	}
+	if opts.Used && opts.Available {
+		return checkers.Unknown("--used and --available can't be used together")
+	}
+
+	var swapBytesCrit *float64
+	if opts.SwapBytesCritical != "" {
+		size, err := checkfilesize.SizeValue(opts.SwapBytesCritical)
+		if err != nil {
+			return checkers.Unknown(err.Error())
+		}
+		swapBytesCrit = &size
+	}
+
+	m, err := getMemory()
+	if err != nil {
+		return checkers.Unknown(fmt.Sprintf("Failed to fetch memory metrics: %s", err))
+	}
+	return evalMemory(&opts, m, swapBytesCrit)
+}
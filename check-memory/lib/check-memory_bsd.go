@@ -0,0 +1,22 @@
+// +build darwin freebsd
+
+package checkmemory
+
+import "github.com/mackerelio/go-osstat/memory"
+
+func getMemory() (*memoryStat, error) {
+	m, err := memory.Get()
+	if err != nil {
+		return nil, err
+	}
+	// there is no MemAvailable nor Buffers, so the memory not used by
+	// processes is regarded as available
+	return &memoryStat{
+		total:     m.Total,
+		free:      m.Free,
+		available: m.Total - m.Used,
+		cached:    m.Cached,
+		swapTotal: m.SwapTotal,
+		swapFree:  m.SwapFree,
+	}, nil
+}
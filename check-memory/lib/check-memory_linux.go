@@ -0,0 +1,24 @@
+package checkmemory
+
+import "github.com/mackerelio/go-osstat/memory"
+
+func getMemory() (*memoryStat, error) {
+	m, err := memory.Get()
+	if err != nil {
+		return nil, err
+	}
+	available := m.Available
+	if !m.MemAvailableEnabled {
+		// MemAvailable is not provided before Linux 3.14
+		available = m.Free + m.Buffers + m.Cached
+	}
+	return &memoryStat{
+		total:     m.Total,
+		free:      m.Free,
+		available: available,
+		buffers:   m.Buffers,
+		cached:    m.Cached,
+		swapTotal: m.SwapTotal,
+		swapFree:  m.SwapFree,
+	}, nil
+}
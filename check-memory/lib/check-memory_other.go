@@ -0,0 +1,12 @@
+// +build !linux,!darwin,!freebsd
+
+package checkmemory
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func getMemory() (*memoryStat, error) {
+	return nil, fmt.Errorf("check-memory is not supported on %s", runtime.GOOS)
+}
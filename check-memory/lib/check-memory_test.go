@@ -0,0 +1,58 @@
+package checkmemory
+
+import (
+	"testing"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+const gb = 1024 * 1024 * 1024
+
+var testStat = &memoryStat{
+	total:     10 * gb,
+	free:      1 * gb,
+	available: 2 * gb,
+	buffers:   1 * gb,
+	cached:    2 * gb,
+	swapTotal: 10 * gb,
+	swapFree:  8 * gb,
+}
+
+func TestMemoryUsage(t *testing.T) {
+	assert.Equal(t, 60.0, memoryUsage(&memoryOpts{}, testStat))
+	assert.Equal(t, 80.0, memoryUsage(&memoryOpts{Available: true}, testStat))
+	assert.Equal(t, 40.0, memoryUsage(&memoryOpts{IncludeSwap: true}, testStat))
+	assert.Equal(t, 0.0, memoryUsage(&memoryOpts{}, &memoryStat{}))
+}
+
+func TestEvalMemory(t *testing.T) {
+	ckr := evalMemory(&memoryOpts{Warning: 80, Critical: 90}, testStat, nil)
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "60.00% used (total 10.00 GB, free 1.00 GB, available 2.00 GB, buffers 1.00 GB, cached 2.00 GB); swap 20.00% used (total 10.00 GB, free 8.00 GB, used 2.00 GB)", ckr.Message)
+
+	ckr = evalMemory(&memoryOpts{Warning: 70, Critical: 90, Available: true}, testStat, nil)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	ckr = evalMemory(&memoryOpts{Warning: 50, Critical: 70, Available: true}, testStat, nil)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+}
+
+func TestEvalSwap(t *testing.T) {
+	warn, crit := 10.0, 50.0
+	st, msg := evalSwap(&memoryOpts{SwapWarning: &warn, SwapCritical: &crit}, testStat, nil)
+	assert.Equal(t, checkers.WARNING, st)
+	assert.Equal(t, "swap 20.00% used (total 10.00 GB, free 8.00 GB, used 2.00 GB)", msg)
+
+	bytesCrit := float64(1 * gb)
+	st, _ = evalSwap(&memoryOpts{SwapWarning: &warn, SwapCritical: &crit}, testStat, &bytesCrit)
+	assert.Equal(t, checkers.CRITICAL, st)
+
+	st, msg = evalSwap(&memoryOpts{SwapWarning: &warn}, &memoryStat{total: 10 * gb}, &bytesCrit)
+	assert.Equal(t, checkers.OK, st)
+	assert.Equal(t, "No swap configured", msg)
+
+	// the swap is checked independently of the memory
+	ckr := evalMemory(&memoryOpts{Warning: 80, Critical: 90, SwapCritical: &warn}, testStat, nil)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+}
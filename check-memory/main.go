@@ -0,0 +1,7 @@
+package main
+
+import "github.com/mackerelio/go-check-plugins/check-memory/lib"
+
+func main() {
+	checkmemory.Do()
+}
@@ -0,0 +1,221 @@
+package checkmongodb
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongodbOpts struct {
+	URI      string  `long:"uri" value-name:"URI" description:"Connection string instead of the individual options"`
+	Host     string  `short:"H" long:"host" default:"localhost" description:"Hostname"`
+	Port     string  `short:"p" long:"port" default:"27017" description:"Port"`
+	User     string  `short:"u" long:"user" description:"Username"`
+	Password string  `short:"P" long:"password" description:"Password" env:"MONGODB_PASSWORD"`
+	AuthDB   string  `long:"authdb" default:"admin" description:"Database to authenticate the user"`
+	Timeout  int     `short:"t" long:"timeout" value-name:"SECONDS" default:"10" description:"Timeout in seconds"`
+	Warning  float64 `short:"w" long:"warning" value-name:"MSEC" description:"warning if the ping RTT is over (ms)"`
+	Critical float64 `short:"c" long:"critical" value-name:"MSEC" description:"critical if the ping RTT is over (ms)"`
+
+	ReplSet                string   `long:"replset" value-name:"NAME" description:"Check the status of the replica set instead of the ping"`
+	MinSecondaries         int      `long:"min-secondaries" value-name:"N" description:"critical if the number of the SECONDARY members is less than (with --replset)"`
+	ReplicationLagWarning  *float64 `long:"replication-lag-warning" value-name:"SECONDS" description:"warning if the maximum replication lag of the secondaries is over (with --replset)"`
+	ReplicationLagCritical *float64 `long:"replication-lag-critical" value-name:"SECONDS" description:"critical if the maximum replication lag of the secondaries is over (with --replset)"`
+}
+
+// Do the plugin
+func Do() {
+	ckr := run(os.Args[1:])
+	ckr.Name = "MongoDB"
+	ckr.Exit()
+}
+
+// clientOptions returns the options to connect to the server given by --uri
+// or the individual options
+func clientOptions(opts *mongodbOpts) *options.ClientOptions {
+	timeout := time.Duration(opts.Timeout) * time.Second
+	var co *options.ClientOptions
+	if opts.URI != "" {
+		co = options.Client().ApplyURI(opts.URI)
+	} else {
+		co = options.Client().ApplyURI("mongodb://" + net.JoinHostPort(opts.Host, opts.Port)).SetDirect(true)
+		if opts.User != "" {
+			co.SetAuth(options.Credential{
+				Username:   opts.User,
+				Password:   opts.Password,
+				AuthSource: opts.AuthDB,
+			})
+		}
+	}
+	return co.SetConnectTimeout(timeout).SetServerSelectionTimeout(timeout)
+}
+
+type serverInfo struct {
+	version       string
+	storageEngine string
+}
+
+// getServerInfo returns the version and the storage engine. The storage
+// engine is empty if the user is not allowed to run serverStatus.
+func getServerInfo(ctx context.Context, db *mongo.Database) (*serverInfo, error) {
+	var buildInfo struct {
+		Version string `bson:"version"`
+	}
+	if err := db.RunCommand(ctx, bson.M{"buildInfo": 1}).Decode(&buildInfo); err != nil {
+		return nil, err
+	}
+	var serverStatus struct {
+		StorageEngine struct {
+			Name string `bson:"name"`
+		} `bson:"storageEngine"`
+	}
+	db.RunCommand(ctx, bson.M{"serverStatus": 1}).Decode(&serverStatus)
+	return &serverInfo{buildInfo.Version, serverStatus.StorageEngine.Name}, nil
+}
+
+func evalPing(opts *mongodbOpts, rtt float64, info *serverInfo) *checkers.Checker {
+	checkSt := checkers.OK
+	if opts.Critical > 0 && rtt > opts.Critical {
+		checkSt = checkers.CRITICAL
+	} else if opts.Warning > 0 && rtt > opts.Warning {
+		checkSt = checkers.WARNING
+	}
+	msg := fmt.Sprintf("%.3f ms ping RTT, MongoDB %s", rtt, info.version)
+	if info.storageEngine != "" {
+		msg += fmt.Sprintf(" (storage engine: %s)", info.storageEngine)
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
+
+type replSetStatus struct {
+	Set     string          `bson:"set"`
+	Members []replSetMember `bson:"members"`
+}
+
+type replSetMember struct {
+	Name       string    `bson:"name"`
+	StateStr   string    `bson:"stateStr"`
+	OptimeDate time.Time `bson:"optimeDate"`
+}
+
+// unhealthyStates are the states of the members which can't serve
+var unhealthyStates = map[string]bool{
+	"STARTUP":    true,
+	"STARTUP2":   true,
+	"RECOVERING": true,
+	"ROLLBACK":   true,
+}
+
+func evalReplSet(opts *mongodbOpts, status *replSetStatus) *checkers.Checker {
+	if status.Set != opts.ReplSet {
+		return checkers.Critical(fmt.Sprintf("the server is a member of the replica set %s, not %s", status.Set, opts.ReplSet))
+	}
+
+	var primary *replSetMember
+	for i, m := range status.Members {
+		if m.StateStr == "PRIMARY" {
+			primary = &status.Members[i]
+		}
+	}
+
+	checkSt := checkers.OK
+	var problems, members []string
+	secondaries := 0
+	maxLag := 0.0
+	for _, m := range status.Members {
+		member := fmt.Sprintf("%s %s", m.Name, m.StateStr)
+		switch {
+		case m.StateStr == "SECONDARY":
+			secondaries++
+			if primary != nil {
+				lag := primary.OptimeDate.Sub(m.OptimeDate).Seconds()
+				if lag < 0 {
+					lag = 0
+				}
+				if lag > maxLag {
+					maxLag = lag
+				}
+				member += fmt.Sprintf(" (lag %.0fs)", lag)
+			}
+		case unhealthyStates[m.StateStr]:
+			checkSt = checkers.CRITICAL
+			problems = append(problems, fmt.Sprintf("%s is %s", m.Name, m.StateStr))
+		}
+		members = append(members, member)
+	}
+
+	if primary == nil {
+		checkSt = checkers.CRITICAL
+		problems = append([]string{"no PRIMARY"}, problems...)
+	}
+	if secondaries < opts.MinSecondaries {
+		checkSt = checkers.CRITICAL
+		problems = append(problems, fmt.Sprintf("%d SECONDARY members < %d", secondaries, opts.MinSecondaries))
+	}
+	if opts.ReplicationLagCritical != nil && maxLag > *opts.ReplicationLagCritical {
+		checkSt = checkers.CRITICAL
+		problems = append(problems, fmt.Sprintf("replication lag %.0fs > %.0fs", maxLag, *opts.ReplicationLagCritical))
+	} else if opts.ReplicationLagWarning != nil && maxLag > *opts.ReplicationLagWarning {
+		if checkSt == checkers.OK {
+			checkSt = checkers.WARNING
+		}
+		problems = append(problems, fmt.Sprintf("replication lag %.0fs > %.0fs", maxLag, *opts.ReplicationLagWarning))
+	}
+
+	msg := fmt.Sprintf("replica set %s: %s", status.Set, strings.Join(members, ", "))
+	if len(problems) > 0 {
+		msg = strings.Join(problems, ", ") + "\n" + msg
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func checkReplSet(ctx context.Context, opts *mongodbOpts, db *mongo.Database) *checkers.Checker {
+	var status replSetStatus
+	if err := db.RunCommand(ctx, bson.M{"replSetGetStatus": 1}).Decode(&status); err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't get the replica set status: %s", err))
+	}
+	return evalReplSet(opts, &status)
+}
+
+func run(args []string) *checkers.Checker {
+	opts := mongodbOpts{}
+	_, err := flags.ParseArgs(&opts, args)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(opts.Timeout)*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, clientOptions(&opts))
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	defer client.Disconnect(context.Background())
+
+	// the connection is established here not to include it in the RTT
+	db := client.Database("admin")
+	info, err := getServerInfo(ctx, db)
+	if err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't connect to MongoDB: %s", err))
+	}
+
+	if opts.ReplSet != "" {
+		return checkReplSet(ctx, &opts, db)
+	}
+
+	start := time.Now()
+	if err := db.RunCommand(ctx, bson.M{"ping": 1}).Err(); err != nil {
+		return checkers.Critical(fmt.Sprintf("ping failed: %s", err))
+	}
+	rtt := float64(time.Since(start)) / float64(time.Millisecond)
+	return evalPing(&opts, rtt, info)
+}
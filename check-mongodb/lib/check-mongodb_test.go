@@ -0,0 +1,82 @@
+package checkmongodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientOptions(t *testing.T) {
+	co := clientOptions(&mongodbOpts{Host: "db1", Port: "27018", User: "nagios", Password: "secret", AuthDB: "admin", Timeout: 5})
+	assert.Equal(t, []string{"db1:27018"}, co.Hosts)
+	assert.True(t, *co.Direct)
+	assert.Equal(t, "nagios", co.Auth.Username)
+	assert.Equal(t, "admin", co.Auth.AuthSource)
+	assert.Equal(t, 5*time.Second, *co.ServerSelectionTimeout)
+
+	co = clientOptions(&mongodbOpts{Host: "localhost", Port: "27017", Timeout: 10})
+	assert.Nil(t, co.Auth)
+
+	co = clientOptions(&mongodbOpts{URI: "mongodb://db1:27017,db2:27017/?replicaSet=rs0", Host: "localhost", Port: "27017", Timeout: 10})
+	assert.Equal(t, []string{"db1:27017", "db2:27017"}, co.Hosts)
+	assert.Nil(t, co.Direct)
+}
+
+func TestEvalPing(t *testing.T) {
+	info := &serverInfo{version: "4.0.10", storageEngine: "wiredTiger"}
+	ckr := evalPing(&mongodbOpts{Warning: 100, Critical: 500}, 1.5, info)
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "1.500 ms ping RTT, MongoDB 4.0.10 (storage engine: wiredTiger)", ckr.Message)
+
+	ckr = evalPing(&mongodbOpts{Warning: 100, Critical: 500}, 200, &serverInfo{version: "4.0.10"})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "200.000 ms ping RTT, MongoDB 4.0.10", ckr.Message)
+
+	ckr = evalPing(&mongodbOpts{Warning: 100, Critical: 500}, 600, info)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = evalPing(&mongodbOpts{}, 600, info)
+	assert.Equal(t, checkers.OK, ckr.Status)
+}
+
+func TestEvalReplSet(t *testing.T) {
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	status := &replSetStatus{
+		Set: "rs0",
+		Members: []replSetMember{
+			{Name: "db1:27017", StateStr: "PRIMARY", OptimeDate: now},
+			{Name: "db2:27017", StateStr: "SECONDARY", OptimeDate: now.Add(-3 * time.Second)},
+			{Name: "db3:27017", StateStr: "SECONDARY", OptimeDate: now.Add(-30 * time.Second)},
+			{Name: "db4:27017", StateStr: "ARBITER"},
+		},
+	}
+	ckr := evalReplSet(&mongodbOpts{ReplSet: "rs0", MinSecondaries: 2}, status)
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "replica set rs0: db1:27017 PRIMARY, db2:27017 SECONDARY (lag 3s), db3:27017 SECONDARY (lag 30s), db4:27017 ARBITER", ckr.Message)
+
+	warn, crit := 10.0, 60.0
+	ckr = evalReplSet(&mongodbOpts{ReplSet: "rs0", ReplicationLagWarning: &warn, ReplicationLagCritical: &crit}, status)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Regexp(t, `^replication lag 30s > 10s\n`, ckr.Message)
+
+	ckr = evalReplSet(&mongodbOpts{ReplSet: "rs0", MinSecondaries: 3}, status)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Regexp(t, `^2 SECONDARY members < 3\n`, ckr.Message)
+
+	ckr = evalReplSet(&mongodbOpts{ReplSet: "rs1"}, status)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "the server is a member of the replica set rs0, not rs1", ckr.Message)
+
+	status = &replSetStatus{
+		Set: "rs0",
+		Members: []replSetMember{
+			{Name: "db1:27017", StateStr: "RECOVERING"},
+			{Name: "db2:27017", StateStr: "SECONDARY", OptimeDate: now},
+		},
+	}
+	ckr = evalReplSet(&mongodbOpts{ReplSet: "rs0"}, status)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "no PRIMARY, db1:27017 is RECOVERING\nreplica set rs0: db1:27017 RECOVERING, db2:27017 SECONDARY", ckr.Message)
+}
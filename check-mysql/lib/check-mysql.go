@@ -1,14 +1,18 @@
 package checkmysql
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
 	"strings"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/mackerelio/checkers"
-	"github.com/ziutek/mymysql/mysql"
-	// MySQL Driver
-	_ "github.com/ziutek/mymysql/native"
 )
 
 type mysqlSetting struct {
@@ -17,13 +21,29 @@ type mysqlSetting struct {
 	Socket string `short:"S" long:"socket" default:"" description:"Path to unix socket"`
 	User   string `short:"u" long:"user" default:"root" description:"Username"`
 	Pass   string `short:"P" long:"password" default:"" description:"Password" env:"MYSQL_PASSWORD"`
+
+	TLS           bool   `long:"tls" description:"Use TLS, and warn if the connection is not encrypted"`
+	TLSCA         string `long:"tls-ca" value-name:"FILE" description:"CA certificate file to verify the server (with --tls)"`
+	TLSCert       string `long:"tls-cert" value-name:"FILE" description:"Client certificate file (with --tls)"`
+	TLSKey        string `long:"tls-key" value-name:"FILE" description:"Client private key file (with --tls)"`
+	TLSSkipVerify bool   `long:"tls-skip-verify" description:"Do not verify the server certificate (with --tls)"`
 }
 
+// tlsConfigName is the name of the TLS config registered to the driver
+const tlsConfigName = "check"
+
+// errNoTLS is returned by newMySQL when --tls is given but the connection is
+// not encrypted
+var errNoTLS = errors.New("TLS is requested but the server does not support it")
+
 var commands = map[string](func([]string) *checkers.Checker){
 	"replication": checkReplication,
 	"connection":  checkConnection,
 	"uptime":      checkUptime,
 	"readonly":    checkReadOnly,
+	"ping":        checkPing,
+	"innodb":      checkInnoDB,
+	"galera":      checkGalera,
 }
 
 func separateSub(argv []string) (string, []string) {
@@ -52,12 +72,117 @@ SubCommands:`)
 	ckr.Exit()
 }
 
-func newMySQL(m mysqlSetting) mysql.Conn {
-	proto := "tcp"
-	target := fmt.Sprintf("%s:%s", m.Host, m.Port)
+func newDSN(m mysqlSetting) string {
+	cfg := mysql.NewConfig()
+	cfg.User = m.User
+	cfg.Passwd = m.Pass
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(m.Host, m.Port)
 	if m.Socket != "" {
-		proto = "unix"
-		target = m.Socket
+		cfg.Net = "unix"
+		cfg.Addr = m.Socket
+	}
+	if m.TLS {
+		cfg.TLSConfig = tlsConfigName
+	}
+	return cfg.FormatDSN()
+}
+
+func newTLSConfig(m mysqlSetting) (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: m.TLSSkipVerify,
+	}
+	if m.TLSCA != "" {
+		pem, err := ioutil.ReadFile(m.TLSCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("failed to parse CA certificates: %s", m.TLSCA)
+		}
+		config.RootCAs = pool
+	}
+	if (m.TLSCert == "") != (m.TLSKey == "") {
+		return nil, fmt.Errorf("Both --tls-cert and --tls-key are required for the client certificate")
+	}
+	if m.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(m.TLSCert, m.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// newMySQL opens a connection and makes sure that the server is reachable
+func newMySQL(m mysqlSetting) (*sql.DB, error) {
+	if m.TLS {
+		config, err := newTLSConfig(m)
+		if err != nil {
+			return nil, err
+		}
+		if err := mysql.RegisterTLSConfig(tlsConfigName, config); err != nil {
+			return nil, err
+		}
+	}
+	db, err := sql.Open("mysql", newDSN(m))
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		if err == mysql.ErrNoTLS {
+			return nil, errNoTLS
+		}
+		return nil, err
+	}
+	if m.TLS {
+		cipher, err := queryValue(db, "SHOW SESSION STATUS LIKE 'Ssl_cipher'")
+		if err != nil || cipher == "" {
+			db.Close()
+			return nil, errNoTLS
+		}
+	}
+	return db, nil
+}
+
+// queryValue returns the value of SHOW STATUS or SHOW VARIABLES with LIKE
+func queryValue(db *sql.DB, query string) (string, error) {
+	var name, value string
+	err := db.QueryRow(query).Scan(&name, &value)
+	return value, err
+}
+
+// queryRow returns the first row as a map of column names to values, or nil
+// if there are no rows, for statements like SHOW SLAVE STATUS which have too
+// many columns to scan one by one
+func queryRow(db *sql.DB, query string) (map[string]sql.NullString, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	row := make(map[string]sql.NullString, len(columns))
+	for i, c := range columns {
+		row[c] = values[i]
 	}
-	return mysql.New(proto, "", target, m.User, m.Pass, "")
+	return row, nil
 }
@@ -0,0 +1,154 @@
+package checkmysql
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDSN(t *testing.T) {
+	m := mysqlSetting{Host: "localhost", Port: "3306", User: "root", Pass: "secret"}
+	assert.Equal(t, "root:secret@tcp(localhost:3306)/", newDSN(m))
+
+	m.Host = "::1"
+	assert.Equal(t, "root:secret@tcp([::1]:3306)/", newDSN(m))
+
+	m.Socket = "/var/run/mysqld/mysqld.sock"
+	assert.Equal(t, "root:secret@unix(/var/run/mysqld/mysqld.sock)/", newDSN(m))
+}
+
+func TestHasReplicaStatus(t *testing.T) {
+	cases := map[string]bool{
+		"5.7.30-log":              false,
+		"8.0.21":                  false,
+		"8.0.22":                  true,
+		"8.0.35-0ubuntu0.22.04.1": true,
+		"8.4.0":                   true,
+		"10.5.8-MariaDB-log":      false,
+		"unknown":                 false,
+	}
+	for version, want := range cases {
+		assert.Equal(t, want, hasReplicaStatus(version), version)
+	}
+}
+
+func TestEvalReplication(t *testing.T) {
+	row := func(io, sqlThread string, behind sql.NullString) map[string]sql.NullString {
+		return map[string]sql.NullString{
+			"Replica_IO_Running":    {String: io, Valid: true},
+			"Replica_SQL_Running":   {String: sqlThread, Valid: true},
+			"Seconds_Behind_Source": behind,
+		}
+	}
+	sec := func(s string) sql.NullString { return sql.NullString{String: s, Valid: true} }
+
+	ckr := evalReplication(row("Yes", "Yes", sec("3")), replicaStatusColumns, 5, 10)
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "MySQL replication behind master 3 seconds (IO thread: Yes, SQL thread: Yes)", ckr.Message)
+
+	ckr = evalReplication(row("Yes", "Yes", sec("7")), replicaStatusColumns, 5, 10)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	ckr = evalReplication(row("Yes", "Yes", sec("11")), replicaStatusColumns, 5, 10)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = evalReplication(row("Yes", "Yes", sql.NullString{}), replicaStatusColumns, 5, 10)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "MySQL replication behind master is NULL (IO thread: Yes, SQL thread: Yes)", ckr.Message)
+
+	ckr = evalReplication(row("Connecting", "Yes", sql.NullString{}), replicaStatusColumns, 5, 10)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "MySQL replication has been stopped (IO thread: Connecting, SQL thread: Yes)", ckr.Message)
+}
+
+func TestConnThreshold(t *testing.T) {
+	th, err := parseConnThreshold("80%")
+	assert.NoError(t, err)
+	assert.True(t, th.percent)
+	assert.True(t, th.exceeded(81, 100))
+	assert.False(t, th.exceeded(80, 100))
+	assert.False(t, th.exceeded(81, 0))
+
+	th, err = parseConnThreshold("200")
+	assert.NoError(t, err)
+	assert.False(t, th.percent)
+	assert.True(t, th.exceeded(201, 100))
+	assert.False(t, th.exceeded(200, 1000))
+
+	_, err = parseConnThreshold("abc%")
+	assert.Error(t, err)
+	_, err = parseConnThreshold("-1")
+	assert.Error(t, err)
+}
+
+func TestHitRatio(t *testing.T) {
+	prev := bufferPoolStatus{reads: 100, readRequests: 10000}
+	assert.Equal(t, 95.0, hitRatio(prev, bufferPoolStatus{reads: 150, readRequests: 11000}))
+	assert.Equal(t, 100.0, hitRatio(prev, prev))
+	assert.Equal(t, 0.0, hitRatio(prev, bufferPoolStatus{reads: 200, readRequests: 10100}))
+}
+
+func TestEvalReadOnly(t *testing.T) {
+	ro := readOnlyMode{readOnly: true, superReadOnly: sql.NullBool{Bool: true, Valid: true}}
+	rw := readOnlyMode{readOnly: false}
+
+	ckr := evalReadOnly(ro, "")
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "the server is read-only (read_only: ON, super_read_only: ON)", ckr.Message)
+
+	ckr = evalReadOnly(rw, "")
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "the server is read-write (read_only: OFF)", ckr.Message)
+
+	assert.Equal(t, checkers.OK, evalReadOnly(ro, "true").Status)
+	assert.Equal(t, checkers.CRITICAL, evalReadOnly(rw, "true").Status)
+	assert.Equal(t, checkers.OK, evalReadOnly(rw, "false").Status)
+	assert.Equal(t, checkers.CRITICAL, evalReadOnly(ro, "false").Status)
+}
+
+func TestNewDSNWithTLS(t *testing.T) {
+	m := mysqlSetting{Host: "localhost", Port: "3306", User: "root", TLS: true}
+	assert.Equal(t, "root@tcp(localhost:3306)/?tls=check", newDSN(m))
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	config, err := newTLSConfig(mysqlSetting{TLSSkipVerify: true})
+	assert.NoError(t, err)
+	assert.True(t, config.InsecureSkipVerify)
+
+	_, err = newTLSConfig(mysqlSetting{TLSCert: "client.pem"})
+	assert.Error(t, err)
+
+	_, err = newTLSConfig(mysqlSetting{TLSCA: "testdata/not-found.pem"})
+	assert.Error(t, err)
+}
+
+func TestEvalGalera(t *testing.T) {
+	status := map[string]string{
+		"wsrep_cluster_status":      "Primary",
+		"wsrep_local_state_comment": "Synced",
+		"wsrep_cluster_size":        "3",
+		"wsrep_flow_control_paused": "0.01",
+	}
+	ckr := evalGalera(status, 3, 0.1)
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "cluster status: Primary, local state: Synced, cluster size: 3, flow control paused: 0.01", ckr.Message)
+
+	status["wsrep_flow_control_paused"] = "0.2"
+	assert.Equal(t, checkers.WARNING, evalGalera(status, 3, 0.1).Status)
+
+	status["wsrep_cluster_size"] = "2"
+	assert.Equal(t, checkers.CRITICAL, evalGalera(status, 3, 0.1).Status)
+
+	status["wsrep_cluster_size"] = "3"
+	status["wsrep_local_state_comment"] = "Donor/Desynced"
+	assert.Equal(t, checkers.CRITICAL, evalGalera(status, 3, 0.1).Status)
+
+	status["wsrep_local_state_comment"] = "Synced"
+	status["wsrep_cluster_status"] = "non-Primary"
+	assert.Equal(t, checkers.CRITICAL, evalGalera(status, 3, 0.1).Status)
+
+	assert.Equal(t, checkers.UNKNOWN, evalGalera(map[string]string{}, 3, 0.1).Status)
+}
@@ -3,6 +3,8 @@ package checkmysql
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/mackerelio/checkers"
@@ -10,8 +12,48 @@ import (
 
 type connectionOpts struct {
 	mysqlSetting
-	Crit int64 `short:"c" long:"critical" default:"250" description:"critical if the number of connection is over"`
-	Warn int64 `short:"w" long:"warning" default:"200" description:"warning if the number of connection is over"`
+	Crit               int64  `short:"c" long:"critical" default:"250" description:"critical if the number of connection is over"`
+	Warn               int64  `short:"w" long:"warning" default:"200" description:"warning if the number of connection is over"`
+	ConnCrit           string `long:"connections-critical" value-name:"N[%]" description:"critical if the number of connection is over, or over the percentage of max_connections with %. takes precedence over --critical"`
+	ConnWarn           string `long:"connections-warning" value-name:"N[%]" description:"warning if the number of connection is over, or over the percentage of max_connections with %. takes precedence over --warning"`
+	ThreadsRunningCrit *int64 `long:"threads-running-critical" value-name:"N" description:"critical if the number of running threads is over"`
+	ThreadsRunningWarn *int64 `long:"threads-running-warning" value-name:"N" description:"warning if the number of running threads is over"`
+}
+
+// connThreshold is a threshold for the number of connections, either in
+// absolute numbers or in percentage of max_connections
+type connThreshold struct {
+	value   float64
+	percent bool
+}
+
+func parseConnThreshold(s string) (connThreshold, error) {
+	var t connThreshold
+	num := s
+	if strings.HasSuffix(s, "%") {
+		t.percent = true
+		num = strings.TrimSuffix(s, "%")
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v < 0 {
+		return t, fmt.Errorf("invalid threshold: %s", s)
+	}
+	t.value = v
+	return t, nil
+}
+
+func (t connThreshold) exceeded(connections, maxConnections int64) bool {
+	if t.percent {
+		return connPercentage(connections, maxConnections) > t.value
+	}
+	return float64(connections) > t.value
+}
+
+func connPercentage(connections, maxConnections int64) float64 {
+	if maxConnections <= 0 {
+		return 0
+	}
+	return float64(connections) / float64(maxConnections) * 100
 }
 
 func checkConnection(args []string) *checkers.Checker {
@@ -22,26 +64,58 @@ func checkConnection(args []string) *checkers.Checker {
 	if err != nil {
 		os.Exit(1)
 	}
-	db := newMySQL(opts.mysqlSetting)
-	err = db.Connect()
+
+	crit := connThreshold{value: float64(opts.Crit)}
+	if opts.ConnCrit != "" {
+		if crit, err = parseConnThreshold(opts.ConnCrit); err != nil {
+			return checkers.Unknown(err.Error())
+		}
+	}
+	warn := connThreshold{value: float64(opts.Warn)}
+	if opts.ConnWarn != "" {
+		if warn, err = parseConnThreshold(opts.ConnWarn); err != nil {
+			return checkers.Unknown(err.Error())
+		}
+	}
+
+	db, err := newMySQL(opts.mysqlSetting)
+	if err == errNoTLS {
+		return checkers.Warning(err.Error())
+	}
 	if err != nil {
 		return checkers.Unknown("couldn't connect DB")
 	}
 	defer db.Close()
 
-	rows, res, err := db.Query("SHOW GLOBAL STATUS LIKE 'Threads_Connected'")
-	if err != nil {
-		return checkers.Unknown("couldn't execute query")
+	var values [3]int64
+	for i, query := range []string{
+		"SHOW GLOBAL STATUS LIKE 'Threads_Connected'",
+		"SHOW GLOBAL VARIABLES LIKE 'max_connections'",
+		"SHOW GLOBAL STATUS LIKE 'Threads_running'",
+	} {
+		value, err := queryValue(db, query)
+		if err != nil {
+			return checkers.Unknown("couldn't execute query")
+		}
+		values[i], err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return checkers.Unknown(err.Error())
+		}
 	}
-
-	idxValue := res.Map("Value")
-	threadsConnected := rows[0].Int64(idxValue)
+	threadsConnected, maxConnections, threadsRunning := values[0], values[1], values[2]
 
 	checkSt := checkers.OK
-	msg := fmt.Sprintf("%d connections", threadsConnected)
-	if threadsConnected > opts.Crit {
+	msg := fmt.Sprintf("%d connections (max_connections: %d, %.2f%%), %d threads running",
+		threadsConnected, maxConnections, connPercentage(threadsConnected, maxConnections), threadsRunning)
+	if crit.exceeded(threadsConnected, maxConnections) {
+		checkSt = checkers.CRITICAL
+	} else if warn.exceeded(threadsConnected, maxConnections) {
+		checkSt = checkers.WARNING
+	}
+
+	if opts.ThreadsRunningCrit != nil && threadsRunning > *opts.ThreadsRunningCrit {
 		checkSt = checkers.CRITICAL
-	} else if threadsConnected > opts.Warn {
+	} else if opts.ThreadsRunningWarn != nil && threadsRunning > *opts.ThreadsRunningWarn && checkSt == checkers.OK {
 		checkSt = checkers.WARNING
 	}
 	return checkers.NewChecker(checkSt, msg)
@@ -0,0 +1,86 @@
+package checkmysql
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+type galeraOpts struct {
+	mysqlSetting
+	MinSize            int64   `long:"galera-min-size" value-name:"N" default:"3" description:"critical if wsrep_cluster_size is less than"`
+	FlowControlWarning float64 `long:"flow-control-warning" value-name:"RATIO" default:"0.1" description:"warning if wsrep_flow_control_paused is over"`
+}
+
+func getWsrepStatus(db *sql.DB) (map[string]string, error) {
+	rows, err := db.Query("SHOW GLOBAL STATUS LIKE 'wsrep_%'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	status := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		status[strings.ToLower(name)] = value
+	}
+	return status, rows.Err()
+}
+
+func evalGalera(status map[string]string, minSize int64, flowControlWarning float64) *checkers.Checker {
+	clusterStatus, ok := status["wsrep_cluster_status"]
+	if !ok {
+		return checkers.Unknown("wsrep status is not available, the server is not a Galera node")
+	}
+	stateComment := status["wsrep_local_state_comment"]
+	clusterSize, err := strconv.ParseInt(status["wsrep_cluster_size"], 10, 64)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	flowControlPaused, err := strconv.ParseFloat(status["wsrep_flow_control_paused"], 64)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+
+	checkSt := checkers.OK
+	msg := fmt.Sprintf("cluster status: %s, local state: %s, cluster size: %d, flow control paused: %g",
+		clusterStatus, stateComment, clusterSize, flowControlPaused)
+	if clusterStatus != "Primary" || stateComment != "Synced" || clusterSize < minSize {
+		checkSt = checkers.CRITICAL
+	} else if flowControlPaused > flowControlWarning {
+		checkSt = checkers.WARNING
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func checkGalera(args []string) *checkers.Checker {
+	opts := galeraOpts{}
+	psr := flags.NewParser(&opts, flags.Default)
+	psr.Usage = "galera [OPTIONS]"
+	_, err := psr.ParseArgs(args)
+	if err != nil {
+		os.Exit(1)
+	}
+	db, err := newMySQL(opts.mysqlSetting)
+	if err == errNoTLS {
+		return checkers.Warning(err.Error())
+	}
+	if err != nil {
+		return checkers.Unknown("couldn't connect DB")
+	}
+	defer db.Close()
+
+	status, err := getWsrepStatus(db)
+	if err != nil {
+		return checkers.Unknown("couldn't execute query")
+	}
+	return evalGalera(status, opts.MinSize, opts.FlowControlWarning)
+}
@@ -0,0 +1,96 @@
+package checkmysql
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+type innodbOpts struct {
+	mysqlSetting
+	Crit     float64       `short:"c" long:"hit-ratio-critical" value-name:"PERCENT" default:"80" description:"critical if the buffer pool hit ratio is under (%)"`
+	Warn     float64       `short:"w" long:"hit-ratio-warning" value-name:"PERCENT" default:"90" description:"warning if the buffer pool hit ratio is under (%)"`
+	Interval time.Duration `long:"interval" default:"1s" description:"interval between the two snapshots of the status counters"`
+}
+
+// bufferPoolStatus is a snapshot of the InnoDB buffer pool read counters
+type bufferPoolStatus struct {
+	reads        int64
+	readRequests int64
+}
+
+func getBufferPoolStatus(db *sql.DB) (bufferPoolStatus, error) {
+	var st bufferPoolStatus
+	for _, v := range []struct {
+		name string
+		dest *int64
+	}{
+		{"Innodb_buffer_pool_reads", &st.reads},
+		{"Innodb_buffer_pool_read_requests", &st.readRequests},
+	} {
+		value, err := queryValue(db, fmt.Sprintf("SHOW GLOBAL STATUS LIKE '%s'", v.name))
+		if err != nil {
+			return st, err
+		}
+		*v.dest, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return st, err
+		}
+	}
+	return st, nil
+}
+
+// hitRatio returns the buffer pool hit ratio in percent between the two
+// snapshots. It is 100 if there are no read requests in the meantime.
+func hitRatio(prev, cur bufferPoolStatus) float64 {
+	requests := cur.readRequests - prev.readRequests
+	if requests <= 0 {
+		return 100
+	}
+	reads := cur.reads - prev.reads
+	return (1 - float64(reads)/float64(requests)) * 100
+}
+
+func checkInnoDB(args []string) *checkers.Checker {
+	opts := innodbOpts{}
+	psr := flags.NewParser(&opts, flags.Default)
+	psr.Usage = "innodb [OPTIONS]"
+	_, err := psr.ParseArgs(args)
+	if err != nil {
+		os.Exit(1)
+	}
+	db, err := newMySQL(opts.mysqlSetting)
+	if err == errNoTLS {
+		return checkers.Warning(err.Error())
+	}
+	if err != nil {
+		return checkers.Unknown("couldn't connect DB")
+	}
+	defer db.Close()
+
+	prev, err := getBufferPoolStatus(db)
+	if err != nil {
+		return checkers.Unknown("couldn't execute query")
+	}
+	time.Sleep(opts.Interval)
+	cur, err := getBufferPoolStatus(db)
+	if err != nil {
+		return checkers.Unknown("couldn't execute query")
+	}
+
+	ratio := hitRatio(prev, cur)
+	checkSt := checkers.OK
+	msg := fmt.Sprintf("InnoDB buffer pool hit ratio %.2f%% (%d reads / %d read requests in %s)",
+		ratio, cur.reads-prev.reads, cur.readRequests-prev.readRequests, opts.Interval)
+	if ratio < opts.Crit {
+		checkSt = checkers.CRITICAL
+	} else if ratio < opts.Warn {
+		checkSt = checkers.WARNING
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
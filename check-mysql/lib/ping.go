@@ -0,0 +1,51 @@
+package checkmysql
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+type pingOpts struct {
+	mysqlSetting
+	Crit float64 `short:"c" long:"critical" value-name:"MSEC" description:"critical if the round-trip time of SELECT 1 is over (ms)"`
+	Warn float64 `short:"w" long:"warning" value-name:"MSEC" description:"warning if the round-trip time of SELECT 1 is over (ms)"`
+}
+
+func checkPing(args []string) *checkers.Checker {
+	opts := pingOpts{}
+	psr := flags.NewParser(&opts, flags.Default)
+	psr.Usage = "ping [OPTIONS]"
+	_, err := psr.ParseArgs(args)
+	if err != nil {
+		os.Exit(1)
+	}
+	db, err := newMySQL(opts.mysqlSetting)
+	if err == errNoTLS {
+		return checkers.Warning(err.Error())
+	}
+	if err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't connect DB: %s", err))
+	}
+	defer db.Close()
+
+	var one int
+	start := time.Now()
+	err = db.QueryRow("SELECT 1").Scan(&one)
+	if err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't execute query: %s", err))
+	}
+	rtt := float64(time.Since(start)) / float64(time.Millisecond)
+
+	checkSt := checkers.OK
+	msg := fmt.Sprintf("SELECT 1 in %.3f ms", rtt)
+	if opts.Crit > 0 && rtt > opts.Crit {
+		checkSt = checkers.CRITICAL
+	} else if opts.Warn > 0 && rtt > opts.Warn {
+		checkSt = checkers.WARNING
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
@@ -1,6 +1,7 @@
 package checkmysql
 
 import (
+	"database/sql"
 	"fmt"
 	"os"
 
@@ -10,6 +11,53 @@ import (
 
 type readOnlyOpts struct {
 	mysqlSetting
+	ExpectReadOnly string `long:"expect-readonly" choice:"true" choice:"false" description:"critical if the server is not read-only (true) or is read-only (false). report the mode only if not specified"`
+}
+
+// readOnlyMode holds the values of @@read_only and @@super_read_only.
+// super_read_only is not available on MySQL before 5.7.8 and on MariaDB.
+type readOnlyMode struct {
+	readOnly      bool
+	superReadOnly sql.NullBool
+}
+
+func onOff(b bool) string {
+	if b {
+		return "ON"
+	}
+	return "OFF"
+}
+
+func (m readOnlyMode) String() string {
+	s := fmt.Sprintf("read_only: %s", onOff(m.readOnly))
+	if m.superReadOnly.Valid {
+		s += fmt.Sprintf(", super_read_only: %s", onOff(m.superReadOnly.Bool))
+	}
+	return s
+}
+
+func getReadOnlyMode(db *sql.DB) (readOnlyMode, error) {
+	var m readOnlyMode
+	err := db.QueryRow("SELECT @@read_only, @@super_read_only").Scan(&m.readOnly, &m.superReadOnly)
+	if err != nil {
+		m.superReadOnly = sql.NullBool{}
+		err = db.QueryRow("SELECT @@read_only").Scan(&m.readOnly)
+	}
+	return m, err
+}
+
+func evalReadOnly(m readOnlyMode, expect string) *checkers.Checker {
+	mode := "read-write"
+	if m.readOnly {
+		mode = "read-only"
+	}
+	switch {
+	case expect == "true" && !m.readOnly:
+		return checkers.Critical(fmt.Sprintf("the server is expected to be read-only, but is read-write (%s)", m))
+	case expect == "false" && m.readOnly:
+		return checkers.Critical(fmt.Sprintf("the server is expected to be read-write, but is read-only (%s)", m))
+	}
+	return checkers.Ok(fmt.Sprintf("the server is %s (%s)", mode, m))
 }
 
 func checkReadOnly(args []string) *checkers.Checker {
@@ -20,31 +68,36 @@ func checkReadOnly(args []string) *checkers.Checker {
 	if err != nil {
 		os.Exit(1)
 	}
-	if len(args) != 1 {
+	if len(args) > 1 {
 		fmt.Println("wrong number of arguments")
 		os.Exit(1)
 	}
-	argStatus := args[0]
+	// the positional ON|OFF argument is kept for compatibility
+	expect := opts.ExpectReadOnly
+	if len(args) == 1 {
+		switch args[0] {
+		case "ON":
+			expect = "true"
+		case "OFF":
+			expect = "false"
+		default:
+			fmt.Printf("invalid argument: %s (must be ON or OFF)\n", args[0])
+			os.Exit(1)
+		}
+	}
 
-	db := newMySQL(opts.mysqlSetting)
-	err = db.Connect()
+	db, err := newMySQL(opts.mysqlSetting)
+	if err == errNoTLS {
+		return checkers.Warning(err.Error())
+	}
 	if err != nil {
 		return checkers.Unknown("couldn't connect DB")
 	}
 	defer db.Close()
 
-	rows, res, err := db.Query("SHOW GLOBAL VARIABLES LIKE 'read_only'")
+	m, err := getReadOnlyMode(db)
 	if err != nil {
 		return checkers.Unknown("couldn't execute query")
 	}
-
-	idxReadOnly := res.Map("Value")
-	readOnlyStatus := rows[0].Str(idxReadOnly)
-
-	if readOnlyStatus != argStatus {
-		msg := fmt.Sprintf("the expected value of read_only is different. readOnlyStatus:%s", readOnlyStatus)
-		return checkers.Critical(msg)
-	}
-
-	return checkers.Ok("read_only is the expected value")
+	return evalReadOnly(m, expect)
 }
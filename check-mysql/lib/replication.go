@@ -1,8 +1,11 @@
 package checkmysql
 
 import (
+	"database/sql"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/mackerelio/checkers"
@@ -10,8 +13,61 @@ import (
 
 type replicationOpts struct {
 	mysqlSetting
-	Crit int64 `short:"c" long:"critical" default:"250" description:"critical if the seconds behind master is over"`
-	Warn int64 `short:"w" long:"warning" default:"200" description:"warning if the seconds behind master is over"`
+	Crit     int64  `short:"c" long:"critical" default:"250" description:"critical if the seconds behind master is over"`
+	Warn     int64  `short:"w" long:"warning" default:"200" description:"warning if the seconds behind master is over"`
+	ReplCrit *int64 `long:"replication-critical" value-name:"SECONDS" description:"same as --critical, takes precedence over it"`
+	ReplWarn *int64 `long:"replication-warning" value-name:"SECONDS" description:"same as --warning, takes precedence over it"`
+}
+
+// replicationColumns holds the column names of the replication status
+// statement, which were renamed along with the statement in MySQL 8.0.22
+type replicationColumns struct {
+	query         string
+	ioRunning     string
+	sqlRunning    string
+	secondsBehind string
+}
+
+var (
+	slaveStatusColumns = replicationColumns{
+		query:         "SHOW SLAVE STATUS",
+		ioRunning:     "Slave_IO_Running",
+		sqlRunning:    "Slave_SQL_Running",
+		secondsBehind: "Seconds_Behind_Master",
+	}
+	replicaStatusColumns = replicationColumns{
+		query:         "SHOW REPLICA STATUS",
+		ioRunning:     "Replica_IO_Running",
+		sqlRunning:    "Replica_SQL_Running",
+		secondsBehind: "Seconds_Behind_Source",
+	}
+)
+
+// hasReplicaStatus reports whether the server of the given VERSION() supports
+// SHOW REPLICA STATUS, i.e. it is MySQL 8.0.22 or later. MariaDB keeps the
+// old column names, so SHOW SLAVE STATUS is always used for it.
+func hasReplicaStatus(version string) bool {
+	if strings.Contains(strings.ToLower(version), "mariadb") {
+		return false
+	}
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	want := []int{8, 0, 22}
+	parts := strings.Split(version, ".")
+	for i, w := range want {
+		if i >= len(parts) {
+			return false
+		}
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return false
+		}
+		if n != w {
+			return n > w
+		}
+	}
+	return true
 }
 
 func checkReplication(args []string) *checkers.Checker {
@@ -22,38 +78,64 @@ func checkReplication(args []string) *checkers.Checker {
 	if err != nil {
 		os.Exit(1)
 	}
-	db := newMySQL(opts.mysqlSetting)
-	err = db.Connect()
+	if opts.ReplCrit != nil {
+		opts.Crit = *opts.ReplCrit
+	}
+	if opts.ReplWarn != nil {
+		opts.Warn = *opts.ReplWarn
+	}
+	db, err := newMySQL(opts.mysqlSetting)
+	if err == errNoTLS {
+		return checkers.Warning(err.Error())
+	}
 	if err != nil {
 		return checkers.Unknown("couldn't connect DB")
 	}
 	defer db.Close()
 
-	rows, res, err := db.Query("SHOW SLAVE STATUS")
+	var version string
+	if err := db.QueryRow("SELECT VERSION()").Scan(&version); err != nil {
+		return checkers.Unknown("couldn't execute query")
+	}
+	cols := slaveStatusColumns
+	if hasReplicaStatus(version) {
+		cols = replicaStatusColumns
+	}
+
+	row, err := queryRow(db, cols.query)
 	if err != nil {
 		return checkers.Unknown("couldn't execute query")
 	}
 
-	if len(rows) == 0 {
+	if row == nil {
 		return checkers.Ok("MySQL is not slave")
 	}
+	return evalReplication(row, cols, opts.Warn, opts.Crit)
+}
 
-	idxIoThreadRunning := res.Map("Slave_IO_Running")
-	idxSQLThreadRunning := res.Map("Slave_SQL_Running")
-	idxSecondsBehindMaster := res.Map("Seconds_Behind_Master")
-	ioThreadStatus := rows[0].Str(idxIoThreadRunning)
-	sqlThreadStatus := rows[0].Str(idxSQLThreadRunning)
-	secondsBehindMaster := rows[0].Int64(idxSecondsBehindMaster)
+func evalReplication(row map[string]sql.NullString, cols replicationColumns, warn, crit int64) *checkers.Checker {
+	ioThreadStatus := row[cols.ioRunning].String
+	sqlThreadStatus := row[cols.sqlRunning].String
+	threads := fmt.Sprintf("IO thread: %s, SQL thread: %s", ioThreadStatus, sqlThreadStatus)
 
 	if !(ioThreadStatus == "Yes" && sqlThreadStatus == "Yes") {
-		return checkers.Critical("MySQL replication has been stopped")
+		return checkers.Critical(fmt.Sprintf("MySQL replication has been stopped (%s)", threads))
+	}
+
+	behind := row[cols.secondsBehind]
+	if !behind.Valid {
+		return checkers.Critical(fmt.Sprintf("MySQL replication behind master is NULL (%s)", threads))
+	}
+	secondsBehindMaster, err := strconv.ParseInt(behind.String, 10, 64)
+	if err != nil {
+		return checkers.Unknown(err.Error())
 	}
 
 	checkSt := checkers.OK
-	msg := fmt.Sprintf("MySQL replication behind master %d seconds", secondsBehindMaster)
-	if secondsBehindMaster > opts.Crit {
+	msg := fmt.Sprintf("MySQL replication behind master %d seconds (%s)", secondsBehindMaster, threads)
+	if secondsBehindMaster > crit {
 		checkSt = checkers.CRITICAL
-	} else if secondsBehindMaster > opts.Warn {
+	} else if secondsBehindMaster > warn {
 		checkSt = checkers.WARNING
 	}
 	return checkers.NewChecker(checkSt, msg)
@@ -3,6 +3,7 @@ package checkmysql
 import (
 	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/mackerelio/checkers"
@@ -30,20 +31,23 @@ func checkUptime(args []string) *checkers.Checker {
 	if err != nil {
 		os.Exit(1)
 	}
-	db := newMySQL(opts.mysqlSetting)
-	err = db.Connect()
+	db, err := newMySQL(opts.mysqlSetting)
+	if err == errNoTLS {
+		return checkers.Warning(err.Error())
+	}
 	if err != nil {
 		return checkers.Unknown("couldn't connect DB")
 	}
 	defer db.Close()
 
-	rows, res, err := db.Query("SHOW GLOBAL STATUS LIKE 'Uptime'")
+	value, err := queryValue(db, "SHOW GLOBAL STATUS LIKE 'Uptime'")
 	if err != nil {
 		return checkers.Unknown("couldn't execute query")
 	}
-
-	idxValue := res.Map("Value")
-	upTime := rows[0].Int64(idxValue)
+	upTime, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
 
 	checkSt := checkers.OK
 	msg := fmt.Sprintf("up %s", uptime2str(upTime))
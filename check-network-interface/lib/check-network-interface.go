@@ -0,0 +1,224 @@
+package checknetworkinterface
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+type networkInterfaceOpts struct {
+	Interfaces        []string `short:"i" long:"interface" value-name:"NAME" description:"Interface name to check, repeatable (default: all the physical interfaces)"`
+	IncludeVirtual    bool     `long:"include-virtual" description:"Check the virtual interfaces too, without --interface"`
+	ErrorRateWarning  *float64 `long:"error-rate-warning" value-name:"N" description:"warning if the errors per second are over"`
+	ErrorRateCritical *float64 `long:"error-rate-critical" value-name:"N" description:"critical if the errors per second are over"`
+	DropRateWarning   *float64 `long:"drop-rate-warning" value-name:"N" description:"warning if the drops per second are over"`
+	DropRateCritical  *float64 `long:"drop-rate-critical" value-name:"N" description:"critical if the drops per second are over"`
+	BandwidthWarning  *float64 `long:"bandwidth-warning" value-name:"PERCENT" description:"warning if the bandwidth usage of the link speed is over"`
+	BandwidthCritical *float64 `long:"bandwidth-critical" value-name:"PERCENT" description:"critical if the bandwidth usage of the link speed is over"`
+	SampleInterval    float64  `long:"sample-interval" value-name:"SECONDS" default:"1" description:"Interval between the two samples"`
+}
+
+// Do the plugin
+func Do() {
+	ckr := run(os.Args[1:])
+	ckr.Name = "NetworkInterface"
+	ckr.Exit()
+}
+
+// ifaceStat is the counters of an interface in /proc/net/dev
+type ifaceStat struct {
+	rxBytes uint64
+	rxErrs  uint64
+	rxDrop  uint64
+	txBytes uint64
+	txErrs  uint64
+	txDrop  uint64
+}
+
+// parseNetDev parses the content of /proc/net/dev
+func parseNetDev(r io.Reader) (map[string]*ifaceStat, error) {
+	stats := make(map[string]*ifaceStat)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			// header lines
+			continue
+		}
+		name := strings.TrimSpace(line[:i])
+		fields := strings.Fields(line[i+1:])
+		if len(fields) < 16 {
+			return nil, fmt.Errorf("unexpected format of the interface %s: %q", name, line)
+		}
+		var values [16]uint64
+		for j := range values {
+			v, err := strconv.ParseUint(fields[j], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected format of the interface %s: %s", name, err)
+			}
+			values[j] = v
+		}
+		stats[name] = &ifaceStat{
+			rxBytes: values[0],
+			rxErrs:  values[2],
+			rxDrop:  values[3],
+			txBytes: values[8],
+			txErrs:  values[10],
+			txDrop:  values[11],
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// ifaceRate is the rates per second of an interface between two samples
+type ifaceRate struct {
+	name         string
+	rxErrs       float64
+	txErrs       float64
+	rxDrop       float64
+	txDrop       float64
+	rxBitsPerSec float64
+	txBitsPerSec float64
+	// link speed in Mbps, zero if unknown
+	speed float64
+}
+
+func perSecond(before, after uint64, interval float64) float64 {
+	if after < before {
+		// the counter has been reset
+		return 0
+	}
+	return float64(after-before) / interval
+}
+
+func calcRate(name string, before, after *ifaceStat, interval float64) *ifaceRate {
+	return &ifaceRate{
+		name:         name,
+		rxErrs:       perSecond(before.rxErrs, after.rxErrs, interval),
+		txErrs:       perSecond(before.txErrs, after.txErrs, interval),
+		rxDrop:       perSecond(before.rxDrop, after.rxDrop, interval),
+		txDrop:       perSecond(before.txDrop, after.txDrop, interval),
+		rxBitsPerSec: perSecond(before.rxBytes, after.rxBytes, interval) * 8,
+		txBitsPerSec: perSecond(before.txBytes, after.txBytes, interval) * 8,
+	}
+}
+
+func evalThreshold(v float64, warning, critical *float64) checkers.Status {
+	if critical != nil && v > *critical {
+		return checkers.CRITICAL
+	}
+	if warning != nil && v > *warning {
+		return checkers.WARNING
+	}
+	return checkers.OK
+}
+
+func worse(a, b checkers.Status) checkers.Status {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func evalRates(opts *networkInterfaceOpts, rates []*ifaceRate) *checkers.Checker {
+	checkSt := checkers.OK
+	msgs := make([]string, 0, len(rates))
+	for _, r := range rates {
+		st := evalThreshold(r.rxErrs, opts.ErrorRateWarning, opts.ErrorRateCritical)
+		st = worse(st, evalThreshold(r.txErrs, opts.ErrorRateWarning, opts.ErrorRateCritical))
+		st = worse(st, evalThreshold(r.rxDrop, opts.DropRateWarning, opts.DropRateCritical))
+		st = worse(st, evalThreshold(r.txDrop, opts.DropRateWarning, opts.DropRateCritical))
+		msg := fmt.Sprintf("%s: errors rx %.2f/s tx %.2f/s, drops rx %.2f/s tx %.2f/s",
+			r.name, r.rxErrs, r.txErrs, r.rxDrop, r.txDrop)
+		if r.speed > 0 {
+			rxUsage := r.rxBitsPerSec / (r.speed * 1000 * 1000) * 100
+			txUsage := r.txBitsPerSec / (r.speed * 1000 * 1000) * 100
+			st = worse(st, evalThreshold(rxUsage, opts.BandwidthWarning, opts.BandwidthCritical))
+			st = worse(st, evalThreshold(txUsage, opts.BandwidthWarning, opts.BandwidthCritical))
+			msg += fmt.Sprintf(", bandwidth rx %.2f%% tx %.2f%% of %.0f Mbps", rxUsage, txUsage, r.speed)
+		}
+		if st != checkers.OK {
+			msg += fmt.Sprintf(" (%s)", st)
+		}
+		checkSt = worse(checkSt, st)
+		msgs = append(msgs, msg)
+	}
+	return checkers.NewChecker(checkSt, strings.Join(msgs, "\n"))
+}
+
+// targetInterfaces returns the names of the interfaces to check
+func targetInterfaces(opts *networkInterfaceOpts, stats map[string]*ifaceStat) ([]string, error) {
+	if len(opts.Interfaces) > 0 {
+		for _, name := range opts.Interfaces {
+			if _, ok := stats[name]; !ok {
+				return nil, fmt.Errorf("interface %s is not found", name)
+			}
+		}
+		return opts.Interfaces, nil
+	}
+	var names []string
+	for name := range stats {
+		if name == "lo" || (!opts.IncludeVirtual && isVirtual(name)) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func run(args []string) *checkers.Checker {
+	opts := networkInterfaceOpts{}
+	_, err := flags.ParseArgs(&opts, args)
+	if err != nil {
+		os.Exit(1)
+	}
+	if opts.SampleInterval <= 0 {
+		return checkers.Unknown("--sample-interval must be positive")
+	}
+
+	before, err := readNetDev()
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	names, err := targetInterfaces(&opts, before)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	if len(names) == 0 {
+		return checkers.Unknown("no interfaces to check")
+	}
+	start := time.Now()
+	time.Sleep(time.Duration(opts.SampleInterval * float64(time.Second)))
+	after, err := readNetDev()
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	interval := time.Since(start).Seconds()
+
+	rates := make([]*ifaceRate, 0, len(names))
+	for _, name := range names {
+		a, ok := after[name]
+		if !ok {
+			return checkers.Unknown(fmt.Sprintf("interface %s has disappeared", name))
+		}
+		r := calcRate(name, before[name], a, interval)
+		if opts.BandwidthWarning != nil || opts.BandwidthCritical != nil {
+			r.speed = linkSpeed(name)
+		}
+		rates = append(rates, r)
+	}
+	return evalRates(&opts, rates)
+}
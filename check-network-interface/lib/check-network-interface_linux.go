@@ -0,0 +1,43 @@
+package checknetworkinterface
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func readNetDev() (map[string]*ifaceStat, error) {
+	f, err := os.Open("/proc/net/dev")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseNetDev(f)
+}
+
+// isVirtual reports whether the interface is a virtual one, such as a
+// bridge, veth or tun, which has no device under /sys/devices except virtual.
+func isVirtual(name string) bool {
+	path, err := filepath.EvalSymlinks(filepath.Join("/sys/class/net", name))
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(path, "/sys/devices/virtual/")
+}
+
+// linkSpeed returns the link speed in Mbps, or zero if unknown
+func linkSpeed(name string) float64 {
+	b, err := ioutil.ReadFile(filepath.Join("/sys/class/net", name, "speed"))
+	if err != nil {
+		// reading speed fails with EINVAL if the link is down
+		return 0
+	}
+	speed, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64)
+	if err != nil || speed <= 0 {
+		// some drivers report -1 for unknown speed
+		return 0
+	}
+	return speed
+}
@@ -0,0 +1,20 @@
+// +build !linux
+
+package checknetworkinterface
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func readNetDev() (map[string]*ifaceStat, error) {
+	return nil, fmt.Errorf("check-network-interface is not supported on %s", runtime.GOOS)
+}
+
+func isVirtual(name string) bool {
+	return false
+}
+
+func linkSpeed(name string) float64 {
+	return 0
+}
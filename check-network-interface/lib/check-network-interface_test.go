@@ -0,0 +1,99 @@
+package checknetworkinterface
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+const netDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: 1234567    8901    0    0    0     0          0         0  1234567    8901    0    0    0     0       0          0
+  eth0: 987654321 654321   12    3    0     0          0        10 123456789 321098    4    5    0     0       0          0
+`
+
+func TestParseNetDev(t *testing.T) {
+	stats, err := parseNetDev(strings.NewReader(netDev))
+	assert.Nil(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, &ifaceStat{
+		rxBytes: 987654321,
+		rxErrs:  12,
+		rxDrop:  3,
+		txBytes: 123456789,
+		txErrs:  4,
+		txDrop:  5,
+	}, stats["eth0"])
+
+	_, err = parseNetDev(strings.NewReader("  eth0: 1 2 3\n"))
+	assert.NotNil(t, err)
+}
+
+func TestCalcRate(t *testing.T) {
+	before := &ifaceStat{rxBytes: 1000, rxErrs: 10, rxDrop: 0, txBytes: 500, txErrs: 5, txDrop: 100}
+	after := &ifaceStat{rxBytes: 3000, rxErrs: 14, rxDrop: 2, txBytes: 1500, txErrs: 5, txDrop: 0}
+	r := calcRate("eth0", before, after, 2)
+	assert.Equal(t, &ifaceRate{
+		name:         "eth0",
+		rxErrs:       2,
+		txErrs:       0,
+		rxDrop:       1,
+		txDrop:       0,
+		rxBitsPerSec: 8000,
+		txBitsPerSec: 4000,
+	}, r)
+}
+
+func TestEvalRates(t *testing.T) {
+	one, ten := 1.0, 10.0
+	warn, crit := 70.0, 90.0
+	opts := &networkInterfaceOpts{
+		ErrorRateWarning:  &one,
+		ErrorRateCritical: &ten,
+		DropRateWarning:   &one,
+		BandwidthWarning:  &warn,
+		BandwidthCritical: &crit,
+	}
+
+	ckr := evalRates(opts, []*ifaceRate{
+		{name: "eth0", rxBitsPerSec: 100 * 1000 * 1000, speed: 1000},
+		{name: "eth1"},
+	})
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "eth0: errors rx 0.00/s tx 0.00/s, drops rx 0.00/s tx 0.00/s, bandwidth rx 10.00% tx 0.00% of 1000 Mbps\neth1: errors rx 0.00/s tx 0.00/s, drops rx 0.00/s tx 0.00/s", ckr.Message)
+
+	ckr = evalRates(opts, []*ifaceRate{
+		{name: "eth0", txDrop: 1.5},
+		{name: "eth1"},
+	})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "eth0: errors rx 0.00/s tx 0.00/s, drops rx 0.00/s tx 1.50/s (WARNING)\neth1: errors rx 0.00/s tx 0.00/s, drops rx 0.00/s tx 0.00/s", ckr.Message)
+
+	ckr = evalRates(opts, []*ifaceRate{
+		{name: "eth0", txDrop: 1.5},
+		{name: "eth1", rxErrs: 20},
+	})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = evalRates(opts, []*ifaceRate{
+		{name: "eth0", txBitsPerSec: 950 * 1000 * 1000, speed: 1000},
+	})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "eth0: errors rx 0.00/s tx 0.00/s, drops rx 0.00/s tx 0.00/s, bandwidth rx 0.00% tx 95.00% of 1000 Mbps (CRITICAL)", ckr.Message)
+}
+
+func TestTargetInterfaces(t *testing.T) {
+	stats := map[string]*ifaceStat{"lo": {}, "eth1": {}, "eth0": {}}
+	names, err := targetInterfaces(&networkInterfaceOpts{IncludeVirtual: true}, stats)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"eth0", "eth1"}, names)
+
+	names, err = targetInterfaces(&networkInterfaceOpts{Interfaces: []string{"eth1"}}, stats)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"eth1"}, names)
+
+	_, err = targetInterfaces(&networkInterfaceOpts{Interfaces: []string{"eth2"}}, stats)
+	assert.NotNil(t, err)
+}
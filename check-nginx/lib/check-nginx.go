@@ -0,0 +1,143 @@
+package checknginx
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+type nginxOpts struct {
+	URL                 string   `short:"u" long:"url" default:"http://localhost/nginx_status" description:"URL of the stub_status page"`
+	Timeout             int      `long:"timeout" value-name:"SECONDS" default:"10" description:"Timeout in seconds"`
+	Warning             *int64   `short:"w" long:"warning" value-name:"CONNECTIONS" description:"warning if the active connections are over"`
+	Critical            *int64   `short:"c" long:"critical" value-name:"CONNECTIONS" description:"critical if the active connections are over"`
+	RequestRateWarning  *float64 `long:"request-rate-warning" value-name:"REQ/SEC" description:"warning if the request rate is over"`
+	RequestRateCritical *float64 `long:"request-rate-critical" value-name:"REQ/SEC" description:"critical if the request rate is over"`
+	SampleInterval      float64  `long:"sample-interval" value-name:"SECONDS" default:"1" description:"Interval of the two samples to compute the request rate"`
+}
+
+// Do the plugin
+func Do() {
+	ckr := run(os.Args[1:])
+	ckr.Name = "Nginx"
+	ckr.Exit()
+}
+
+type stubStatus struct {
+	active   int64
+	accepts  int64
+	handled  int64
+	requests int64
+	reading  int64
+	writing  int64
+	waiting  int64
+}
+
+var stubStatusReg = regexp.MustCompile(`(?s)Active connections:\s*(\d+).*?(\d+)\s+(\d+)\s+(\d+)\s*Reading:\s*(\d+)\s*Writing:\s*(\d+)\s*Waiting:\s*(\d+)`)
+
+// parseStubStatus parses the page like:
+//
+//	Active connections: 291
+//	server accepts handled requests
+//	 16630948 16630948 31070465
+//	Reading: 6 Writing: 179 Waiting: 106
+func parseStubStatus(body string) (*stubStatus, error) {
+	m := stubStatusReg.FindStringSubmatch(body)
+	if m == nil {
+		return nil, fmt.Errorf("unexpected stub_status: %q", body)
+	}
+	var values [7]int64
+	for i := range values {
+		v, err := strconv.ParseInt(m[i+1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return &stubStatus{
+		active:   values[0],
+		accepts:  values[1],
+		handled:  values[2],
+		requests: values[3],
+		reading:  values[4],
+		writing:  values[5],
+		waiting:  values[6],
+	}, nil
+}
+
+func fetchStubStatus(client *http.Client, url string) (*stubStatus, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return nil, err
+	}
+	return parseStubStatus(string(b))
+}
+
+// evalStatus checks the status. rate is nil unless the request rate is
+// measured.
+func evalStatus(opts *nginxOpts, st *stubStatus, rate *float64) *checkers.Checker {
+	checkSt := checkers.OK
+	if opts.Critical != nil && st.active > *opts.Critical ||
+		rate != nil && opts.RequestRateCritical != nil && *rate > *opts.RequestRateCritical {
+		checkSt = checkers.CRITICAL
+	} else if opts.Warning != nil && st.active > *opts.Warning ||
+		rate != nil && opts.RequestRateWarning != nil && *rate > *opts.RequestRateWarning {
+		checkSt = checkers.WARNING
+	}
+	msg := fmt.Sprintf("active connections %d (reading %d, writing %d, waiting %d), accepts %d, handled %d, requests %d",
+		st.active, st.reading, st.writing, st.waiting, st.accepts, st.handled, st.requests)
+	if rate != nil {
+		msg += fmt.Sprintf(", %.2f req/s", *rate)
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func run(args []string) *checkers.Checker {
+	opts := nginxOpts{}
+	_, err := flags.ParseArgs(&opts, args)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	client := &http.Client{Timeout: time.Duration(opts.Timeout) * time.Second}
+	st, err := fetchStubStatus(client, opts.URL)
+	if err != nil {
+		return checkers.Critical(err.Error())
+	}
+
+	var rate *float64
+	if opts.RequestRateWarning != nil || opts.RequestRateCritical != nil {
+		if opts.SampleInterval <= 0 {
+			return checkers.Unknown("--sample-interval must be positive")
+		}
+		start := time.Now()
+		time.Sleep(time.Duration(opts.SampleInterval * float64(time.Second)))
+		st2, err := fetchStubStatus(client, opts.URL)
+		if err != nil {
+			return checkers.Critical(err.Error())
+		}
+		r := float64(st2.requests-st.requests) / time.Since(start).Seconds()
+		if r < 0 {
+			// nginx has been restarted
+			r = 0
+		}
+		st, rate = st2, &r
+	}
+	return evalStatus(&opts, st, rate)
+}
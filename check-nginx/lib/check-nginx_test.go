@@ -0,0 +1,71 @@
+package checknginx
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+const stubStatusPage = `Active connections: 291 
+server accepts handled requests
+ 16630948 16630948 31070465 
+Reading: 6 Writing: 179 Waiting: 106 
+`
+
+func TestParseStubStatus(t *testing.T) {
+	st, err := parseStubStatus(stubStatusPage)
+	assert.Nil(t, err)
+	assert.Equal(t, &stubStatus{
+		active:   291,
+		accepts:  16630948,
+		handled:  16630948,
+		requests: 31070465,
+		reading:  6,
+		writing:  179,
+		waiting:  106,
+	}, st)
+
+	_, err = parseStubStatus("<html>Not Found</html>")
+	assert.NotNil(t, err)
+}
+
+func TestEvalStatus(t *testing.T) {
+	st, _ := parseStubStatus(stubStatusPage)
+	warn, crit := int64(200), int64(500)
+	ckr := evalStatus(&nginxOpts{Warning: &warn, Critical: &crit}, st, nil)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "active connections 291 (reading 6, writing 179, waiting 106), accepts 16630948, handled 16630948, requests 31070465", ckr.Message)
+
+	rate, rateCrit := 120.0, 100.0
+	ckr = evalStatus(&nginxOpts{RequestRateCritical: &rateCrit}, st, &rate)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Regexp(t, `, 120.00 req/s$`, ckr.Message)
+}
+
+func TestRun(t *testing.T) {
+	var requests int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/nginx_status" {
+			http.NotFound(w, r)
+			return
+		}
+		n := atomic.AddInt64(&requests, 100)
+		fmt.Fprintf(w, "Active connections: 1 \nserver accepts handled requests\n 10 10 %d \nReading: 0 Writing: 1 Waiting: 0 \n", n)
+	}))
+	defer ts.Close()
+
+	ckr := run([]string{"--url", ts.URL + "/nginx_status", "--warning", "0"})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	ckr = run([]string{"--url", ts.URL + "/nginx_status", "--request-rate-warning", "10", "--request-rate-critical", "100000", "--sample-interval", "0.1"})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Regexp(t, `, requests 300, [\d.]+ req/s$`, ckr.Message)
+
+	ckr = run([]string{"--url", ts.URL + "/status"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+}
@@ -0,0 +1,7 @@
+package main
+
+import "github.com/mackerelio/go-check-plugins/check-nginx/lib"
+
+func main() {
+	checknginx.Do()
+}
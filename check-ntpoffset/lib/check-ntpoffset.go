@@ -23,6 +23,16 @@ var opts struct {
 	NTPServers   string  `short:"s" long:"ntp-servers" default:"" description:"Use specified NTP Servers(plural servers can be set separated by ,). When set plural servers, use first response. If not set, use local command just like ntpd/chronyd."`
 	NTPTimeout   int     `short:"t" long:"ntp-timeout" default:"15" description:"Timeout of NTP Server Querying(in seconds)."`
 	CheckStratum bool    `short:"S" long:"check-stratum" description:"Check stratum and fail if the machine is not synchronized."`
+	StratumWarn  *int    `long:"stratum-warning" value-name:"N" description:"Warning if the stratum of the NTP server is over N (with --ntp-servers)"`
+	StratumCrit  *int    `long:"stratum-critical" value-name:"N" description:"Critical if the stratum of the NTP server is over N (with --ntp-servers)"`
+}
+
+// ntpServerResult is the response of the NTP server
+type ntpServerResult struct {
+	server  string
+	offset  float64
+	stratum int
+	refID   string
 }
 
 var ntpTimeout int
@@ -45,7 +55,20 @@ func run(args []string) *checkers.Checker {
 	}
 	ntpTimeout = opts.NTPTimeout
 
-	offset, err := getNTPOffset(opts.NTPServers, opts.CheckStratum)
+	if opts.NTPServers == "" && (opts.StratumWarn != nil || opts.StratumCrit != nil) {
+		return checkers.Unknown("--stratum-warning and --stratum-critical require --ntp-servers")
+	}
+
+	var offset float64
+	var server *ntpServerResult
+	if opts.NTPServers != "" {
+		server, err = getNTPOffsetFromNTPServers(opts.NTPServers)
+		if err == nil {
+			offset = server.offset
+		}
+	} else {
+		offset, err = getNTPOffset(opts.CheckStratum)
+	}
 	if err != nil {
 		return checkers.Unknown(err.Error())
 	}
@@ -63,6 +86,18 @@ func run(args []string) *checkers.Checker {
 		chkSt = checkers.OK
 	}
 
+	if server != nil {
+		stratumSt := checkers.OK
+		if opts.StratumCrit != nil && server.stratum > *opts.StratumCrit {
+			stratumSt = checkers.CRITICAL
+		} else if opts.StratumWarn != nil && server.stratum > *opts.StratumWarn {
+			stratumSt = checkers.WARNING
+		}
+		if stratumSt > chkSt {
+			chkSt = stratumSt
+		}
+		msg += fmt.Sprintf("; server %s, stratum %d, reference ID %s", server.server, server.stratum, server.refID)
+	}
 	return checkers.NewChecker(chkSt, msg)
 }
 
@@ -115,11 +150,7 @@ func detectNTPDname() (ntpdName string, err error) {
 	return ntpdName, err
 }
 
-func getNTPOffset(ntpServers string, checkStratum bool) (float64, error) {
-	if ntpServers != "" {
-		return getNTPOffsetFromNTPServers(ntpServers)
-	}
-
+func getNTPOffset(checkStratum bool) (float64, error) {
 	ntpdName, err := detectNTPDname()
 	if err != nil {
 		return 0.0, err
@@ -137,8 +168,8 @@ func getNTPOffset(ntpServers string, checkStratum bool) (float64, error) {
 // Use first response, ignore others
 //
 // FIXME need fluent cancel mechanism
-func getNTPOffsetFromNTPServers(ntpServers string) (offset float64, err error) {
-	resultChan := make(chan float64)
+func getNTPOffsetFromNTPServers(ntpServers string) (*ntpServerResult, error) {
+	resultChan := make(chan *ntpServerResult)
 	for _, ntpServer := range strings.Split(ntpServers, ",") {
 		go func(ntpServer string) error {
 			ntpServer = strings.Trim(ntpServer, " ")
@@ -147,7 +178,12 @@ func getNTPOffsetFromNTPServers(ntpServers string) (offset float64, err error) {
 			if err != nil {
 				return err
 			}
-			resultChan <- float64(response.ClockOffset / time.Millisecond)
+			resultChan <- &ntpServerResult{
+				server:  ntpServer,
+				offset:  float64(response.ClockOffset / time.Millisecond),
+				stratum: int(response.Stratum),
+				refID:   formatReferenceID(response.Stratum, response.ReferenceID),
+			}
 			return nil
 		}(ntpServer)
 	}
@@ -155,10 +191,21 @@ func getNTPOffsetFromNTPServers(ntpServers string) (offset float64, err error) {
 	select {
 	case <-time.After(time.Duration(ntpTimeout) * time.Second):
 		// return error only when all NTPServers are failed
-		return 0.0, fmt.Errorf("NTP offset cannot get from %q", ntpServers)
-	case offset = <-resultChan:
-		return offset, nil
+		return nil, fmt.Errorf("NTP offset cannot get from %q", ntpServers)
+	case result := <-resultChan:
+		return result, nil
+	}
+}
+
+// formatReferenceID returns the reference ID, which is the code of the
+// reference clock like "GPS" for stratum 0 or 1, and the IPv4 address of the
+// upstream server otherwise
+func formatReferenceID(stratum uint8, id uint32) string {
+	b := []byte{byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}
+	if stratum <= 1 {
+		return strings.TrimRight(string(b), "\x00")
 	}
+	return fmt.Sprintf("%d.%d.%d.%d", b[0], b[1], b[2], b[3])
 }
 
 func getNTPOffsetFromNTPD(checkStratum bool) (offset float64, err error) {
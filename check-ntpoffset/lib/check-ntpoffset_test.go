@@ -167,3 +167,20 @@ stratum=16, offset=0.000
 		})
 	}
 }
+
+func TestFormatReferenceID(t *testing.T) {
+	testCases := []struct {
+		stratum uint8
+		id      uint32
+		expect  string
+	}{
+		{1, 0x47505300, "GPS"},
+		{0, 0x52415445, "RATE"},
+		{2, 0xc0000201, "192.0.2.1"},
+	}
+	for _, tc := range testCases {
+		if got := formatReferenceID(tc.stratum, tc.id); got != tc.expect {
+			t.Errorf("formatReferenceID(%d, %x) = %q, want %q", tc.stratum, tc.id, got, tc.expect)
+		}
+	}
+}
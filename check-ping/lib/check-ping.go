@@ -1,8 +1,14 @@
 package checkping
 
 import (
+	"context"
+	"fmt"
+	"math"
 	"net"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	flags "github.com/jessevdk/go-flags"
@@ -10,57 +16,395 @@ import (
 	ping "github.com/tatsushid/go-fastping"
 )
 
-var opts struct {
-	Host     string `long:"host" short:"H" description:"check target IP Address"`
-	Count    int    `long:"count" short:"n" default:"1" description:"sending (and receiving) count ping packets"`
-	WaitTime int    `long:"wait-time" short:"w" default:"1000" description:"wait time, Max RTT(ms)"`
+type pingOpts struct {
+	Hosts          []string      `long:"host" short:"H" description:"check target IP Address (may be repeated or comma-separated)"`
+	Count          int           `long:"count" short:"n" default:"1" description:"sending (and receiving) count ping packets"`
+	WaitTime       int           `long:"wait-time" short:"w" default:"1000" description:"wait time, Max RTT(ms)"`
+	Size           int           `long:"packet-size" short:"s" default:"56" description:"ICMP payload size in bytes (8 - 65507)"`
+	DNSTimeout     time.Duration `long:"dns-timeout" default:"5s" description:"timeout of resolving the host name"`
+	Source         string        `long:"source" short:"S" value-name:"ADDRESS" description:"source IP address of ICMP packets"`
+	IPv6           bool          `long:"ipv6" short:"6" description:"use ICMPv6 even if the host name can be resolved to an IPv4 address"`
+	Warning        string        `long:"warning" value-name:"RTA,PL%" description:"warning threshold of average RTT(ms) and packet loss(%)"`
+	Critical       string        `long:"critical" value-name:"RTA,PL%" description:"critical threshold of average RTT(ms) and packet loss(%)"`
+	WarningJitter  float64       `long:"warning-jitter" value-name:"MS" description:"warning threshold of jitter(ms)"`
+	CriticalJitter float64       `long:"critical-jitter" value-name:"MS" description:"critical threshold of jitter(ms)"`
+	UseMax         bool          `long:"use-max-rtt" description:"compare the maximum RTT instead of the average one with the thresholds"`
+	Perfdata       bool          `long:"perfdata" description:"append performance data (rta, pl, rtmax and rtmin) to the output"`
+}
+
+// The payload must hold the timestamp and id go-fastping embeds (8 bytes), and
+// can not exceed the maximum IPv4 datagram minus the IP and ICMP headers.
+const (
+	minPacketSize = 8
+	maxPacketSize = 65507
+)
+
+type threshold struct {
+	rtt  time.Duration
+	loss float64
+}
+
+func parseThreshold(str string) (*threshold, error) {
+	if str == "" {
+		return nil, nil
+	}
+	values := strings.Split(str, ",")
+	if len(values) != 2 || !strings.HasSuffix(values[1], "%") {
+		return nil, fmt.Errorf("Invalid threshold: %s (should be RTA,PL%%)", str)
+	}
+	rtt, err := strconv.ParseFloat(values[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid threshold: %s (%s)", str, err)
+	}
+	loss, err := strconv.ParseFloat(strings.TrimSuffix(values[1], "%"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid threshold: %s (%s)", str, err)
+	}
+	return &threshold{
+		rtt:  time.Duration(rtt * float64(time.Millisecond)),
+		loss: loss,
+	}, nil
+}
+
+func (th *threshold) exceeded(rtt time.Duration, loss float64, received int) bool {
+	if th == nil {
+		return false
+	}
+	return loss > th.loss || (received > 0 && rtt > th.rtt)
 }
 
 func run(args []string) *checkers.Checker {
-	var parser = flags.NewParser(&opts, flags.Default)
+	opts := &pingOpts{}
+	var parser = flags.NewParser(opts, flags.Default)
 	_, err := parser.ParseArgs(args)
 	if err != nil {
 		os.Exit(1)
 	}
-	if opts.Host == "" {
+	hosts := splitHosts(opts.Hosts)
+	if len(hosts) == 0 {
 		parser.WriteHelp(os.Stderr)
 		os.Exit(1)
 	}
+	if opts.Count < 1 {
+		return checkers.Unknown("count should be greater than 0")
+	}
+	if opts.Size < minPacketSize || opts.Size > maxPacketSize {
+		return checkers.Unknown(fmt.Sprintf("packet size should be between %d and %d bytes: %d", minPacketSize, maxPacketSize, opts.Size))
+	}
+	if opts.Source != "" {
+		src, err := resolveSource(opts.Source)
+		if err != nil {
+			return checkers.Unknown(err.Error())
+		}
+		opts.Source = src
+	}
+	warning, err := parseThreshold(opts.Warning)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	critical, err := parseThreshold(opts.Critical)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
 
-	p := ping.NewPinger()
-	netProto := "ip4:icmp"
-	if isIPv6(opts.Host) {
-		netProto = "ip6:ipv6-icmp"
+	results := make([]*pingResult, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			results[i] = opts.pingHost(host, warning, critical)
+		}(i, host)
+	}
+	wg.Wait()
+
+	status := checkers.OK
+	var msgs, perfs []string
+	for _, r := range results {
+		if severity(r.status) > severity(status) {
+			status = r.status
+		}
+		if len(results) == 1 {
+			msgs = append(msgs, r.msg)
+		} else {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", r.host, r.msg))
+		}
+		if opts.Perfdata && r.perfdata {
+			prefix := ""
+			if len(results) > 1 {
+				prefix = r.host + "_"
+			}
+			perfs = append(perfs, perfdata(prefix, r.st, r.packetLoss, r.received, warning, critical))
+		}
+	}
+
+	msg := strings.Join(msgs, "\n")
+	if len(perfs) > 0 {
+		msg += "|" + strings.Join(perfs, " ")
+	}
+	return checkers.NewChecker(status, msg)
+}
+
+// runPinger sends one ping; tests replace it to fake replies
+var runPinger = func(p *ping.Pinger) error {
+	return p.Run()
+}
+
+// calcPacketLoss returns the percentage of lost packets. Convert to float
+// before dividing, or partial loss is rounded down to 0%.
+func calcPacketLoss(sent, received int) float64 {
+	return float64(sent-received) / float64(sent) * 100.0
+}
+
+// splitHosts accepts both repeated and comma-separated -H values
+func splitHosts(values []string) []string {
+	var hosts []string
+	for _, v := range values {
+		for _, host := range strings.Split(v, ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				hosts = append(hosts, host)
+			}
+		}
 	}
+	return hosts
+}
 
-	ra, err := net.ResolveIPAddr(netProto, opts.Host)
+// resolveSource resolves the source address and makes sure that it is
+// assigned to one of the interfaces of this host
+func resolveSource(source string) (string, error) {
+	addr, err := net.ResolveIPAddr("ip", source)
 	if err != nil {
-		os.Exit(1)
+		return "", err
+	}
+	ifaddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+	for _, ifaddr := range ifaddrs {
+		if ipnet, ok := ifaddr.(*net.IPNet); ok && ipnet.IP.Equal(addr.IP) {
+			return addr.IP.String(), nil
+		}
+	}
+	return "", fmt.Errorf("source address %s is not assigned to this host", source)
+}
+
+// severity orders statuses so that the worst one wins: CRITICAL > UNKNOWN > WARNING > OK
+func severity(st checkers.Status) int {
+	switch st {
+	case checkers.OK:
+		return 0
+	case checkers.WARNING:
+		return 1
+	case checkers.UNKNOWN:
+		return 2
+	default:
+		return 3
+	}
+}
+
+type pingResult struct {
+	host       string
+	status     checkers.Status
+	msg        string
+	st         rttStats
+	packetLoss float64
+	received   int
+	perfdata   bool
+}
+
+func (opts *pingOpts) pingHost(host string, warning, critical *threshold) *pingResult {
+	addrs, err := opts.lookupIPAddr(host)
+	if err == context.DeadlineExceeded {
+		return &pingResult{host: host, status: checkers.UNKNOWN, msg: fmt.Sprintf("DNS resolution timeout: %s", host)}
+	}
+	if err != nil {
+		return &pingResult{host: host, status: checkers.CRITICAL, msg: err.Error()}
 	}
+	ra := pickIPAddr(addrs, opts.IPv6)
+	if opts.IPv6 && ra == nil {
+		return &pingResult{host: host, status: checkers.UNKNOWN, msg: fmt.Sprintf("%s does not have any IPv6 address", host)}
+	}
+
+	p := ping.NewPinger()
 	p.AddIPAddr(ra)
 
 	status := checkers.CRITICAL
+	var recvs []time.Duration
+	p.Size = opts.Size
+	if opts.Source != "" {
+		if _, err := p.Source(opts.Source); err != nil {
+			return &pingResult{host: host, status: checkers.UNKNOWN, msg: err.Error()}
+		}
+	}
 	p.MaxRTT = time.Millisecond * time.Duration(opts.WaitTime)
-	p.OnRecv = func(_ *net.IPAddr, _ time.Duration) {
+	p.OnRecv = func(_ *net.IPAddr, rtt time.Duration) {
 		status = checkers.OK
+		recvs = append(recvs, rtt)
 	}
 
 	for i := 0; i < opts.Count; i++ {
-		err := p.Run()
+		err := runPinger(p)
 		if err != nil {
-			return checkers.NewChecker(status, err.Error())
+			return &pingResult{host: host, status: status, msg: err.Error()}
+		}
+	}
+
+	r := &pingResult{
+		host:       host,
+		st:         calcRTTStats(recvs),
+		packetLoss: calcPacketLoss(opts.Count, len(recvs)),
+		received:   len(recvs),
+		perfdata:   true,
+	}
+	rtt := r.st.avg
+	if opts.UseMax {
+		rtt = r.st.max
+	}
+	if critical.exceeded(rtt, r.packetLoss, r.received) {
+		status = checkers.CRITICAL
+	} else if status == checkers.OK && warning.exceeded(rtt, r.packetLoss, r.received) {
+		status = checkers.WARNING
+	}
+	if r.received > 1 {
+		if jitterExceeded(r.st.jitter, opts.CriticalJitter) {
+			status = checkers.CRITICAL
+		} else if status == checkers.OK && jitterExceeded(r.st.jitter, opts.WarningJitter) {
+			status = checkers.WARNING
 		}
 	}
+	r.status = status
 
-	return checkers.NewChecker(status, "")
+	r.msg = fmt.Sprintf("%d packets (%d bytes) transmitted, %d received, %g%% packet loss", opts.Count, opts.Size, r.received, r.packetLoss)
+	if r.received > 0 {
+		r.msg += ", " + formatStats(r.st, opts.UseMax)
+	}
+	return r
 }
 
-func isIPv6(host string) bool {
-	addr, err := net.ResolveIPAddr("ip", host)
-	if err != nil {
-		os.Exit(1)
+// jitterExceeded reports whether jitter is over the threshold in milliseconds.
+// A threshold of 0 means that it is not specified.
+func jitterExceeded(jitter time.Duration, ms float64) bool {
+	return ms > 0 && jitter > time.Duration(ms*float64(time.Millisecond))
+}
+
+// perfdata formats the result in the Nagios performance data format
+// (label=value[UOM];[warn];[crit];[min];[max]). prefix is prepended to each label.
+func perfdata(prefix string, st rttStats, packetLoss float64, received int, warning, critical *threshold) string {
+	var warnRTT, critRTT, warnLoss, critLoss string
+	if warning != nil {
+		warnRTT = fmt.Sprintf("%.3f", float64(warning.rtt)/float64(time.Millisecond))
+		warnLoss = fmt.Sprintf("%g", warning.loss)
+	}
+	if critical != nil {
+		critRTT = fmt.Sprintf("%.3f", float64(critical.rtt)/float64(time.Millisecond))
+		critLoss = fmt.Sprintf("%g", critical.loss)
+	}
+
+	data := []string{
+		fmt.Sprintf("%spl=%g%%;%s;%s;0;100", prefix, packetLoss, warnLoss, critLoss),
+	}
+	if received > 0 {
+		data = append([]string{fmt.Sprintf("%srta=%s;%s;%s;0", prefix, formatRTT(st.avg), warnRTT, critRTT)}, data...)
+		data = append(data,
+			fmt.Sprintf("%srtmax=%s;;;0", prefix, formatRTT(st.max)),
+			fmt.Sprintf("%srtmin=%s;;;0", prefix, formatRTT(st.min)),
+		)
+	}
+	return strings.Join(data, " ")
+}
+
+type rttStats struct {
+	min    time.Duration
+	max    time.Duration
+	avg    time.Duration
+	stddev time.Duration
+	jitter time.Duration
+}
+
+func calcRTTStats(rtts []time.Duration) rttStats {
+	var st rttStats
+	if len(rtts) == 0 {
+		return st
+	}
+	var sum time.Duration
+	st.min, st.max = rtts[0], rtts[0]
+	for _, rtt := range rtts {
+		sum += rtt
+		if rtt < st.min {
+			st.min = rtt
+		}
+		if rtt > st.max {
+			st.max = rtt
+		}
+	}
+	st.avg = sum / time.Duration(len(rtts))
+
+	var variance float64
+	for _, rtt := range rtts {
+		d := float64(rtt - st.avg)
+		variance += d * d
+	}
+	variance /= float64(len(rtts))
+	st.stddev = time.Duration(math.Sqrt(variance))
+
+	// jitter is the mean absolute difference between consecutive RTTs (RFC 3393)
+	if len(rtts) > 1 {
+		var diff time.Duration
+		for i := 1; i < len(rtts); i++ {
+			d := rtts[i] - rtts[i-1]
+			if d < 0 {
+				d = -d
+			}
+			diff += d
+		}
+		st.jitter = diff / time.Duration(len(rtts)-1)
+	}
+	return st
+}
+
+// formatStats puts the RTT compared with the thresholds first
+func formatStats(st rttStats, useMax bool) string {
+	if useMax {
+		return fmt.Sprintf("RTT(Max): %s, RTT min=%s avg=%s stddev=%s jitter=%s",
+			formatRTT(st.max), formatRTT(st.min), formatRTT(st.avg), formatRTT(st.stddev), formatRTT(st.jitter))
 	}
-	if ip4 := addr.IP.To4(); len(ip4) != net.IPv4len {
+	return fmt.Sprintf("RTT(Avg): %s, RTT min=%s max=%s stddev=%s jitter=%s",
+		formatRTT(st.avg), formatRTT(st.min), formatRTT(st.max), formatRTT(st.stddev), formatRTT(st.jitter))
+}
+
+func formatRTT(d time.Duration) string {
+	return fmt.Sprintf("%.3fms", float64(d)/float64(time.Millisecond))
+}
+
+// lookupIPAddr resolves the host name within --dns-timeout, so that a slow DNS
+// server does not eat up the whole check. context.DeadlineExceeded is returned
+// on timeout.
+func (opts *pingOpts) lookupIPAddr(host string) ([]net.IPAddr, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.DNSTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return nil, context.DeadlineExceeded
+	}
+	return addrs, err
+}
+
+// pickIPAddr prefers an IPv4 address like net.ResolveIPAddr("ip", ...) does,
+// or only looks for an IPv6 address if ipv6 is true
+func pickIPAddr(addrs []net.IPAddr, ipv6 bool) *net.IPAddr {
+	for _, addr := range addrs {
+		if isIPv6(addr.IP) == ipv6 {
+			return &net.IPAddr{IP: addr.IP, Zone: addr.Zone}
+		}
+	}
+	if !ipv6 && len(addrs) > 0 {
+		return &net.IPAddr{IP: addrs[0].IP, Zone: addrs[0].Zone}
+	}
+	return nil
+}
+
+func isIPv6(ip net.IP) bool {
+	if ip4 := ip.To4(); len(ip4) != net.IPv4len {
 		return true
 	}
 	return false
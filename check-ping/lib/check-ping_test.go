@@ -1,9 +1,15 @@
 package checkping
 
 import (
+	"errors"
+	"net"
+	"runtime"
 	"testing"
+	"time"
 
+	"github.com/mackerelio/checkers"
 	"github.com/stretchr/testify/assert"
+	ping "github.com/tatsushid/go-fastping"
 )
 
 func TestIsIPv6(t *testing.T) {
@@ -36,8 +42,183 @@ func TestIsIPv6(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.casename, func(t *testing.T) {
-			result := isIPv6(tc.host)
+			result := isIPv6(net.ParseIP(tc.host))
 			assert.Equal(t, tc.expectDetectation, result, "something went wrong")
 		})
 	}
 }
+
+func TestRunIPv6WithIPv4Host(t *testing.T) {
+	ckr := run([]string{"-H", "127.0.0.1", "-6"})
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status, "ckr.Status should be UNKNOWN")
+	assert.Equal(t, "127.0.0.1 does not have any IPv6 address", ckr.Message, "something went wrong")
+}
+
+func TestCalcRTTStats(t *testing.T) {
+	rtts := []time.Duration{
+		2 * time.Millisecond,
+		4 * time.Millisecond,
+		4 * time.Millisecond,
+		4 * time.Millisecond,
+		5 * time.Millisecond,
+		5 * time.Millisecond,
+		7 * time.Millisecond,
+		9 * time.Millisecond,
+	}
+	st := calcRTTStats(rtts)
+	assert.Equal(t, 2*time.Millisecond, st.min, "min should be 2ms")
+	assert.Equal(t, 9*time.Millisecond, st.max, "max should be 9ms")
+	assert.Equal(t, 5*time.Millisecond, st.avg, "avg should be 5ms")
+	assert.Equal(t, 2*time.Millisecond, st.stddev, "stddev should be 2ms")
+	assert.Equal(t, 1*time.Millisecond, st.jitter, "jitter should be 1ms")
+
+	assert.Equal(t, rttStats{}, calcRTTStats(nil), "stats of no packets should be zero")
+}
+
+func TestJitterExceeded(t *testing.T) {
+	assert.False(t, jitterExceeded(5*time.Millisecond, 0), "0 means no threshold")
+	assert.False(t, jitterExceeded(5*time.Millisecond, 5))
+	assert.True(t, jitterExceeded(5*time.Millisecond, 4.5))
+}
+
+func TestFormatRTT(t *testing.T) {
+	assert.Equal(t, "1.200ms", formatRTT(1200*time.Microsecond))
+	assert.Equal(t, "800.000ms", formatRTT(800*time.Millisecond))
+}
+
+func TestFormatStats(t *testing.T) {
+	st := rttStats{min: time.Millisecond, max: 9 * time.Millisecond, avg: 3 * time.Millisecond, stddev: 2 * time.Millisecond, jitter: 4 * time.Millisecond}
+	assert.Equal(t, "RTT(Avg): 3.000ms, RTT min=1.000ms max=9.000ms stddev=2.000ms jitter=4.000ms", formatStats(st, false))
+	assert.Equal(t, "RTT(Max): 9.000ms, RTT min=1.000ms avg=3.000ms stddev=2.000ms jitter=4.000ms", formatStats(st, true))
+}
+
+func TestParseThreshold(t *testing.T) {
+	th, err := parseThreshold("100,20%")
+	assert.Nil(t, err, "err should be nil")
+	assert.Equal(t, &threshold{rtt: 100 * time.Millisecond, loss: 20}, th)
+
+	th, err = parseThreshold("0.5,2.5%")
+	assert.Nil(t, err, "err should be nil")
+	assert.Equal(t, &threshold{rtt: 500 * time.Microsecond, loss: 2.5}, th)
+
+	th, err = parseThreshold("")
+	assert.Nil(t, err, "err should be nil")
+	assert.Nil(t, th, "empty threshold should be nil")
+
+	for _, s := range []string{"100", "100,20", "a,20%", "100,b%", "100,20%,3"} {
+		_, err := parseThreshold(s)
+		assert.NotNil(t, err, "%s should be invalid", s)
+	}
+}
+
+func TestPerfdata(t *testing.T) {
+	st := rttStats{min: 1 * time.Millisecond, max: 3 * time.Millisecond, avg: 2 * time.Millisecond}
+	warning := &threshold{rtt: 100 * time.Millisecond, loss: 20}
+	critical := &threshold{rtt: 500 * time.Millisecond, loss: 60}
+
+	assert.Equal(t,
+		"rta=2.000ms;100.000;500.000;0 pl=20%;20;60;0;100 rtmax=3.000ms;;;0 rtmin=1.000ms;;;0",
+		perfdata("", st, 20, 4, warning, critical))
+	assert.Equal(t,
+		"rta=2.000ms;;;0 pl=0%;;;0;100 rtmax=3.000ms;;;0 rtmin=1.000ms;;;0",
+		perfdata("", st, 0, 5, nil, nil))
+	assert.Equal(t, "pl=100%;20;60;0;100", perfdata("", rttStats{}, 100, 0, warning, critical))
+	assert.Equal(t, "db1_pl=100%;20;60;0;100", perfdata("db1_", rttStats{}, 100, 0, warning, critical))
+}
+
+func TestSplitHosts(t *testing.T) {
+	hosts := splitHosts([]string{"127.0.0.1", "db1, db2", "", "db3,"})
+	assert.Equal(t, []string{"127.0.0.1", "db1", "db2", "db3"}, hosts)
+}
+
+func TestRunMultipleHostsWithResolutionFailure(t *testing.T) {
+	ckr := run([]string{"-H", "host1.invalid,host2.invalid", "-6"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status, "ckr.Status should be CRITICAL")
+	assert.Regexp(t, `^host1\.invalid: lookup host1\.invalid.*\nhost2\.invalid: lookup host2\.invalid.*$`, ckr.Message)
+
+	ckr = run([]string{"-H", "host1.invalid", "-H", "host2.invalid"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status, "ckr.Status should be CRITICAL")
+}
+
+func TestRunInvalidPacketSize(t *testing.T) {
+	for _, size := range []string{"7", "65508"} {
+		ckr := run([]string{"-H", "127.0.0.1", "-s", size})
+		assert.Equal(t, checkers.UNKNOWN, ckr.Status, "ckr.Status should be UNKNOWN")
+		assert.Equal(t, "packet size should be between 8 and 65507 bytes: "+size, ckr.Message)
+	}
+}
+
+func TestResolveSource(t *testing.T) {
+	src, err := resolveSource("127.0.0.1")
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1", src)
+
+	_, err = resolveSource("192.0.2.1")
+	assert.EqualError(t, err, "source address 192.0.2.1 is not assigned to this host")
+
+	_, err = resolveSource("source.invalid")
+	assert.NotNil(t, err)
+}
+
+func TestPickIPAddr(t *testing.T) {
+	addrs := []net.IPAddr{
+		{IP: net.ParseIP("2001:db8::1")},
+		{IP: net.ParseIP("192.0.2.1")},
+	}
+	assert.Equal(t, "192.0.2.1", pickIPAddr(addrs, false).String())
+	assert.Equal(t, "2001:db8::1", pickIPAddr(addrs, true).String())
+	assert.Equal(t, "2001:db8::1", pickIPAddr(addrs[:1], false).String())
+	assert.Nil(t, pickIPAddr(addrs[1:], true))
+}
+
+func TestRunDNSTimeout(t *testing.T) {
+	ckr := run([]string{"-H", "example.com", "--dns-timeout", "1ns"})
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status, "ckr.Status should be UNKNOWN")
+	assert.Equal(t, "DNS resolution timeout: example.com", ckr.Message)
+}
+
+func TestCalcPacketLoss(t *testing.T) {
+	assert.Equal(t, 0.0, calcPacketLoss(5, 5))
+	assert.Equal(t, 20.0, calcPacketLoss(5, 4))
+	assert.Equal(t, 60.0, calcPacketLoss(5, 2))
+	assert.Equal(t, 100.0, calcPacketLoss(5, 0))
+}
+
+func TestRunPartialPacketLoss(t *testing.T) {
+	orig := runPinger
+	defer func() { runPinger = orig }()
+	sent := 0
+	runPinger = func(p *ping.Pinger) error {
+		sent++
+		// the 3rd packet is lost
+		if sent != 3 {
+			p.OnRecv(&net.IPAddr{IP: net.ParseIP("127.0.0.1")}, time.Millisecond)
+		}
+		return nil
+	}
+
+	ckr := run([]string{"-H", "127.0.0.1", "-n", "5", "--warning", "100,10%", "--critical", "100,30%"})
+	assert.Equal(t, checkers.WARNING, ckr.Status, "ckr.Status should be WARNING")
+	assert.Contains(t, ckr.Message, "5 packets (56 bytes) transmitted, 4 received, 20% packet loss")
+}
+
+func TestRunNoGoroutineLeakOnError(t *testing.T) {
+	orig := runPinger
+	defer func() { runPinger = orig }()
+	runPinger = func(p *ping.Pinger) error {
+		return errors.New("socket: operation not permitted")
+	}
+
+	before := runtime.NumGoroutine()
+	ckr := run([]string{"-H", "127.0.0.1,host.invalid", "-n", "3"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status, "ckr.Status should be CRITICAL")
+	assert.Contains(t, ckr.Message, "127.0.0.1: socket: operation not permitted")
+
+	// the resolver may take a moment to clean up its own goroutines
+	after := runtime.NumGoroutine()
+	for i := 0; i < 100 && after > before; i++ {
+		time.Sleep(10 * time.Millisecond)
+		after = runtime.NumGoroutine()
+	}
+	assert.True(t, after <= before, "goroutines leaked: %d -> %d", before, after)
+}
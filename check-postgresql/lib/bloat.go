@@ -0,0 +1,126 @@
+package checkpostgresql
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+type bloatOpts struct {
+	postgresqlSetting
+	Warn       *float64 `long:"bloat-warning" value-name:"PERCENT" description:"warning if the dead tuples of a table are over the percentage"`
+	Crit       *float64 `long:"bloat-critical" value-name:"PERCENT" description:"critical if the dead tuples of a table are over the percentage"`
+	TopN       int      `long:"top-n" value-name:"N" default:"5" description:"number of tables shown in the message"`
+	VacuumWarn *float64 `long:"last-vacuum-warning" value-name:"HOURS" description:"warning if a table has not been vacuumed for the hours"`
+}
+
+// tableStat is the estimate of dead tuples from pg_stat_user_tables
+type tableStat struct {
+	name             string
+	liveTuples       int64
+	deadTuples       int64
+	hoursSinceVacuum sql.NullFloat64 // NULL if never vacuumed
+}
+
+func (t tableStat) deadRatio() float64 {
+	total := t.liveTuples + t.deadTuples
+	if total == 0 {
+		return 0
+	}
+	return float64(t.deadTuples) / float64(total) * 100
+}
+
+func getTableStats(db *sql.DB) ([]tableStat, error) {
+	rows, err := db.Query(`SELECT schemaname || '.' || relname, n_live_tup, n_dead_tup,
+EXTRACT(EPOCH FROM now() - GREATEST(last_vacuum, last_autovacuum)) / 3600
+FROM pg_stat_user_tables`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []tableStat
+	for rows.Next() {
+		var t tableStat
+		if err := rows.Scan(&t.name, &t.liveTuples, &t.deadTuples, &t.hoursSinceVacuum); err != nil {
+			return nil, err
+		}
+		stats = append(stats, t)
+	}
+	return stats, rows.Err()
+}
+
+func evalBloat(stats []tableStat, opts bloatOpts) *checkers.Checker {
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].deadRatio() > stats[j].deadRatio()
+	})
+
+	checkSt := checkers.OK
+	top := make([]string, 0, opts.TopN)
+	for i, t := range stats {
+		ratio := t.deadRatio()
+		if opts.Crit != nil && ratio > *opts.Crit {
+			checkSt = checkers.CRITICAL
+		} else if opts.Warn != nil && ratio > *opts.Warn && checkSt == checkers.OK {
+			checkSt = checkers.WARNING
+		}
+		if i < opts.TopN {
+			top = append(top, fmt.Sprintf("%s %.2f%%", t.name, ratio))
+		}
+	}
+	msg := fmt.Sprintf("%d tables", len(stats))
+	if len(top) > 0 {
+		msg += ", dead tuples: " + strings.Join(top, ", ")
+	}
+
+	if opts.VacuumWarn != nil {
+		var notVacuumed []string
+		for _, t := range stats {
+			if !t.hoursSinceVacuum.Valid || t.hoursSinceVacuum.Float64 > *opts.VacuumWarn {
+				notVacuumed = append(notVacuumed, t.name)
+			}
+		}
+		if len(notVacuumed) > 0 {
+			if checkSt == checkers.OK {
+				checkSt = checkers.WARNING
+			}
+			sort.Strings(notVacuumed)
+			n := len(notVacuumed)
+			if n > opts.TopN {
+				notVacuumed = append(notVacuumed[:opts.TopN], "...")
+			}
+			msg += fmt.Sprintf(", %d tables not vacuumed for %g hours: %s", n, *opts.VacuumWarn, strings.Join(notVacuumed, ", "))
+		}
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func checkBloat(args []string) *checkers.Checker {
+	opts := bloatOpts{}
+	psr := flags.NewParser(&opts, flags.Default)
+	psr.Usage = "bloat [OPTIONS]"
+	_, err := psr.ParseArgs(args)
+	if err != nil {
+		os.Exit(1)
+	}
+	if opts.TopN < 0 {
+		return checkers.Unknown("--top-n must be 0 or more")
+	}
+
+	db, err := newPostgreSQL(opts.postgresqlSetting)
+	if err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't connect DB: %s", err))
+	}
+	defer db.Close()
+
+	stats, err := getTableStats(db)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	return evalBloat(stats, opts)
+}
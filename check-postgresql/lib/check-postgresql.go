@@ -1,6 +1,7 @@
 package checkpostgresql
 
 import (
+	"database/sql"
 	"fmt"
 	"os"
 	"strings"
@@ -11,7 +12,12 @@ import (
 )
 
 var commands = map[string](func([]string) *checkers.Checker){
-	"connection": checkConnection,
+	"connection":  checkConnection,
+	"ping":        checkPing,
+	"replication": checkReplication,
+	"long-query":  checkLongQuery,
+	"bloat":       checkBloat,
+	"locks":       checkLocks,
 }
 
 type postgresqlSetting struct {
@@ -24,15 +30,40 @@ type postgresqlSetting struct {
 	Timeout  int    `short:"t" long:"timeout" default:"5" description:"Maximum wait for connection, in seconds."`
 }
 
+// quoteDSNValue quotes a value of the connection string, so that a password
+// with spaces or quotes can be used
+func quoteDSNValue(v string) string {
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, `'`, `\'`, -1)
+	return "'" + v + "'"
+}
+
+// getDriverAndDataSourceName returns the arguments of sql.Open. A host
+// beginning with / is the directory of the unix domain socket.
 func (p postgresqlSetting) getDriverAndDataSourceName() (string, string) {
 	dbName := p.User
 	if p.Database != "" {
 		dbName = p.Database
 	}
-	dataSourceName := fmt.Sprintf("user=%s password=%s host=%s port=%s dbname=%s sslmode=%s connect_timeout=%d", p.User, p.Password, p.Host, p.Port, dbName, p.SSLmode, p.Timeout)
+	dataSourceName := fmt.Sprintf("user=%s password=%s host=%s port=%s dbname=%s sslmode=%s connect_timeout=%d",
+		quoteDSNValue(p.User), quoteDSNValue(p.Password), quoteDSNValue(p.Host), quoteDSNValue(p.Port),
+		quoteDSNValue(dbName), quoteDSNValue(p.SSLmode), p.Timeout)
 	return "postgres", dataSourceName
 }
 
+// newPostgreSQL opens a connection and makes sure that the server is reachable
+func newPostgreSQL(p postgresqlSetting) (*sql.DB, error) {
+	db, err := sql.Open(p.getDriverAndDataSourceName())
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
 func separateSub(argv []string) (string, []string) {
 	if len(argv) == 0 || strings.HasPrefix(argv[0], "-") {
 		return "", argv
@@ -0,0 +1,142 @@
+package checkpostgresql
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDriverAndDataSourceName(t *testing.T) {
+	p := postgresqlSetting{Host: "localhost", Port: "5432", User: "postgres", Password: `pa ss'\`, SSLmode: "disable", Timeout: 5}
+	driver, dsn := p.getDriverAndDataSourceName()
+	assert.Equal(t, "postgres", driver)
+	assert.Equal(t, `user='postgres' password='pa ss\'\\' host='localhost' port='5432' dbname='postgres' sslmode='disable' connect_timeout=5`, dsn)
+
+	p.Host = "/var/run/postgresql"
+	p.Database = "app"
+	_, dsn = p.getDriverAndDataSourceName()
+	assert.Contains(t, dsn, `host='/var/run/postgresql' port='5432' dbname='app'`)
+}
+
+func TestEvalReplication(t *testing.T) {
+	n := func(v int64) *int64 { return &v }
+	lags := []replicaLag{{"replica1", 0}, {"replica2", 2 * 1024 * 1024}}
+
+	ckr := evalReplication(lags, replicationOpts{Warn: n(1024 * 1024), Crit: n(16 * 1024 * 1024)})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "2 replicas, replica1: 0.00 B (0 bytes), replica2: 2.00 MB (2097152 bytes)", ckr.Message)
+
+	assert.Equal(t, checkers.CRITICAL, evalReplication(lags, replicationOpts{Warn: n(1024 * 1024), Crit: n(1024 * 1024)}).Status)
+	assert.Equal(t, checkers.OK, evalReplication(lags, replicationOpts{}).Status)
+
+	ckr = evalReplication(nil, replicationOpts{})
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "no replicas found", ckr.Message)
+
+	ckr = evalReplication(nil, replicationOpts{ReplicaName: "replica3", RequireReplica: true})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "replica replica3 not found", ckr.Message)
+}
+
+func TestEvalLongQueries(t *testing.T) {
+	queries := []activeQuery{
+		{pid: 101, duration: 400, query: "SELECT *\n  FROM orders\n  WHERE created_at < now() - interval '1 year'"},
+		{pid: 102, duration: 90, query: "VACUUM FULL users"},
+		{pid: 103, duration: 1, query: "SELECT 1"},
+	}
+	opts := longQueryOpts{TimeWarn: 60, TimeCrit: 300}
+
+	ckr := evalLongQueries(queries, nil, opts)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "2 queries running longer than 60s, 1 longer than 300s: pid 101 (400s): SELECT * FROM orders WHERE created_at < now() - interval '1 year', pid 102 (90s): VACUUM FULL users", ckr.Message)
+
+	opts.CountCrit = 1
+	assert.Equal(t, checkers.WARNING, evalLongQueries(queries, nil, opts).Status)
+
+	opts.CountWarn = 2
+	assert.Equal(t, checkers.OK, evalLongQueries(queries, nil, opts).Status)
+
+	opts = longQueryOpts{TimeWarn: 60, TimeCrit: 300}
+	ckr = evalLongQueries(queries, regexp.MustCompile(`(?i)^select`), opts)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "1 queries running longer than 60s, 0 longer than 300s: pid 102 (90s): VACUUM FULL users", ckr.Message)
+}
+
+func TestPreviewQuery(t *testing.T) {
+	assert.Equal(t, "SELECT 1", previewQuery("SELECT\n\t1"))
+	long := strings.Repeat("a", 100)
+	assert.Equal(t, strings.Repeat("a", 80)+"...", previewQuery(long))
+}
+
+func TestEvalConnection(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	n := func(v int) *int { return &v }
+	st := connectionStats{connections: 60, maxConnections: 100, idleInTxn: 3}
+
+	ckr := evalConnection(st, connectionOpts{Warn: 70, Crit: 90})
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "60 connections (max_connections: 100, 60.00%), 3 idle in transaction", ckr.Message)
+
+	assert.Equal(t, checkers.WARNING, evalConnection(st, connectionOpts{Warn: 70, Crit: 90, PercentWarn: f(50), PercentCrit: f(80)}).Status)
+	assert.Equal(t, checkers.CRITICAL, evalConnection(st, connectionOpts{Warn: 70, Crit: 90, PercentWarn: f(50), PercentCrit: f(55)}).Status)
+	assert.Equal(t, checkers.WARNING, evalConnection(st, connectionOpts{Warn: 70, Crit: 90, IdleTxnWarn: n(2), IdleTxnCrit: n(5)}).Status)
+	assert.Equal(t, checkers.CRITICAL, evalConnection(st, connectionOpts{Warn: 70, Crit: 90, PercentWarn: f(50), IdleTxnCrit: n(2)}).Status)
+	assert.Equal(t, checkers.CRITICAL, evalConnection(st, connectionOpts{Warn: 50, Crit: 59}).Status)
+	assert.Equal(t, checkers.OK, evalConnection(st, connectionOpts{Warn: 50, Crit: 59, PercentWarn: f(70), PercentCrit: f(80)}).Status)
+}
+
+func TestEvalBloat(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	hours := func(v float64) sql.NullFloat64 { return sql.NullFloat64{Float64: v, Valid: true} }
+	stats := func() []tableStat {
+		return []tableStat{
+			{name: "public.users", liveTuples: 900, deadTuples: 100, hoursSinceVacuum: hours(1)},
+			{name: "public.orders", liveTuples: 600, deadTuples: 400, hoursSinceVacuum: hours(48)},
+			{name: "public.empty", hoursSinceVacuum: sql.NullFloat64{}},
+		}
+	}
+
+	ckr := evalBloat(stats(), bloatOpts{TopN: 2})
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "3 tables, dead tuples: public.orders 40.00%, public.users 10.00%", ckr.Message)
+
+	assert.Equal(t, checkers.WARNING, evalBloat(stats(), bloatOpts{TopN: 5, Warn: f(20), Crit: f(50)}).Status)
+	assert.Equal(t, checkers.CRITICAL, evalBloat(stats(), bloatOpts{TopN: 5, Warn: f(5), Crit: f(30)}).Status)
+
+	ckr = evalBloat(stats(), bloatOpts{TopN: 1, VacuumWarn: f(24)})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "3 tables, dead tuples: public.orders 40.00%, 2 tables not vacuumed for 24 hours: public.empty, ...", ckr.Message)
+}
+
+func TestCheckBloatWithNegativeTopN(t *testing.T) {
+	ckr := checkBloat([]string{"--top-n=-1"})
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status)
+	assert.Equal(t, "--top-n must be 0 or more", ckr.Message)
+}
+
+func TestParseIntArray(t *testing.T) {
+	assert.Equal(t, []int{123, 456}, parseIntArray("{123,456}"))
+	assert.Nil(t, parseIntArray("{}"))
+}
+
+func TestEvalLocks(t *testing.T) {
+	n := func(v int) *int { return &v }
+	n64 := func(v int64) *int64 { return &v }
+	st := lockStats{waiting: 2, blockers: map[int]string{200: "UPDATE accounts SET balance = 0", 100: "LOCK TABLE users"}}
+
+	ckr := evalLocks(st, nil, locksOpts{SampleInterval: 1})
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "2 sessions waiting for locks (blocked by pid 100: LOCK TABLE users, pid 200: UPDATE accounts SET balance = 0)", ckr.Message)
+
+	assert.Equal(t, checkers.WARNING, evalLocks(st, nil, locksOpts{WaitWarn: n(1), WaitCrit: n(5)}).Status)
+	assert.Equal(t, checkers.CRITICAL, evalLocks(st, nil, locksOpts{WaitWarn: n(0), WaitCrit: n(1)}).Status)
+
+	ckr = evalLocks(lockStats{}, n64(2), locksOpts{DeadlocksWarn: n64(0), DeadlocksCrit: n64(5), SampleInterval: 10})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "0 sessions waiting for locks, 2 deadlocks in 10s", ckr.Message)
+	assert.Equal(t, checkers.CRITICAL, evalLocks(lockStats{}, n64(2), locksOpts{DeadlocksCrit: n64(1)}).Status)
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/mackerelio/checkers"
@@ -11,8 +12,61 @@ import (
 
 type connectionOpts struct {
 	postgresqlSetting
-	Warn int `short:"w" long:"warning" default:"70" description:"warning if the number of connection is over"`
-	Crit int `short:"c" long:"critical" default:"90" description:"critical if the number of connection is over"`
+	Warn        int      `short:"w" long:"warning" default:"70" description:"warning if the number of connection is over"`
+	Crit        int      `short:"c" long:"critical" default:"90" description:"critical if the number of connection is over"`
+	PercentWarn *float64 `long:"connections-warning" value-name:"PERCENT" description:"warning if the connections are over the percentage of max_connections. takes precedence over --warning"`
+	PercentCrit *float64 `long:"connections-critical" value-name:"PERCENT" description:"critical if the connections are over the percentage of max_connections. takes precedence over --critical"`
+	IdleTxnWarn *int     `long:"idle-txn-warning" value-name:"N" description:"warning if the number of idle in transaction connections is over"`
+	IdleTxnCrit *int     `long:"idle-txn-critical" value-name:"N" description:"critical if the number of idle in transaction connections is over"`
+}
+
+type connectionStats struct {
+	connections    int
+	maxConnections int
+	idleInTxn      int
+}
+
+func getConnectionStats(db *sql.DB) (connectionStats, error) {
+	var st connectionStats
+	err := db.QueryRow(`SELECT COUNT(*) AS cnt,
+COALESCE(SUM(CASE WHEN state = 'idle in transaction' THEN 1 ELSE 0 END), 0) AS idle_in_txn
+FROM pg_stat_activity`).Scan(&st.connections, &st.idleInTxn)
+	if err != nil {
+		return st, err
+	}
+	var maxConnections string
+	if err := db.QueryRow("SHOW max_connections").Scan(&maxConnections); err != nil {
+		return st, err
+	}
+	st.maxConnections, err = strconv.Atoi(maxConnections)
+	return st, err
+}
+
+func evalConnection(st connectionStats, opts connectionOpts) *checkers.Checker {
+	percent := 0.0
+	if st.maxConnections > 0 {
+		percent = float64(st.connections) / float64(st.maxConnections) * 100
+	}
+	// the percentage takes precedence over the number of connections
+	overConnections := func(threshold int, percentThreshold *float64) bool {
+		if percentThreshold != nil {
+			return percent > *percentThreshold
+		}
+		return st.connections > threshold
+	}
+	overIdleInTxn := func(threshold *int) bool {
+		return threshold != nil && st.idleInTxn > *threshold
+	}
+
+	checkSt := checkers.OK
+	msg := fmt.Sprintf("%d connections (max_connections: %d, %.2f%%), %d idle in transaction",
+		st.connections, st.maxConnections, percent, st.idleInTxn)
+	if overConnections(opts.Crit, opts.PercentCrit) || overIdleInTxn(opts.IdleTxnCrit) {
+		checkSt = checkers.CRITICAL
+	} else if overConnections(opts.Warn, opts.PercentWarn) || overIdleInTxn(opts.IdleTxnWarn) {
+		checkSt = checkers.WARNING
+	}
+	return checkers.NewChecker(checkSt, msg)
 }
 
 func checkConnection(args []string) *checkers.Checker {
@@ -30,18 +84,9 @@ func checkConnection(args []string) *checkers.Checker {
 	}
 	defer db.Close()
 
-	statActivityCount := 0
-	err = db.QueryRow("SELECT COUNT(*) AS cnt FROM pg_stat_activity").Scan(&statActivityCount)
+	st, err := getConnectionStats(db)
 	if err != nil {
 		return checkers.Unknown(err.Error())
 	}
-
-	checkSt := checkers.OK
-	msg := fmt.Sprintf("%d connections", statActivityCount)
-	if statActivityCount > opts.Crit {
-		checkSt = checkers.CRITICAL
-	} else if statActivityCount > opts.Warn {
-		checkSt = checkers.WARNING
-	}
-	return checkers.NewChecker(checkSt, msg)
+	return evalConnection(st, opts)
 }
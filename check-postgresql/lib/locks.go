@@ -0,0 +1,151 @@
+package checkpostgresql
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+type locksOpts struct {
+	postgresqlSetting
+	WaitWarn       *int   `long:"lock-wait-warning" value-name:"N" description:"warning if the number of sessions waiting for locks is over"`
+	WaitCrit       *int   `long:"lock-wait-critical" value-name:"N" description:"critical if the number of sessions waiting for locks is over"`
+	DeadlocksWarn  *int64 `long:"deadlocks-warning" value-name:"N" description:"warning if the number of deadlocks during --sample-interval is over"`
+	DeadlocksCrit  *int64 `long:"deadlocks-critical" value-name:"N" description:"critical if the number of deadlocks during --sample-interval is over"`
+	SampleInterval int    `long:"sample-interval" value-name:"SECONDS" default:"1" description:"interval between the two samples of the deadlocks counter"`
+}
+
+type lockStats struct {
+	waiting  int
+	blockers map[int]string // pid to query of the sessions blocking others
+}
+
+// parseIntArray parses an int[] in text like "{123,456}"
+func parseIntArray(s string) []int {
+	var ns []int
+	for _, f := range strings.Split(strings.Trim(s, "{}"), ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(f)); err == nil {
+			ns = append(ns, n)
+		}
+	}
+	return ns
+}
+
+func getLockStats(db *sql.DB) (lockStats, error) {
+	st := lockStats{blockers: make(map[int]string)}
+	rows, err := db.Query(`SELECT pid, COALESCE(query, ''), COALESCE(wait_event_type, '') = 'Lock',
+CASE WHEN wait_event_type = 'Lock' THEN pg_blocking_pids(pid)::text ELSE '{}' END
+FROM pg_stat_activity`)
+	if err != nil {
+		return st, err
+	}
+	defer rows.Close()
+
+	queries := make(map[int]string)
+	var blockingPids []int
+	for rows.Next() {
+		var pid int
+		var query, blocking string
+		var waiting bool
+		if err := rows.Scan(&pid, &query, &waiting, &blocking); err != nil {
+			return st, err
+		}
+		queries[pid] = query
+		if waiting {
+			st.waiting++
+			blockingPids = append(blockingPids, parseIntArray(blocking)...)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return st, err
+	}
+	for _, pid := range blockingPids {
+		st.blockers[pid] = queries[pid]
+	}
+	return st, nil
+}
+
+func getDeadlocks(db *sql.DB) (int64, error) {
+	var deadlocks int64
+	err := db.QueryRow("SELECT deadlocks FROM pg_stat_database WHERE datname = current_database()").Scan(&deadlocks)
+	return deadlocks, err
+}
+
+func evalLocks(st lockStats, deadlocks *int64, opts locksOpts) *checkers.Checker {
+	msg := fmt.Sprintf("%d sessions waiting for locks", st.waiting)
+	if len(st.blockers) > 0 {
+		pids := make([]int, 0, len(st.blockers))
+		for pid := range st.blockers {
+			pids = append(pids, pid)
+		}
+		sort.Ints(pids)
+		blockers := make([]string, 0, len(pids))
+		for _, pid := range pids {
+			blockers = append(blockers, fmt.Sprintf("pid %d: %s", pid, previewQuery(st.blockers[pid])))
+		}
+		msg += fmt.Sprintf(" (blocked by %s)", strings.Join(blockers, ", "))
+	}
+	if deadlocks != nil {
+		msg += fmt.Sprintf(", %d deadlocks in %ds", *deadlocks, opts.SampleInterval)
+	}
+
+	overWait := func(threshold *int) bool {
+		return threshold != nil && st.waiting > *threshold
+	}
+	overDeadlocks := func(threshold *int64) bool {
+		return threshold != nil && deadlocks != nil && *deadlocks > *threshold
+	}
+	checkSt := checkers.OK
+	if overWait(opts.WaitCrit) || overDeadlocks(opts.DeadlocksCrit) {
+		checkSt = checkers.CRITICAL
+	} else if overWait(opts.WaitWarn) || overDeadlocks(opts.DeadlocksWarn) {
+		checkSt = checkers.WARNING
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func checkLocks(args []string) *checkers.Checker {
+	opts := locksOpts{}
+	psr := flags.NewParser(&opts, flags.Default)
+	psr.Usage = "locks [OPTIONS]"
+	_, err := psr.ParseArgs(args)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	db, err := newPostgreSQL(opts.postgresqlSetting)
+	if err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't connect DB: %s", err))
+	}
+	defer db.Close()
+
+	// the deadlocks counter is sampled only if its thresholds are given,
+	// not to wait for --sample-interval in vain
+	var deadlocks *int64
+	if opts.DeadlocksWarn != nil || opts.DeadlocksCrit != nil {
+		prev, err := getDeadlocks(db)
+		if err != nil {
+			return checkers.Unknown(err.Error())
+		}
+		time.Sleep(time.Duration(opts.SampleInterval) * time.Second)
+		cur, err := getDeadlocks(db)
+		if err != nil {
+			return checkers.Unknown(err.Error())
+		}
+		delta := cur - prev
+		deadlocks = &delta
+	}
+
+	st, err := getLockStats(db)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	return evalLocks(st, deadlocks, opts)
+}
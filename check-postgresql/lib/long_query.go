@@ -0,0 +1,117 @@
+package checkpostgresql
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+type longQueryOpts struct {
+	postgresqlSetting
+	TimeWarn            float64 `long:"query-time-warning" value-name:"SECONDS" default:"60" description:"a query running longer than this is counted for the warning"`
+	TimeCrit            float64 `long:"query-time-critical" value-name:"SECONDS" default:"300" description:"a query running longer than this is counted for the critical"`
+	CountWarn           int     `long:"query-count-warning" value-name:"N" default:"0" description:"warning if the number of queries running longer than --query-time-warning is over"`
+	CountCrit           int     `long:"query-count-critical" value-name:"N" default:"0" description:"critical if the number of queries running longer than --query-time-critical is over"`
+	ExcludeQueryPattern string  `long:"exclude-query-pattern" value-name:"REGEXP" description:"ignore the queries matching the pattern"`
+}
+
+// queryPreviewLength is the max length of the query shown in the message
+const queryPreviewLength = 80
+
+type activeQuery struct {
+	pid      int
+	duration float64
+	query    string
+}
+
+func getActiveQueries(db *sql.DB) ([]activeQuery, error) {
+	rows, err := db.Query(`SELECT pid, EXTRACT(EPOCH FROM now() - query_start), query FROM pg_stat_activity
+WHERE state = 'active' AND pid <> pg_backend_pid() AND query NOT ILIKE 'autovacuum%'
+ORDER BY query_start`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var queries []activeQuery
+	for rows.Next() {
+		var q activeQuery
+		if err := rows.Scan(&q.pid, &q.duration, &q.query); err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+	}
+	return queries, rows.Err()
+}
+
+func previewQuery(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if r := []rune(query); len(r) > queryPreviewLength {
+		query = string(r[:queryPreviewLength]) + "..."
+	}
+	return query
+}
+
+func evalLongQueries(queries []activeQuery, exclude *regexp.Regexp, opts longQueryOpts) *checkers.Checker {
+	var warnCount, critCount int
+	var details []string
+	for _, q := range queries {
+		if exclude != nil && exclude.MatchString(q.query) {
+			continue
+		}
+		if q.duration > opts.TimeCrit {
+			critCount++
+		}
+		if q.duration > opts.TimeWarn {
+			warnCount++
+			details = append(details, fmt.Sprintf("pid %d (%.0fs): %s", q.pid, q.duration, previewQuery(q.query)))
+		}
+	}
+
+	checkSt := checkers.OK
+	if critCount > opts.CountCrit {
+		checkSt = checkers.CRITICAL
+	} else if warnCount > opts.CountWarn {
+		checkSt = checkers.WARNING
+	}
+	msg := fmt.Sprintf("%d queries running longer than %gs, %d longer than %gs", warnCount, opts.TimeWarn, critCount, opts.TimeCrit)
+	if len(details) > 0 {
+		msg += ": " + strings.Join(details, ", ")
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func checkLongQuery(args []string) *checkers.Checker {
+	opts := longQueryOpts{}
+	psr := flags.NewParser(&opts, flags.Default)
+	psr.Usage = "long-query [OPTIONS]"
+	_, err := psr.ParseArgs(args)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	var exclude *regexp.Regexp
+	if opts.ExcludeQueryPattern != "" {
+		exclude, err = regexp.Compile(opts.ExcludeQueryPattern)
+		if err != nil {
+			return checkers.Unknown(fmt.Sprintf("invalid --exclude-query-pattern: %s", err))
+		}
+	}
+
+	db, err := newPostgreSQL(opts.postgresqlSetting)
+	if err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't connect DB: %s", err))
+	}
+	defer db.Close()
+
+	queries, err := getActiveQueries(db)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	return evalLongQueries(queries, exclude, opts)
+}
@@ -0,0 +1,99 @@
+package checkpostgresql
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+	"github.com/mackerelio/go-check-plugins/check-file-size/lib"
+)
+
+type replicationOpts struct {
+	postgresqlSetting
+	Warn           *int64 `long:"replication-warning" value-name:"BYTES" description:"warning if the WAL lag of a replica is over"`
+	Crit           *int64 `long:"replication-critical" value-name:"BYTES" description:"critical if the WAL lag of a replica is over"`
+	ReplicaName    string `long:"replica-name" value-name:"NAME" description:"check only the replica of the application_name"`
+	RequireReplica bool   `long:"require-replica" description:"critical if no replicas are found"`
+}
+
+// replicaLag is the WAL lag of a replica between sent_lsn and replay_lsn
+type replicaLag struct {
+	name     string
+	lagBytes int64
+}
+
+func getReplicaLags(db *sql.DB, name string) ([]replicaLag, error) {
+	query := "SELECT application_name, pg_wal_lsn_diff(sent_lsn, replay_lsn) AS lag_bytes FROM pg_stat_replication"
+	args := []interface{}{}
+	if name != "" {
+		query += " WHERE application_name = $1"
+		args = append(args, name)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lags []replicaLag
+	for rows.Next() {
+		var name string
+		var lag sql.NullFloat64
+		if err := rows.Scan(&name, &lag); err != nil {
+			return nil, err
+		}
+		lags = append(lags, replicaLag{name: name, lagBytes: int64(lag.Float64)})
+	}
+	return lags, rows.Err()
+}
+
+func evalReplication(lags []replicaLag, opts replicationOpts) *checkers.Checker {
+	if len(lags) == 0 {
+		msg := "no replicas found"
+		if opts.ReplicaName != "" {
+			msg = fmt.Sprintf("replica %s not found", opts.ReplicaName)
+		}
+		if opts.RequireReplica {
+			return checkers.Critical(msg)
+		}
+		return checkers.Ok(msg)
+	}
+
+	checkSt := checkers.OK
+	details := make([]string, 0, len(lags))
+	for _, l := range lags {
+		details = append(details, fmt.Sprintf("%s: %s (%d bytes)", l.name, checkfilesize.HumanizeBytes(float64(l.lagBytes)), l.lagBytes))
+		if opts.Crit != nil && l.lagBytes > *opts.Crit {
+			checkSt = checkers.CRITICAL
+		} else if opts.Warn != nil && l.lagBytes > *opts.Warn && checkSt == checkers.OK {
+			checkSt = checkers.WARNING
+		}
+	}
+	msg := fmt.Sprintf("%d replicas, %s", len(lags), strings.Join(details, ", "))
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func checkReplication(args []string) *checkers.Checker {
+	opts := replicationOpts{}
+	psr := flags.NewParser(&opts, flags.Default)
+	psr.Usage = "replication [OPTIONS]"
+	_, err := psr.ParseArgs(args)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	db, err := newPostgreSQL(opts.postgresqlSetting)
+	if err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't connect DB: %s", err))
+	}
+	defer db.Close()
+
+	lags, err := getReplicaLags(db, opts.ReplicaName)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	return evalReplication(lags, opts)
+}
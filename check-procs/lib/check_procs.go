@@ -3,42 +3,55 @@ package checkprocs
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/mackerelio/checkers"
 )
 
 // https://github.com/sensu-plugins/sensu-plugins-process-checks
-var opts struct {
-	WarningOver   *int64  `short:"w" long:"warning-over" value-name:"N" description:"Trigger a warning if over a number"`
-	WarnOver      *int64  `long:"warn-over" value-name:"N" description:"(DEPRECATED) Trigger a warning if over a number"`
-	CritOver      *int64  `short:"c" long:"critical-over" value-name:"N" description:"Trigger a critical if over a number"`
-	WarningUnder  int64   `short:"W" long:"warning-under" value-name:"N" default:"1" description:"Trigger a warning if under a number"`
-	WarnUnder     int64   `long:"warn-under" value-name:"N" default:"1" description:"(DEPRECATED) Trigger a warning if under a number"`
-	CritUnder     int64   `short:"C" long:"critical-under" value-name:"N" default:"1" description:"Trigger a critial if under a number"`
-	MatchSelf     bool    `short:"m" long:"match-self" description:"Match itself"`
-	MatchParent   bool    `short:"M" long:"match-parent" description:"Match parent"`
-	CmdPat        string  `short:"p" long:"pattern" value-name:"PATTERN" description:"Match a command against this pattern"`
-	CmdExcludePat string  `short:"x" long:"exclude-pattern" value-name:"PATTERN" description:"Don't match against a pattern to prevent false positives"`
-	Ppid          string  `long:"ppid" value-name:"PPID" description:"Check against a specific PPID"`
-	FilePid       string  `short:"f" long:"file-pid" value-name:"PID" description:"Check against a specific PID"`
-	Vsz           int64   `short:"z" long:"virtual-memory-size" value-name:"VSZ" description:"Trigger on a Virtual Memory size is bigger than this"`
-	Rss           int64   `short:"r" long:"resident-set-size" value-name:"RSS" description:"Trigger on a Resident Set size is bigger than this"`
-	Pcpu          float64 `short:"P" long:"proportional-set-size" value-name:"PCPU" description:"Trigger on a Proportional Set Size is bigger than this"`
-	Thcount       int64   `short:"T" long:"thread-count" value-name:"THCOUNT" description:"Trigger on a Thread Count is bigger than this"`
-	State         string  `short:"s" long:"state" value-name:"STATE" description:"Trigger on a specific state, example: Z for zombie"`
-	User          string  `short:"u" long:"user" value-name:"USER" description:"Trigger on a specific user"`
-	Usernot       string  `short:"U" long:"user-not" value-name:"USER" description:"Trigger if not owned a specific user"`
-	EsecOver      int64   `short:"e" long:"esec-over" value-name:"SECONDS" description:"Match processes that older that this, in SECONDS"`
-	EsecUnder     int64   `short:"E" long:"esec-under" value-name:"SECONDS" description:"Match process that are younger than this, in SECONDS"`
-	CPUOver       int64   `short:"i" long:"cpu-over" value-name:"SECONDS" description:"Match processes cpu time that is older than this, in SECONDS"`
-	CPUUnder      int64   `short:"I" long:"cpu-under" value-name:"SECONDS" description:"Match processes cpu time that is younger than this, in SECONDS"`
+type procsOpts struct {
+	WarningOver   *int64   `short:"w" long:"warning-over" value-name:"N" description:"Trigger a warning if over a number"`
+	WarnOver      *int64   `long:"warn-over" value-name:"N" description:"(DEPRECATED) Trigger a warning if over a number"`
+	CritOver      *int64   `short:"c" long:"critical-over" value-name:"N" description:"Trigger a critical if over a number"`
+	WarningUnder  int64    `short:"W" long:"warning-under" value-name:"N" default:"1" description:"Trigger a warning if under a number"`
+	WarnUnder     int64    `long:"warn-under" value-name:"N" default:"1" description:"(DEPRECATED) Trigger a warning if under a number"`
+	CritUnder     int64    `short:"C" long:"critical-under" value-name:"N" default:"1" description:"Trigger a critial if under a number"`
+	MatchSelf     bool     `short:"m" long:"match-self" description:"Match itself"`
+	MatchParent   bool     `short:"M" long:"match-parent" description:"Match parent"`
+	CmdPat        string   `short:"p" long:"pattern" value-name:"PATTERN" description:"Match a command against this pattern"`
+	NamePat       string   `long:"command" value-name:"PATTERN" description:"Match a process name (comm of ps, without arguments) against this pattern"`
+	CmdExcludePat string   `short:"x" long:"exclude-pattern" value-name:"PATTERN" description:"Don't match against a pattern to prevent false positives"`
+	Ppid          string   `long:"ppid" value-name:"PPID" description:"Check against a specific PPID"`
+	FilePid       string   `short:"f" long:"file-pid" value-name:"PID" description:"Check against a specific PID"`
+	Vsz           int64    `short:"z" long:"virtual-memory-size" value-name:"VSZ" description:"Trigger on a Virtual Memory size is bigger than this"`
+	Rss           int64    `short:"r" long:"resident-set-size" value-name:"RSS" description:"Trigger on a Resident Set size is bigger than this"`
+	Pcpu          float64  `short:"P" long:"proportional-set-size" value-name:"PCPU" description:"Trigger on a Proportional Set Size is bigger than this"`
+	Thcount       int64    `short:"T" long:"thread-count" value-name:"THCOUNT" description:"Trigger on a Thread Count is bigger than this"`
+	State         string   `short:"s" long:"state" value-name:"STATE" description:"Trigger on a specific state, example: Z for zombie"`
+	User          string   `short:"u" long:"user" value-name:"USER" description:"Trigger on a specific user"`
+	Usernot       string   `short:"U" long:"user-not" value-name:"USER" description:"Trigger if not owned a specific user"`
+	EsecOver      int64    `short:"e" long:"esec-over" value-name:"SECONDS" description:"Match processes that older that this, in SECONDS"`
+	EsecUnder     int64    `short:"E" long:"esec-under" value-name:"SECONDS" description:"Match process that are younger than this, in SECONDS"`
+	CPUOver       int64    `short:"i" long:"cpu-over" value-name:"SECONDS" description:"Match processes cpu time that is older than this, in SECONDS"`
+	CPUUnder      int64    `short:"I" long:"cpu-under" value-name:"SECONDS" description:"Match processes cpu time that is younger than this, in SECONDS"`
+	MaxOutput     int      `long:"max-output" value-name:"N" description:"Output details of up to N matching processes"`
+	MemoryWarn    *float64 `long:"memory-warning" value-name:"MB" description:"Trigger a warning if the resident memory of a matching process is over MB (requires --pattern or --command)"`
+	MemoryCrit    *float64 `long:"memory-critical" value-name:"MB" description:"Trigger a critical if the resident memory of a matching process is over MB (requires --pattern or --command)"`
+	AggregateMem  bool     `long:"aggregate-memory" description:"Apply --memory-warning and --memory-critical to the sum of the matching processes"`
+	FdWarn        *int64   `long:"fd-warning" value-name:"N" description:"Trigger a warning if the open file descriptors of a matching process are over N (Linux only, requires --pattern or --command)"`
+	FdCrit        *int64   `long:"fd-critical" value-name:"N" description:"Trigger a critical if the open file descriptors of a matching process are over N (Linux only, requires --pattern or --command)"`
+	FdPctWarn     *float64 `long:"fd-pct-warning" value-name:"PERCENT" description:"Trigger a warning if the open file descriptors of a matching process are over the percentage of fs.nr_open (Linux only)"`
+	FdPctCrit     *float64 `long:"fd-pct-critical" value-name:"PERCENT" description:"Trigger a critical if the open file descriptors of a matching process are over the percentage of fs.nr_open (Linux only)"`
+	Zombie        bool     `long:"zombie" description:"Check zombie processes, which is the same as --state=Z --critical-over=0 and lists their parents"`
 }
 
 type procState struct {
 	cmd     string
+	name    string
 	user    string
 	ppid    string
 	pid     string
@@ -58,12 +71,43 @@ func Do() {
 	ckr.Exit()
 }
 
+// DoZombie checks zombie processes for check-zombie
+func DoZombie() {
+	ckr := run(append([]string{"--zombie"}, os.Args[1:]...))
+	ckr.Name = "Zombie"
+	ckr.Exit()
+}
+
+// procName returns the name of the command without the directory and the
+// arguments, or the kernel thread name without brackets. It is used only if
+// the name of the executable is not available, since the command line can be
+// rewritten by the process like "sshd: user@pts/0"
+func procName(cmd string) string {
+	if strings.HasPrefix(cmd, "[") && strings.HasSuffix(cmd, "]") {
+		return strings.Trim(cmd, "[]")
+	}
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return ""
+	}
+	return filepath.Base(fields[0])
+}
+
 func run(args []string) *checkers.Checker {
-	_, err := flags.ParseArgs(&opts, args)
+	opts := &procsOpts{}
+	_, err := flags.ParseArgs(opts, args)
 	if err != nil {
 		os.Exit(1)
 	}
 
+	if (opts.MemoryWarn != nil || opts.MemoryCrit != nil) && opts.CmdPat == "" && opts.NamePat == "" {
+		return checkers.Unknown("--memory-warning and --memory-critical require --pattern or --command")
+	}
+	checkFds := opts.FdWarn != nil || opts.FdCrit != nil || opts.FdPctWarn != nil || opts.FdPctCrit != nil
+	if checkFds && opts.CmdPat == "" && opts.NamePat == "" {
+		return checkers.Unknown("--fd-warning, --fd-critical, --fd-pct-warning and --fd-pct-critical require --pattern or --command")
+	}
+
 	// for backward compatibility
 	if opts.WarnUnder != 1 && opts.WarningUnder == 1 {
 		opts.WarningUnder = opts.WarnUnder
@@ -72,6 +116,17 @@ func run(args []string) *checkers.Checker {
 		opts.WarningOver = opts.WarnOver
 	}
 
+	if opts.Zombie {
+		opts.State = "Z"
+		if opts.CritOver == nil {
+			zero := int64(0)
+			opts.CritOver = &zero
+		}
+		// no zombie is not a problem
+		opts.WarningUnder = 0
+		opts.CritUnder = 0
+	}
+
 	procs, err := getProcs()
 	if err != nil {
 		return checkers.NewChecker(checkers.UNKNOWN, err.Error())
@@ -92,14 +147,22 @@ func run(args []string) *checkers.Checker {
 		}
 		cmdExcludePatRegexp = r
 	}
+	namePatRegexp := regexp.MustCompile(".*")
+	if opts.NamePat != "" {
+		r, err := regexp.Compile(opts.NamePat)
+		if err != nil {
+			return checkers.NewChecker(checkers.UNKNOWN, err.Error())
+		}
+		namePatRegexp = r
+	}
 	var resultrocStates []procState
 	for _, proc := range procs {
-		if matchProc(proc, cmdPatRegexp, cmdExcludePatRegexp) {
+		if matchProc(opts, proc, cmdPatRegexp, cmdExcludePatRegexp, namePatRegexp) {
 			resultrocStates = append(resultrocStates, proc)
 		}
 	}
 	count := int64(len(resultrocStates))
-	msg := gatherMsg(count)
+	msg := gatherMsg(opts, count)
 	result := checkers.OK
 	if opts.CritUnder != 0 && count < opts.CritUnder ||
 		opts.CritOver != nil && count > *opts.CritOver {
@@ -108,11 +171,113 @@ func run(args []string) *checkers.Checker {
 		opts.WarningOver != nil && count > *opts.WarningOver {
 		result = checkers.WARNING
 	}
+	if opts.MemoryWarn != nil || opts.MemoryCrit != nil {
+		memResult, memMsg := evalMemory(opts, resultrocStates)
+		if memResult > result {
+			result = memResult
+		}
+		msg += memMsg
+	}
+	if checkFds {
+		var limit int64
+		if opts.FdPctWarn != nil || opts.FdPctCrit != nil {
+			limit, err = fdLimit()
+			if err != nil {
+				return checkers.Unknown(err.Error())
+			}
+		}
+		fdResult, fdMsg := evalFds(opts, resultrocStates, countFds, limit)
+		if fdResult > result {
+			result = fdResult
+		}
+		msg += fdMsg
+	}
+	if opts.Zombie {
+		msg += zombieParents(resultrocStates)
+	}
+	msg += procDetails(resultrocStates, opts.MaxOutput)
 	return checkers.NewChecker(result, msg)
 }
 
-func matchProc(proc procState, cmdPatRegexp *regexp.Regexp, cmdExcludePatRegexp *regexp.Regexp) bool {
+// evalMemory checks the resident memory of each process, or the sum of them
+// with --aggregate-memory
+func evalMemory(opts *procsOpts, procs []procState) (checkers.Status, string) {
+	over := func(mb float64) checkers.Status {
+		if opts.MemoryCrit != nil && mb > *opts.MemoryCrit {
+			return checkers.CRITICAL
+		}
+		if opts.MemoryWarn != nil && mb > *opts.MemoryWarn {
+			return checkers.WARNING
+		}
+		return checkers.OK
+	}
+
+	result := checkers.OK
+	total := 0.0
+	var usages []string
+	for _, proc := range procs {
+		mb := float64(proc.rss*rssUnit) / 1024 / 1024
+		total += mb
+		usages = append(usages, fmt.Sprintf("pid %s %.2f MB", proc.pid, mb))
+		if st := over(mb); !opts.AggregateMem && st > result {
+			result = st
+		}
+	}
+	if opts.AggregateMem {
+		result = over(total)
+		return result, fmt.Sprintf("; memory total %.2f MB (%s)", total, strings.Join(usages, ", "))
+	}
+	return result, fmt.Sprintf("; memory (%s)", strings.Join(usages, ", "))
+}
+
+// evalFds checks the number of the open file descriptors of each process,
+// against the percentage of limit as well if limit is given
+func evalFds(opts *procsOpts, procs []procState, count func(pid string) (int64, error), limit int64) (checkers.Status, string) {
+	raise := func(result *checkers.Status, st checkers.Status) {
+		if st > *result {
+			*result = st
+		}
+	}
+
+	result := checkers.OK
+	var usages []string
+	for _, proc := range procs {
+		n, err := count(proc.pid)
+		if err != nil {
+			// the process may have exited since ps
+			if os.IsNotExist(err) {
+				continue
+			}
+			return checkers.UNKNOWN, fmt.Sprintf("; couldn't count fds of pid %s: %s", proc.pid, err)
+		}
+		usage := fmt.Sprintf("pid %s %s %d", proc.pid, proc.name, n)
+		st := checkers.OK
+		if opts.FdCrit != nil && n > *opts.FdCrit {
+			st = checkers.CRITICAL
+		} else if opts.FdWarn != nil && n > *opts.FdWarn {
+			st = checkers.WARNING
+		}
+		if limit > 0 {
+			pct := float64(n) / float64(limit) * 100
+			usage += fmt.Sprintf(" (%.2f%%)", pct)
+			if opts.FdPctCrit != nil && pct > *opts.FdPctCrit {
+				raise(&st, checkers.CRITICAL)
+			} else if opts.FdPctWarn != nil && pct > *opts.FdPctWarn {
+				raise(&st, checkers.WARNING)
+			}
+		}
+		raise(&result, st)
+		usages = append(usages, usage)
+	}
+	if len(usages) == 0 {
+		return result, ""
+	}
+	return result, fmt.Sprintf("; fds (%s)", strings.Join(usages, ", "))
+}
+
+func matchProc(opts *procsOpts, proc procState, cmdPatRegexp, cmdExcludePatRegexp, namePatRegexp *regexp.Regexp) bool {
 	return (opts.CmdPat == "" || cmdPatRegexp.MatchString(proc.cmd)) &&
+		(opts.NamePat == "" || namePatRegexp.MatchString(proc.name)) &&
 		(opts.CmdExcludePat == "" || !cmdExcludePatRegexp.MatchString(proc.cmd)) &&
 		(opts.MatchSelf || proc.pid != strconv.Itoa(os.Getpid())) &&
 		(opts.MatchParent || proc.pid != strconv.Itoa(os.Getppid())) &&
@@ -122,7 +287,7 @@ func matchProc(proc procState, cmdPatRegexp *regexp.Regexp, cmdExcludePatRegexp
 		(opts.Rss == 0 || proc.rss <= opts.Rss) &&
 		(opts.Pcpu == 0 || proc.pcpu <= opts.Pcpu) &&
 		(opts.Thcount == 0 || proc.thcount <= opts.Thcount) &&
-		(opts.State == "" || proc.state == opts.State) &&
+		(opts.State == "" || proc.state == opts.State || opts.Zombie && isZombie(proc.state)) &&
 		(opts.User == "" || proc.user == opts.User) &&
 		(opts.Usernot == "" || proc.user != opts.Usernot) &&
 		(opts.EsecUnder == 0 || proc.esec < opts.EsecUnder) &&
@@ -131,11 +296,20 @@ func matchProc(proc procState, cmdPatRegexp *regexp.Regexp, cmdExcludePatRegexp
 		(opts.CPUOver == 0 || proc.csec > opts.CPUOver)
 }
 
-func gatherMsg(count int64) string {
+// isZombie reports whether the state of ps is zombie, which can have the
+// additional flags like "Z+" or "Zs"
+func isZombie(state string) bool {
+	return strings.HasPrefix(state, "Z")
+}
+
+func gatherMsg(opts *procsOpts, count int64) string {
 	msg := fmt.Sprintf("Found %d matching processes", count)
 	if opts.CmdPat != "" {
 		msg += fmt.Sprintf("; cmd /%s/", opts.CmdPat)
 	}
+	if opts.NamePat != "" {
+		msg += fmt.Sprintf("; command /%s/", opts.NamePat)
+	}
 	if opts.State != "" {
 		msg += fmt.Sprintf("; state /%s/", opts.State)
 	}
@@ -177,3 +351,33 @@ func gatherMsg(count int64) string {
 	}
 	return msg
 }
+
+// zombieParents returns the pids of zombies and their parents, which should
+// reap them
+func zombieParents(procs []procState) string {
+	if len(procs) == 0 {
+		return ""
+	}
+	var zombies []string
+	for _, proc := range procs {
+		zombies = append(zombies, fmt.Sprintf("pid %s (ppid %s)", proc.pid, proc.ppid))
+	}
+	return "; zombies: " + strings.Join(zombies, ", ")
+}
+
+// procDetails returns the lines of up to max processes to append to the
+// message
+func procDetails(procs []procState, max int) string {
+	if max <= 0 || len(procs) == 0 {
+		return ""
+	}
+	var msg string
+	for i, proc := range procs {
+		if i >= max {
+			msg += fmt.Sprintf("\n... and %d more processes", len(procs)-max)
+			break
+		}
+		msg += fmt.Sprintf("\npid %s, ppid %s, user %s, state %s: %s", proc.pid, proc.ppid, proc.user, proc.state, proc.cmd)
+	}
+	return msg
+}
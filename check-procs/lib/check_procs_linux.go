@@ -0,0 +1,26 @@
+package checkprocs
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// countFds returns the number of the open file descriptors of the process
+func countFds(pid string) (int64, error) {
+	fds, err := ioutil.ReadDir(filepath.Join("/proc", pid, "fd"))
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(fds)), nil
+}
+
+// fdLimit returns the maximum number of file descriptors a process can open
+func fdLimit() (int64, error) {
+	b, err := ioutil.ReadFile("/proc/sys/fs/nr_open")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+}
@@ -0,0 +1,15 @@
+// +build !linux
+
+package checkprocs
+
+import "errors"
+
+var errFdNotSupported = errors.New("counting file descriptors is supported only on Linux")
+
+func countFds(pid string) (int64, error) {
+	return 0, errFdNotSupported
+}
+
+func fdLimit() (int64, error) {
+	return 0, errFdNotSupported
+}
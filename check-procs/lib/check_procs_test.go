@@ -1,8 +1,19 @@
 package checkprocs
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
 	"runtime"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestProcs(t *testing.T) {
@@ -43,3 +54,161 @@ func TestProcs(t *testing.T) {
 		}
 	}
 }
+
+func TestProcsName(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("/proc/self/comm is available only on Linux")
+	}
+	comm, err := ioutil.ReadFile("/proc/self/comm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	procs, err := getProcs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, proc := range procs {
+		if proc.pid == strconv.Itoa(os.Getpid()) {
+			assert.Equal(t, strings.TrimSpace(string(comm)), proc.name)
+			return
+		}
+	}
+	t.Fatal("the test process should be found")
+}
+
+func TestProcName(t *testing.T) {
+	assert.Equal(t, "nginx", procName("/usr/sbin/nginx -g daemon off;"))
+	assert.Equal(t, "sshd:", procName("sshd: user@pts/0"))
+	assert.Equal(t, "kworker/0:1", procName("[kworker/0:1]"))
+	assert.Equal(t, "", procName(""))
+}
+
+func TestMatchProc(t *testing.T) {
+	proc := procState{cmd: "/usr/bin/ruby /app/worker.rb", name: "ruby", user: "app", ppid: "1", pid: "100", state: "Ss"}
+	anyReg := regexp.MustCompile(".*")
+
+	opts := &procsOpts{NamePat: "^ruby$"}
+	assert.True(t, matchProc(opts, proc, anyReg, anyReg, regexp.MustCompile(opts.NamePat)))
+
+	opts = &procsOpts{NamePat: "^worker"}
+	assert.False(t, matchProc(opts, proc, anyReg, anyReg, regexp.MustCompile(opts.NamePat)), "--command should not match the arguments")
+
+	opts = &procsOpts{CmdPat: "worker"}
+	assert.True(t, matchProc(opts, proc, regexp.MustCompile(opts.CmdPat), anyReg, anyReg))
+
+	opts = &procsOpts{State: "Ss"}
+	assert.True(t, matchProc(opts, proc, anyReg, anyReg, anyReg))
+
+	opts = &procsOpts{State: "S"}
+	assert.False(t, matchProc(opts, proc, anyReg, anyReg, anyReg), "--state should match exactly")
+
+	opts = &procsOpts{State: "Z"}
+	assert.False(t, matchProc(opts, proc, anyReg, anyReg, anyReg))
+
+	zombie := procState{cmd: "[defunct]", ppid: "100", pid: "101", state: "Z+"}
+	assert.False(t, matchProc(opts, zombie, anyReg, anyReg, anyReg))
+	opts = &procsOpts{State: "Z", Zombie: true}
+	assert.True(t, matchProc(opts, zombie, anyReg, anyReg, anyReg), "--zombie should match the state with the additional flags")
+
+	opts = &procsOpts{User: "root"}
+	assert.False(t, matchProc(opts, proc, anyReg, anyReg, anyReg))
+}
+
+func TestProcDetails(t *testing.T) {
+	procs := []procState{
+		{cmd: "nginx: master process", user: "root", ppid: "1", pid: "10", state: "Ss"},
+		{cmd: "nginx: worker process", user: "www", ppid: "10", pid: "11", state: "S"},
+		{cmd: "nginx: worker process", user: "www", ppid: "10", pid: "12", state: "S"},
+	}
+	assert.Equal(t, "", procDetails(procs, 0))
+	assert.Equal(t, "\npid 10, ppid 1, user root, state Ss: nginx: master process\npid 11, ppid 10, user www, state S: nginx: worker process\n... and 1 more processes", procDetails(procs, 2))
+	assert.Equal(t, 3, len(regexp.MustCompile("\n").FindAllString(procDetails(procs, 3), -1)))
+}
+
+func TestEvalMemory(t *testing.T) {
+	mb := int64(1024 * 1024 / rssUnit)
+	procs := []procState{
+		{pid: "10", rss: 100 * mb},
+		{pid: "11", rss: 300 * mb},
+	}
+	warn, crit := 200.0, 350.0
+
+	st, msg := evalMemory(&procsOpts{MemoryWarn: &warn, MemoryCrit: &crit}, procs)
+	assert.Equal(t, checkers.WARNING, st)
+	assert.Equal(t, "; memory (pid 10 100.00 MB, pid 11 300.00 MB)", msg)
+
+	st, msg = evalMemory(&procsOpts{MemoryWarn: &warn, MemoryCrit: &crit, AggregateMem: true}, procs)
+	assert.Equal(t, checkers.CRITICAL, st)
+	assert.Equal(t, "; memory total 400.00 MB (pid 10 100.00 MB, pid 11 300.00 MB)", msg)
+
+	st, _ = evalMemory(&procsOpts{MemoryCrit: &crit}, procs)
+	assert.Equal(t, checkers.OK, st)
+}
+
+func TestZombie(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no zombie on windows")
+	}
+	ckr := run([]string{"--zombie", "--ppid", strconv.Itoa(os.Getpid())})
+	assert.Equal(t, checkers.OK, ckr.Status, "no zombie is not a problem")
+
+	// the child becomes a zombie until it is waited
+	cmd := exec.Command("true")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+	time.Sleep(100 * time.Millisecond)
+
+	ckr = run([]string{"--zombie", "--ppid", strconv.Itoa(os.Getpid())})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, fmt.Sprintf("Found 1 matching processes; state /Z/; ppid %d; zombies: pid %d (ppid %d)", os.Getpid(), cmd.Process.Pid, os.Getpid()), ckr.Message)
+
+	ckr = run([]string{"--zombie", "--ppid", strconv.Itoa(os.Getpid()), "--critical-over", "1"})
+	assert.Equal(t, checkers.OK, ckr.Status, "--critical-over should be able to be overridden")
+}
+
+func TestEvalFds(t *testing.T) {
+	procs := []procState{
+		{pid: "10", name: "nginx"},
+		{pid: "11", name: "nginx"},
+		{pid: "12", name: "nginx"},
+	}
+	fds := map[string]int64{"10": 100, "11": 600}
+	count := func(pid string) (int64, error) {
+		n, ok := fds[pid]
+		if !ok {
+			return 0, os.ErrNotExist
+		}
+		return n, nil
+	}
+	warn, crit := int64(500), int64(1000)
+
+	st, msg := evalFds(&procsOpts{FdWarn: &warn, FdCrit: &crit}, procs, count, 0)
+	assert.Equal(t, checkers.WARNING, st)
+	assert.Equal(t, "; fds (pid 10 nginx 100, pid 11 nginx 600)", msg, "exited processes should be skipped")
+
+	st, _ = evalFds(&procsOpts{FdCrit: &crit}, procs, count, 0)
+	assert.Equal(t, checkers.OK, st)
+
+	pctCrit := 50.0
+	st, msg = evalFds(&procsOpts{FdWarn: &warn, FdPctCrit: &pctCrit}, procs, count, 1024)
+	assert.Equal(t, checkers.CRITICAL, st)
+	assert.Equal(t, "; fds (pid 10 nginx 100 (9.77%), pid 11 nginx 600 (58.59%))", msg)
+
+	st, _ = evalFds(&procsOpts{FdWarn: &warn}, procs, func(string) (int64, error) { return 0, os.ErrPermission }, 0)
+	assert.Equal(t, checkers.UNKNOWN, st)
+}
+
+func TestFds(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("counting fds is supported only on Linux")
+	}
+	n, err := countFds(strconv.Itoa(os.Getpid()))
+	assert.Nil(t, err)
+	assert.True(t, n > 0)
+
+	limit, err := fdLimit()
+	assert.Nil(t, err)
+	assert.True(t, limit > 0)
+}
@@ -5,6 +5,7 @@ package checkprocs
 import (
 	"errors"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"strconv"
@@ -13,6 +14,9 @@ import (
 
 var threadsUnknown = runtime.GOOS == "darwin"
 
+// rss of ps is in KiB
+const rssUnit = 1024
+
 func getProcs() (proc []procState, err error) {
 	var procs []procState
 	psformat := "user,ppid,pid,vsz,rss,pcpu,nlwp,state,etime,time,command"
@@ -23,16 +27,48 @@ func getProcs() (proc []procState, err error) {
 	if err != nil {
 		return nil, err
 	}
+	names, err := getProcNames()
+	if err != nil {
+		return nil, err
+	}
 	for _, line := range strings.Split(string(output), "\n")[1:] {
 		proc, err := parseProcState(line)
 		if err != nil {
 			continue
 		}
+		// the process may have started after ps for the names
+		if name, ok := names[proc.pid]; ok {
+			proc.name = name
+		}
 		procs = append(procs, proc)
 	}
 	return procs, nil
 }
 
+// getProcNames returns comm of ps by pid, which is the name of the
+// executable not affected by the command line rewritten by the process
+func getProcNames() (map[string]string, error) {
+	output, err := exec.Command("ps", "axwwo", "pid,comm").Output()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n")[1:] {
+		// comm is the last column and can contain spaces
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 2)
+		if len(fields) < 2 {
+			continue
+		}
+		name := strings.TrimSpace(fields[1])
+		// comm is the path of the executable on some platforms like darwin
+		if strings.HasPrefix(name, "/") {
+			name = filepath.Base(name)
+		}
+		names[fields[0]] = name
+	}
+	return names, nil
+}
+
 func parseProcState(line string) (proc procState, err error) {
 	fields := strings.Fields(line)
 	fieldsMinLen := 11
@@ -48,12 +84,14 @@ func parseProcState(line string) (proc procState, err error) {
 	if threadsUnknown {
 		esec := timeStrToSeconds(fields[7])
 		csec := timeStrToSeconds(fields[8])
-		return procState{strings.Join(fields[9:], " "), fields[0], fields[1], fields[2], vsz, rss, pcpu, 1, fields[6], esec, csec}, nil
+		cmd := strings.Join(fields[9:], " ")
+		return procState{cmd, procName(cmd), fields[0], fields[1], fields[2], vsz, rss, pcpu, 1, fields[6], esec, csec}, nil
 	}
 	thcount, _ := strconv.ParseInt(fields[6], 10, 64)
 	esec := timeStrToSeconds(fields[8])
 	csec := timeStrToSeconds(fields[9])
-	return procState{strings.Join(fields[10:], " "), fields[0], fields[1], fields[2], vsz, rss, pcpu, thcount, fields[7], esec, csec}, nil
+	cmd := strings.Join(fields[10:], " ")
+	return procState{cmd, procName(cmd), fields[0], fields[1], fields[2], vsz, rss, pcpu, thcount, fields[7], esec, csec}, nil
 }
 
 var timeRegexp = regexp.MustCompile(`(?:(\d+)-)?(?:(\d+):)?(\d+)[:.](\d+)`)
@@ -6,6 +6,9 @@ import (
 	"github.com/StackExchange/wmi"
 )
 
+// WorkingSet is in bytes
+const rssUnit = 1
+
 // Win32PerfFormattedDataPerfProcProcess is struct for Win32_PerfFormattedData_PerfProc_Process.
 type Win32PerfFormattedDataPerfProcProcess struct {
 	ElapsedTime          uint64
@@ -26,6 +29,7 @@ func getProcs() (proc []procState, err error) {
 	for _, record := range records {
 		proc = append(proc, procState{
 			cmd:     record.Name,
+			name:    record.Name,
 			pid:     fmt.Sprint(record.IDProcess),
 			vsz:     int64(record.VirtualBytes),
 			rss:     int64(record.WorkingSet),
@@ -0,0 +1,111 @@
+package checkrabbitmq
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+type rabbitmqOpts struct {
+	Host         string   `short:"H" long:"host" default:"localhost" description:"Hostname"`
+	Port         int      `short:"p" long:"port" default:"15672" description:"Port of the management API"`
+	User         string   `short:"u" long:"user" default:"guest" description:"Username"`
+	Password     string   `long:"password" default:"guest" description:"Password" env:"RABBITMQ_PASSWORD"`
+	Vhost        string   `long:"vhost" default:"%2F" description:"URL-encoded virtual host"`
+	Timeout      int      `long:"timeout" default:"10" description:"Timeout in seconds"`
+	Queue        string   `long:"queue" description:"Queue name (required unless --node-check)"`
+	Warn         *int64   `short:"w" long:"warning" value-name:"N" description:"warning if the number of messages in the queue is over"`
+	Crit         *int64   `short:"c" long:"critical" value-name:"N" description:"critical if the number of messages in the queue is over"`
+	ConsumerCrit *int64   `long:"consumers-critical" value-name:"N" description:"critical if the number of consumers is under"`
+	NodeCheck    bool     `long:"node-check" description:"check the health of the node instead of a queue"`
+	Node         string   `long:"node" description:"Node name to check (default: the node of /api/overview)"`
+	FdWarn       *float64 `long:"fd-usage-warning" value-name:"PERCENT" description:"warning if fd_used is over the percentage of fd_total (with --node-check)"`
+	FdCrit       *float64 `long:"fd-usage-critical" value-name:"PERCENT" description:"critical if fd_used is over the percentage of fd_total (with --node-check)"`
+	MemWarn      *float64 `long:"memory-usage-warning" value-name:"PERCENT" description:"warning if mem_used is over the percentage of mem_limit (with --node-check)"`
+	MemCrit      *float64 `long:"memory-usage-critical" value-name:"PERCENT" description:"critical if mem_used is over the percentage of mem_limit (with --node-check)"`
+}
+
+type queueStat struct {
+	Messages     int64 `json:"messages"`
+	Consumers    int64 `json:"consumers"`
+	MessageStats struct {
+		PublishDetails struct {
+			Rate float64 `json:"rate"`
+		} `json:"publish_details"`
+	} `json:"message_stats"`
+}
+
+// Do the plugin
+func Do() {
+	ckr := run(os.Args[1:])
+	ckr.Name = "RabbitMQ"
+	ckr.Exit()
+}
+
+// getJSON requests the path of the management API and decodes the JSON
+// response into v
+func getJSON(opts *rabbitmqOpts, path string, v interface{}) error {
+	uri := fmt.Sprintf("http://%s:%d%s", opts.Host, opts.Port, path)
+
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "check-rabbitmq")
+	req.SetBasicAuth(opts.User, opts.Password)
+
+	client := &http.Client{Timeout: time.Duration(opts.Timeout) * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func evalQueue(name string, stat queueStat, opts *rabbitmqOpts) *checkers.Checker {
+	checkSt := checkers.OK
+	if opts.Crit != nil && stat.Messages > *opts.Crit {
+		checkSt = checkers.CRITICAL
+	} else if opts.Warn != nil && stat.Messages > *opts.Warn {
+		checkSt = checkers.WARNING
+	}
+	if opts.ConsumerCrit != nil && stat.Consumers < *opts.ConsumerCrit {
+		checkSt = checkers.CRITICAL
+	}
+
+	msg := fmt.Sprintf("%s: %d messages, %d consumers, publish %.2f/s",
+		name, stat.Messages, stat.Consumers, stat.MessageStats.PublishDetails.Rate)
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func run(args []string) *checkers.Checker {
+	opts := &rabbitmqOpts{}
+	_, err := flags.ParseArgs(opts, args)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	if opts.NodeCheck {
+		return checkNode(opts)
+	}
+	if opts.Queue == "" {
+		return checkers.Unknown("--queue is required unless --node-check")
+	}
+
+	var stat queueStat
+	path := fmt.Sprintf("/api/queues/%s/%s", opts.Vhost, url.PathEscape(opts.Queue))
+	if err := getJSON(opts, path, &stat); err != nil {
+		return checkers.Critical(err.Error())
+	}
+	return evalQueue(opts.Queue, stat, opts)
+}
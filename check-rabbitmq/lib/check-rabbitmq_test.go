@@ -0,0 +1,113 @@
+package checkrabbitmq
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRabbitMQ serves the JSON bodies for the escaped paths
+func fakeRabbitMQ(bodies map[string]string) (*httptest.Server, []string) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "guest" || pass != "guest" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, ok := bodies[r.URL.EscapedPath()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	u, _ := url.Parse(ts.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	return ts, []string{"-H", host, "-p", port}
+}
+
+func TestQueue(t *testing.T) {
+	ts, args := fakeRabbitMQ(map[string]string{
+		"/api/queues/%2F/jobs":        `{"name":"jobs","messages":120,"consumers":2,"message_stats":{"publish":1000,"publish_details":{"rate":3.5}}}`,
+		"/api/queues/app/new%20queue": `{"name":"new queue","messages":0,"consumers":0}`,
+	})
+	defer ts.Close()
+
+	ckr := run(append(args, "--queue", "jobs"))
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "jobs: 120 messages, 2 consumers, publish 3.50/s", ckr.Message)
+
+	ckr = run(append(args, "--queue", "jobs", "-w", "100", "-c", "1000"))
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	ckr = run(append(args, "--queue", "jobs", "-w", "100", "-c", "110"))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = run(append(args, "--queue", "jobs", "--consumers-critical", "3"))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = run(append(args, "--queue", "new queue", "--vhost", "app", "--consumers-critical", "0"))
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "new queue: 0 messages, 0 consumers, publish 0.00/s", ckr.Message)
+
+	ckr = run(append(args, "--queue", "missing"))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "/api/queues/%2F/missing returned 404 Not Found", ckr.Message)
+
+	ckr = run(append(args, "--queue", "jobs", "--password", "wrong"))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+}
+
+const nodeJSON = `{"name":"rabbit@mq1","running":true,"mem_alarm":false,"disk_free_alarm":false,"fd_used":512,"fd_total":1024,"mem_used":268435456,"mem_limit":1073741824}`
+
+func TestNode(t *testing.T) {
+	ts, args := fakeRabbitMQ(map[string]string{
+		"/api/overview":             `{"node":"rabbit@mq1","cluster_name":"rabbit@mq1"}`,
+		"/api/nodes/rabbit@mq1":     nodeJSON,
+		"/api/nodes/rabbit@mq2":     `{"name":"rabbit@mq2","running":true,"mem_alarm":true,"disk_free_alarm":false,"fd_used":10,"fd_total":1024,"mem_used":1073741824,"mem_limit":1073741824}`,
+		"/api/nodes/rabbit@stopped": `{"name":"rabbit@stopped","running":false}`,
+	})
+	defer ts.Close()
+
+	ckr := run(append(args, "--node-check"))
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "rabbit@mq1: running: true, mem_alarm: false, disk_free_alarm: false, fd 512 / 1024 (50.00%), memory 256.00 MB / 1.00 GB (25.00%)", ckr.Message)
+
+	ckr = run(append(args, "--node-check", "--fd-usage-warning", "40", "--fd-usage-critical", "80"))
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	ckr = run(append(args, "--node-check", "--memory-usage-warning", "10", "--memory-usage-critical", "20"))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = run(append(args, "--node-check", "--node", "rabbit@mq2"))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Contains(t, ckr.Message, "mem_alarm: true")
+
+	ckr = run(append(args, "--node-check", "--node", "rabbit@stopped"))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "rabbit@stopped: running: false, mem_alarm: false, disk_free_alarm: false, fd 0 / 0 (0.00%), memory 0.00 B / 0.00 B (0.00%)", ckr.Message)
+
+	ckr = run(args)
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status)
+	assert.Equal(t, "--queue is required unless --node-check", ckr.Message)
+}
+
+func TestTimeout(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+	u, _ := url.Parse(ts.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+
+	ckr := run([]string{"-H", host, "-p", port, "--queue", "jobs", "--timeout", "1"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Contains(t, ckr.Message, "Timeout")
+}
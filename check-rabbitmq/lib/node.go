@@ -0,0 +1,76 @@
+package checkrabbitmq
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/mackerelio/checkers"
+	"github.com/mackerelio/go-check-plugins/check-file-size/lib"
+)
+
+type nodeStat struct {
+	Name          string  `json:"name"`
+	Running       bool    `json:"running"`
+	MemAlarm      bool    `json:"mem_alarm"`
+	DiskFreeAlarm bool    `json:"disk_free_alarm"`
+	FdUsed        float64 `json:"fd_used"`
+	FdTotal       float64 `json:"fd_total"`
+	MemUsed       float64 `json:"mem_used"`
+	MemLimit      float64 `json:"mem_limit"`
+}
+
+func percentage(used, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return used / total * 100
+}
+
+func evalNode(stat nodeStat, opts *rabbitmqOpts) *checkers.Checker {
+	checkSt := checkers.OK
+	raise := func(critical, warning bool) {
+		if critical {
+			checkSt = checkers.CRITICAL
+		} else if warning && checkSt == checkers.OK {
+			checkSt = checkers.WARNING
+		}
+	}
+	over := func(v float64, threshold *float64) bool {
+		return threshold != nil && v > *threshold
+	}
+
+	raise(!stat.Running || stat.MemAlarm || stat.DiskFreeAlarm, false)
+
+	fdUsage := percentage(stat.FdUsed, stat.FdTotal)
+	raise(over(fdUsage, opts.FdCrit), over(fdUsage, opts.FdWarn))
+	memUsage := percentage(stat.MemUsed, stat.MemLimit)
+	raise(over(memUsage, opts.MemCrit), over(memUsage, opts.MemWarn))
+
+	msg := fmt.Sprintf("%s: running: %t, mem_alarm: %t, disk_free_alarm: %t, fd %.0f / %.0f (%.2f%%), memory %s / %s (%.2f%%)",
+		stat.Name, stat.Running, stat.MemAlarm, stat.DiskFreeAlarm,
+		stat.FdUsed, stat.FdTotal, fdUsage,
+		checkfilesize.HumanizeBytes(stat.MemUsed), checkfilesize.HumanizeBytes(stat.MemLimit), memUsage)
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func checkNode(opts *rabbitmqOpts) *checkers.Checker {
+	node := opts.Node
+	if node == "" {
+		var overview struct {
+			Node string `json:"node"`
+		}
+		if err := getJSON(opts, "/api/overview", &overview); err != nil {
+			return checkers.Critical(err.Error())
+		}
+		if overview.Node == "" {
+			return checkers.Unknown("couldn't detect the node from /api/overview")
+		}
+		node = overview.Node
+	}
+
+	var stat nodeStat
+	if err := getJSON(opts, "/api/nodes/"+url.PathEscape(node), &stat); err != nil {
+		return checkers.Critical(err.Error())
+	}
+	return evalNode(stat, opts)
+}
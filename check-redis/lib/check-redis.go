@@ -1,14 +1,17 @@
 package checkredis
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
-	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/fzzy/radix/redis"
 	"github.com/jessevdk/go-flags"
 	"github.com/mackerelio/checkers"
 )
@@ -18,11 +21,22 @@ type redisSetting struct {
 	Socket  string `short:"s" long:"socket" default:"" description:"Server socket"`
 	Port    string `short:"p" long:"port" default:"6379" description:"Port"`
 	Timeout uint64 `short:"t" long:"timeout" default:"5" description:"Dial Timeout in sec"`
+	Auth    string `short:"a" long:"auth" default:"" description:"Password for AUTH" env:"REDIS_PASSWORD"`
+	DB      int    `long:"db" default:"0" description:"Database number to SELECT"`
+
+	TLS           bool   `long:"tls" description:"Connect with TLS"`
+	TLSCA         string `long:"tls-ca" value-name:"FILE" description:"CA certificate file to verify the server (with --tls)"`
+	TLSCert       string `long:"tls-cert" value-name:"FILE" description:"Client certificate file (with --tls)"`
+	TLSKey        string `long:"tls-key" value-name:"FILE" description:"Client private key file (with --tls)"`
+	TLSSkipVerify bool   `long:"tls-skip-verify" description:"Do not verify the server certificate (with --tls)"`
 }
 
 var commands = map[string](func([]string) *checkers.Checker){
 	"reachable":   checkReachable,
 	"replication": checkReplication,
+	"memory":      checkMemory,
+	"clients":     checkClients,
+	"cluster":     checkCluster,
 	"slave":       checkSlave, // deprecated command
 }
 
@@ -52,37 +66,82 @@ SubCommands:`)
 	ckr.Exit()
 }
 
-func connectRedis(m redisSetting) (*redis.Client, error) {
+func connectRedis(m redisSetting) (*client, error) {
 	network := "tcp"
-	target := fmt.Sprintf("%s:%s", m.Host, m.Port)
+	target := net.JoinHostPort(m.Host, m.Port)
 	if m.Socket != "" {
 		target = m.Socket
 		network = "unix"
 	}
-	c, err := redis.DialTimeout(network, target, time.Duration(m.Timeout)*time.Second)
+	timeout := time.Duration(m.Timeout) * time.Second
+	conn, err := net.DialTimeout(network, target, timeout)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't connect: %s", err)
 	}
+	if m.TLS {
+		config, err := newTLSConfig(m)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		tlsConn := tls.Client(conn, config)
+		tlsConn.SetDeadline(time.Now().Add(timeout))
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("couldn't connect: %s", err)
+		}
+		conn = tlsConn
+	}
+	c := newClient(conn, timeout)
+	if m.Auth != "" {
+		if _, err := c.Cmd("AUTH", m.Auth); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("couldn't authenticate: %s", err)
+		}
+	}
+	if m.DB != 0 {
+		if _, err := c.Cmd("SELECT", fmt.Sprint(m.DB)); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("couldn't select db %d: %s", m.DB, err)
+		}
+	}
 	return c, nil
 }
 
-func getRedisInfo(c *redis.Client) (*map[string]string, error) {
-	info := make(map[string]string)
-
-	r := c.Cmd("info")
-	if r.Err != nil {
-		return nil, errors.New("couldn't execute query")
+func newTLSConfig(m redisSetting) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         m.Host,
+		InsecureSkipVerify: m.TLSSkipVerify,
 	}
-	str, err := r.Str()
-	if err != nil {
-		return nil, errors.New("couldn't execute query")
+	if m.TLSCA != "" {
+		pem, err := ioutil.ReadFile(m.TLSCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("failed to parse CA certificates: %s", m.TLSCA)
+		}
+		config.RootCAs = pool
+	}
+	if (m.TLSCert == "") != (m.TLSKey == "") {
+		return nil, fmt.Errorf("Both --tls-cert and --tls-key are required for the client certificate")
+	}
+	if m.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(m.TLSCert, m.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
 	}
+	return config, nil
+}
 
+// parseInfo parses the output of the INFO command into a map
+func parseInfo(str string) map[string]string {
+	info := make(map[string]string)
 	for _, line := range strings.Split(str, "\r\n") {
-		if line == "" {
-			continue
-		}
-		if re, _ := regexp.MatchString("^#", line); re {
+		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
 
@@ -93,26 +152,42 @@ func getRedisInfo(c *redis.Client) (*map[string]string, error) {
 		key, value := record[0], record[1]
 		info[key] = value
 	}
+	return info
+}
 
+// getRedisInfo runs INFO with the optional section, e.g. "memory"
+func getRedisInfo(c *client, section ...string) (*map[string]string, error) {
+	str, err := c.Str(append([]string{"INFO"}, section...)...)
+	if err != nil {
+		return nil, errors.New("couldn't execute query")
+	}
+	info := parseInfo(str)
 	return &info, nil
 }
 
-func connectRedisGetInfo(opts redisSetting) (*redis.Client, *map[string]string, error) {
+func connectRedisGetInfo(opts redisSetting, section ...string) (*client, *map[string]string, error) {
 	c, err := connectRedis(opts)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	info, err := getRedisInfo(c)
+	info, err := getRedisInfo(c, section...)
 	if err != nil {
+		c.Close()
 		return nil, nil, err
 	}
 
 	return c, info, nil
 }
 
+type reachableOpts struct {
+	redisSetting
+	Crit float64 `short:"c" long:"critical" value-name:"MSEC" description:"critical if the round-trip time of PING is over (ms)"`
+	Warn float64 `short:"w" long:"warning" value-name:"MSEC" description:"warning if the round-trip time of PING is over (ms)"`
+}
+
 func checkReachable(args []string) *checkers.Checker {
-	opts := redisSetting{}
+	opts := reachableOpts{}
 	psr := flags.NewParser(&opts, flags.Default)
 	psr.Usage = "reachable [OPTIONS]"
 	_, err := psr.ParseArgs(args)
@@ -121,24 +196,46 @@ func checkReachable(args []string) *checkers.Checker {
 		os.Exit(1)
 	}
 
-	c, info, err := connectRedisGetInfo(opts)
+	c, err := connectRedis(opts.redisSetting)
 	if err != nil {
-		return checkers.Unknown(err.Error())
+		return checkers.Critical(err.Error())
 	}
 	defer c.Close()
 
+	start := time.Now()
+	pong, err := c.Str("PING")
+	if err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't execute PING: %s", err))
+	}
+	rtt := float64(time.Since(start)) / float64(time.Millisecond)
+	if pong != "PONG" {
+		return checkers.Critical(fmt.Sprintf("unexpected reply of PING: %s", pong))
+	}
+
+	info, err := getRedisInfo(c, "server")
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
 	if _, ok := (*info)["redis_version"]; !ok {
 		return checkers.Unknown("couldn't get redis_version")
 	}
 
-	return checkers.Ok(
-		fmt.Sprintf("version: %s", (*info)["redis_version"]),
-	)
+	checkSt := checkers.OK
+	msg := fmt.Sprintf("version: %s, PING in %.3f ms", (*info)["redis_version"], rtt)
+	if opts.Crit > 0 && rtt > opts.Crit {
+		checkSt = checkers.CRITICAL
+	} else if opts.Warn > 0 && rtt > opts.Warn {
+		checkSt = checkers.WARNING
+	}
+	return checkers.NewChecker(checkSt, msg)
 }
 
 type replicationOpts struct {
 	redisSetting
-	SkipMaster bool `long:"skip-master" description:"return ok if redis role is master"`
+	SkipMaster bool   `long:"skip-master" description:"return ok if redis role is master"`
+	Crit       *int64 `long:"replication-critical" value-name:"N" description:"critical if the replication lag is over N bytes (or seconds with --replication-seconds)"`
+	Warn       *int64 `long:"replication-warning" value-name:"N" description:"warning if the replication lag is over N bytes (or seconds with --replication-seconds)"`
+	Seconds    bool   `long:"replication-seconds" description:"use master_last_io_seconds_ago instead of the lag in bytes for the thresholds. The lag in bytes needs a connection to master_host:master_port with the same password and TLS options, and master_last_io_seconds_ago is used if it fails"`
 }
 
 func checkReplication(args []string) *checkers.Checker {
@@ -165,21 +262,78 @@ func checkReplication(args []string) *checkers.Checker {
 		return checkers.Unknown("couldn't get role")
 	}
 
-	if status, ok := (*info)["master_link_status"]; ok {
-		msg := fmt.Sprintf("master_link_status: %s", status)
-
-		switch status {
-		case "up":
-			return checkers.Ok(msg)
-		case "down":
-			return checkers.Critical(msg)
-		default:
-			return checkers.Unknown(msg)
+	var masterInfo map[string]string
+	if (opts.Warn != nil || opts.Crit != nil) && !opts.Seconds && (*info)["master_link_status"] == "up" {
+		// the replica knows only its own offset, so the offset of the
+		// master is taken from the master itself
+		m := opts.redisSetting
+		m.Host = (*info)["master_host"]
+		m.Port = (*info)["master_port"]
+		m.Socket = ""
+		mc, mi, err := connectRedisGetInfo(m, "replication")
+		if err != nil {
+			// the master is not always reachable from where the replica
+			// is checked, so fall back to master_last_io_seconds_ago
+			opts.Seconds = true
+			ckr := evalReplication(*info, nil, opts)
+			ckr.Message += fmt.Sprintf(" (couldn't get the offset of the master %s: %s)", net.JoinHostPort(m.Host, m.Port), err)
+			return ckr
 		}
+		mc.Close()
+		masterInfo = *mi
+	}
+	return evalReplication(*info, masterInfo, opts)
+}
 
-	} else {
+// evalReplication checks master_link_status and the replication lag. The lag
+// in bytes is the difference between the offset of the master
+// (master_repl_offset in the INFO of the master) and the offset processed by
+// the replica (slave_repl_offset).
+func evalReplication(info, masterInfo map[string]string, opts replicationOpts) *checkers.Checker {
+	status, ok := info["master_link_status"]
+	if !ok {
 		return checkers.Unknown("couldn't get master_link_status")
 	}
+	msg := fmt.Sprintf("master_link_status: %s", status)
+
+	switch status {
+	case "up":
+	case "down":
+		return checkers.Critical(msg)
+	default:
+		return checkers.Unknown(msg)
+	}
+
+	if opts.Warn == nil && opts.Crit == nil {
+		return checkers.Ok(msg)
+	}
+
+	lastIO, err := strconv.ParseInt(info["master_last_io_seconds_ago"], 10, 64)
+	if err != nil {
+		return checkers.Unknown("couldn't get master_last_io_seconds_ago")
+	}
+	value := lastIO
+	if !opts.Seconds {
+		masterOffset, err := strconv.ParseInt(masterInfo["master_repl_offset"], 10, 64)
+		if err != nil {
+			return checkers.Unknown("couldn't get master_repl_offset of the master")
+		}
+		slaveOffset, err := strconv.ParseInt(info["slave_repl_offset"], 10, 64)
+		if err != nil {
+			return checkers.Unknown("couldn't get slave_repl_offset")
+		}
+		value = masterOffset - slaveOffset
+		msg += fmt.Sprintf(", lag: %d bytes", value)
+	}
+	msg += fmt.Sprintf(", last IO: %d seconds ago", lastIO)
+
+	checkSt := checkers.OK
+	if opts.Crit != nil && value > *opts.Crit {
+		checkSt = checkers.CRITICAL
+	} else if opts.Warn != nil && value > *opts.Warn {
+		checkSt = checkers.WARNING
+	}
+	return checkers.NewChecker(checkSt, msg)
 }
 
 // Deprecated: For backward compatibility.
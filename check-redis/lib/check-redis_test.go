@@ -0,0 +1,339 @@
+package checkredis
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedis serves the raw replies for the commands, e.g. "PING": "+PONG\r\n".
+// A command not in replies gets an error reply.
+func fakeRedis(t *testing.T, replies map[string]string) (host, port string, closer func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serveFakeRedisListener(ln, replies)
+}
+
+// fakeRedisTLS is fakeRedis over TLS with the certificate of httptest, which
+// is valid for 127.0.0.1. The CA certificate is returned in PEM.
+func fakeRedisTLS(t *testing.T, replies map[string]string) (host, port string, caPEM []byte, closer func()) {
+	ts := httptest.NewTLSServer(nil)
+	ts.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln = tls.NewListener(ln, &tls.Config{Certificates: ts.TLS.Certificates})
+	caPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	host, port, closer = serveFakeRedisListener(ln, replies)
+	return host, port, caPEM, closer
+}
+
+func serveFakeRedisListener(ln net.Listener, replies map[string]string) (host, port string, closer func()) {
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeRedis(conn, replies)
+		}
+	}()
+	host, port, _ = net.SplitHostPort(ln.Addr().String())
+	return host, port, func() { ln.Close() }
+}
+
+func serveFakeRedis(conn net.Conn, replies map[string]string) {
+	defer conn.Close()
+	c := newClient(conn, 0)
+	for {
+		req, err := c.readReply()
+		if err != nil {
+			return
+		}
+		args := make([]string, 0)
+		for _, a := range req.([]interface{}) {
+			args = append(args, a.(string))
+		}
+		reply, ok := replies[strings.Join(args, " ")]
+		if !ok {
+			reply = fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+		}
+		conn.Write([]byte(reply))
+	}
+}
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+func TestClientReplies(t *testing.T) {
+	host, port, closer := fakeRedis(t, map[string]string{
+		"PING":      "+PONG\r\n",
+		"DBSIZE":    ":42\r\n",
+		"GET none":  "$-1\r\n",
+		"KEYS *":    "*2\r\n$3\r\nfoo\r\n$3\r\nbar\r\n",
+		"INFO bulk": bulk("a:1\r\nb:2\r\n"),
+	})
+	defer closer()
+
+	c, err := connectRedis(redisSetting{Host: host, Port: port, Timeout: 1})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	s, err := c.Str("PING")
+	assert.NoError(t, err)
+	assert.Equal(t, "PONG", s)
+
+	r, err := c.Cmd("DBSIZE")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), r)
+
+	r, err = c.Cmd("GET", "none")
+	assert.NoError(t, err)
+	assert.Nil(t, r)
+
+	r, err = c.Cmd("KEYS", "*")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"foo", "bar"}, r)
+
+	s, err = c.Str("INFO", "bulk")
+	assert.NoError(t, err)
+	assert.Equal(t, "a:1\r\nb:2\r\n", s)
+
+	_, err = c.Cmd("FLUSHALL")
+	assert.Equal(t, redisError("ERR unknown command 'FLUSHALL'"), err)
+}
+
+func TestReachable(t *testing.T) {
+	host, port, closer := fakeRedis(t, map[string]string{
+		"AUTH secret": "+OK\r\n",
+		"SELECT 2":    "+OK\r\n",
+		"PING":        "+PONG\r\n",
+		"INFO server": bulk("# Server\r\nredis_version:6.2.6\r\n"),
+	})
+	defer closer()
+
+	ckr := checkReachable([]string{"-H", host, "-p", port, "-a", "secret", "--db", "2"})
+	assert.Equal(t, checkers.OK, ckr.Status, ckr.Message)
+	assert.Contains(t, ckr.Message, "version: 6.2.6, PING in ")
+
+	ckr = checkReachable([]string{"-H", host, "-p", port, "-a", "wrong"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Contains(t, ckr.Message, "couldn't authenticate")
+
+	closer()
+	ckr = checkReachable([]string{"-H", host, "-p", port})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Contains(t, ckr.Message, "couldn't connect")
+}
+
+func TestEvalMemory(t *testing.T) {
+	info := map[string]string{
+		"used_memory":               "524288000",
+		"used_memory_human":         "500.00M",
+		"used_memory_rss":           "629145600",
+		"used_memory_rss_human":     "600.00M",
+		"maxmemory":                 "1073741824",
+		"maxmemory_human":           "1.00G",
+		"total_system_memory":       "8589934592",
+		"total_system_memory_human": "8.00G",
+	}
+	th := func(s string) *memThreshold {
+		t, _ := parseMemThreshold(s, true)
+		return t
+	}
+
+	ckr := evalMemory(info, th("80%"), th("90%"), nil, nil)
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "used_memory: 500.00M / maxmemory: 1.00G (48.83%), used_memory_rss: 600.00M", ckr.Message)
+
+	assert.Equal(t, checkers.WARNING, evalMemory(info, th("40%"), th("90%"), nil, nil).Status)
+	assert.Equal(t, checkers.CRITICAL, evalMemory(info, th("40%"), th("400M"), nil, nil).Status)
+	assert.Equal(t, checkers.WARNING, evalMemory(info, nil, nil, th("512M"), th("1G")).Status)
+	assert.Equal(t, checkers.CRITICAL, evalMemory(info, nil, nil, th("512M"), th("512M")).Status)
+
+	info["maxmemory"] = "0"
+	ckr = evalMemory(info, th("5%"), nil, nil, nil)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "used_memory: 500.00M / total_system_memory: 8.00G (6.10%), used_memory_rss: 600.00M", ckr.Message)
+
+	delete(info, "total_system_memory")
+	assert.Equal(t, checkers.UNKNOWN, evalMemory(info, th("5%"), nil, nil, nil).Status)
+	assert.Equal(t, checkers.OK, evalMemory(info, th("1G"), nil, nil, nil).Status)
+}
+
+func TestEvalReplication(t *testing.T) {
+	info := map[string]string{
+		"role":                       "slave",
+		"master_link_status":         "up",
+		"master_last_io_seconds_ago": "3",
+		"master_repl_offset":         "10000",
+		"slave_repl_offset":          "10000",
+	}
+	masterInfo := map[string]string{
+		"role":               "master",
+		"master_repl_offset": "15000",
+	}
+	n := func(v int64) *int64 { return &v }
+
+	ckr := evalReplication(info, nil, replicationOpts{})
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "master_link_status: up", ckr.Message)
+
+	ckr = evalReplication(info, masterInfo, replicationOpts{Warn: n(10000), Crit: n(20000)})
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "master_link_status: up, lag: 5000 bytes, last IO: 3 seconds ago", ckr.Message)
+
+	assert.Equal(t, checkers.WARNING, evalReplication(info, masterInfo, replicationOpts{Warn: n(4000), Crit: n(20000)}).Status)
+	assert.Equal(t, checkers.CRITICAL, evalReplication(info, masterInfo, replicationOpts{Warn: n(4000), Crit: n(4500)}).Status)
+	assert.Equal(t, checkers.UNKNOWN, evalReplication(info, nil, replicationOpts{Warn: n(4000), Crit: n(4500)}).Status)
+
+	ckr = evalReplication(info, nil, replicationOpts{Warn: n(2), Crit: n(10), Seconds: true})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "master_link_status: up, last IO: 3 seconds ago", ckr.Message)
+	assert.Equal(t, checkers.OK, evalReplication(info, nil, replicationOpts{Warn: n(5), Crit: n(10), Seconds: true}).Status)
+
+	info["master_link_status"] = "down"
+	ckr = evalReplication(info, nil, replicationOpts{Warn: n(100000), Crit: n(200000)})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "master_link_status: down", ckr.Message)
+}
+
+func TestReplication(t *testing.T) {
+	masterHost, masterPort, closeMaster := fakeRedis(t, map[string]string{
+		"INFO replication": bulk("# Replication\r\nrole:master\r\nconnected_slaves:1\r\nslave0:ip=127.0.0.1,port=6380,state=online,offset=9000,lag=0\r\nmaster_repl_offset:15000\r\n"),
+	})
+	defer closeMaster()
+	host, port, closer := fakeRedis(t, map[string]string{
+		"INFO": bulk("# Replication\r\nrole:slave\r\nmaster_host:" + masterHost + "\r\nmaster_port:" + masterPort +
+			"\r\nmaster_link_status:up\r\nmaster_last_io_seconds_ago:1\r\nslave_repl_offset:9000\r\nmaster_repl_offset:9000\r\n"),
+	})
+	defer closer()
+
+	ckr := checkReplication([]string{"-H", host, "-p", port, "--replication-warning=1000", "--replication-critical=10000"})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "master_link_status: up, lag: 6000 bytes, last IO: 1 seconds ago", ckr.Message)
+
+	// master_last_io_seconds_ago is used when the master is unreachable
+	closeMaster()
+	ckr = checkReplication([]string{"-H", host, "-p", port, "--replication-warning=1000"})
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Regexp(t, `^master_link_status: up, last IO: 1 seconds ago \(couldn't get the offset of the master `, ckr.Message)
+
+	ckr = checkReplication([]string{"-H", host, "-p", port, "--replication-warning=0"})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+}
+
+func TestClients(t *testing.T) {
+	host, port, closer := fakeRedis(t, map[string]string{
+		"INFO clients":          bulk("# Clients\r\nconnected_clients:150\r\nblocked_clients:2\r\n"),
+		"CONFIG GET maxclients": "*2\r\n" + bulk("maxclients") + bulk("10000"),
+	})
+	defer closer()
+
+	ckr := checkClients([]string{"-H", host, "-p", port, "--clients-warning=100", "--clients-critical=200"})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "connected_clients: 150 (1.50% of maxclients 10000), blocked_clients: 2", ckr.Message)
+
+	ckr = checkClients([]string{"-H", host, "-p", port, "--blocked-clients-critical=1"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+}
+
+func TestEvalClients(t *testing.T) {
+	info := map[string]string{"connected_clients": "10", "blocked_clients": "0"}
+	n := func(v int64) *int64 { return &v }
+
+	ckr := evalClients(info, 0, clientsOpts{})
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "connected_clients: 10, blocked_clients: 0", ckr.Message)
+
+	assert.Equal(t, checkers.CRITICAL, evalClients(info, 0, clientsOpts{Warn: n(5), Crit: n(9)}).Status)
+	assert.Equal(t, checkers.OK, evalClients(info, 0, clientsOpts{BlockedWarn: n(0)}).Status)
+	assert.Equal(t, checkers.UNKNOWN, evalClients(map[string]string{}, 0, clientsOpts{}).Status)
+}
+
+func TestTLS(t *testing.T) {
+	host, port, caPEM, closer := fakeRedisTLS(t, map[string]string{
+		"AUTH secret": "+OK\r\n",
+		"PING":        "+PONG\r\n",
+		"INFO server": bulk("# Server\r\nredis_version:6.2.6\r\n"),
+	})
+	defer closer()
+
+	f, err := ioutil.TempFile("", "check-redis-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Write(caPEM)
+	f.Close()
+
+	ckr := checkReachable([]string{"-H", host, "-p", port, "-a", "secret", "--tls", "--tls-ca", f.Name()})
+	assert.Equal(t, checkers.OK, ckr.Status, ckr.Message)
+	assert.Contains(t, ckr.Message, "version: 6.2.6")
+
+	ckr = checkReachable([]string{"-H", host, "-p", port, "--tls", "--tls-skip-verify"})
+	assert.Equal(t, checkers.OK, ckr.Status, ckr.Message)
+
+	// self-signed
+	ckr = checkReachable([]string{"-H", host, "-p", port, "--tls"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Contains(t, ckr.Message, "couldn't connect")
+
+	// plain text to the TLS server
+	ckr = checkReachable([]string{"-H", host, "-p", port, "-t", "1"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+}
+
+func TestCluster(t *testing.T) {
+	nodes := `07c37dfeb235213a872192d90877d0cd55635b91 127.0.0.1:30004@31004 slave e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 0 1426238317239 4 connected
+67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 127.0.0.1:30002@31002 master - 0 1426238316232 2 connected 5461-10922
+292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f 127.0.0.1:30003@31003 master - 0 1426238318243 3 connected 10923-16383
+6ec23923021cf3ffec47632106199cb7f496ce01 127.0.0.1:30005@31005 slave,fail? 67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 0 1426238316232 5 connected
+824fe116063bc5fcf9f4ffd895bc17aee7731ac3 127.0.0.1:30006@31006 slave 292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f 0 1426238317741 6 connected
+e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 127.0.0.1:30001@31001 myself,master - 0 0 1 connected 0-5460
+`
+	host, port, closer := fakeRedis(t, map[string]string{
+		"INFO cluster":  bulk("# Cluster\r\ncluster_enabled:1\r\n"),
+		"CLUSTER INFO":  bulk("cluster_state:ok\r\ncluster_slots_assigned:16384\r\ncluster_slots_ok:16384\r\ncluster_slots_pfail:0\r\ncluster_slots_fail:0\r\ncluster_known_nodes:6\r\n"),
+		"CLUSTER NODES": bulk(nodes),
+	})
+	defer closer()
+
+	ckr := checkCluster([]string{"-H", host, "-p", port})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "cluster_state: ok, cluster_slots_fail: 0, nodes: 6 (1 failing), slot coverage: 100.00%", ckr.Message)
+
+	ckr = checkCluster([]string{"-H", host, "-p", port, "--cluster-min-nodes", "6"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+}
+
+func TestEvalCluster(t *testing.T) {
+	info := map[string]string{"cluster_state": "fail", "cluster_slots_ok": "10923", "cluster_slots_fail": "5461"}
+	ckr := evalCluster(info, "", 0)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "cluster_state: fail, cluster_slots_fail: 5461, nodes: 0 (0 failing), slot coverage: 66.67%", ckr.Message)
+}
+
+func TestClusterDisabled(t *testing.T) {
+	host, port, closer := fakeRedis(t, map[string]string{
+		"INFO cluster": bulk("# Cluster\r\ncluster_enabled:0\r\n"),
+	})
+	defer closer()
+
+	ckr := checkCluster([]string{"-H", host, "-p", port})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "cluster_enabled: 0", ckr.Message)
+}
@@ -0,0 +1,84 @@
+package checkredis
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+type clientsOpts struct {
+	redisSetting
+	Crit        *int64 `long:"clients-critical" value-name:"N" description:"critical if connected_clients is over"`
+	Warn        *int64 `long:"clients-warning" value-name:"N" description:"warning if connected_clients is over"`
+	BlockedCrit *int64 `long:"blocked-clients-critical" value-name:"N" description:"critical if blocked_clients is over"`
+	BlockedWarn *int64 `long:"blocked-clients-warning" value-name:"N" description:"warning if blocked_clients is over"`
+}
+
+// getMaxClients returns maxclients from INFO clients (Redis 7+) or CONFIG
+// GET, or 0 if it is not available, e.g. CONFIG is disabled.
+func getMaxClients(c *client, info map[string]string) int64 {
+	if v, err := strconv.ParseInt(info["maxclients"], 10, 64); err == nil {
+		return v
+	}
+	reply, err := c.Cmd("CONFIG", "GET", "maxclients")
+	if err != nil {
+		return 0
+	}
+	if r, ok := reply.([]interface{}); ok && len(r) == 2 {
+		if s, ok := r[1].(string); ok {
+			v, _ := strconv.ParseInt(s, 10, 64)
+			return v
+		}
+	}
+	return 0
+}
+
+func evalClients(info map[string]string, maxClients int64, opts clientsOpts) *checkers.Checker {
+	connected, err := strconv.ParseInt(info["connected_clients"], 10, 64)
+	if err != nil {
+		return checkers.Unknown("couldn't get connected_clients")
+	}
+	blocked, err := strconv.ParseInt(info["blocked_clients"], 10, 64)
+	if err != nil {
+		return checkers.Unknown("couldn't get blocked_clients")
+	}
+
+	msg := fmt.Sprintf("connected_clients: %d", connected)
+	if maxClients > 0 {
+		msg += fmt.Sprintf(" (%.2f%% of maxclients %d)", float64(connected)/float64(maxClients)*100, maxClients)
+	}
+	msg += fmt.Sprintf(", blocked_clients: %d", blocked)
+
+	exceeded := func(v int64, threshold *int64) bool {
+		return threshold != nil && v > *threshold
+	}
+	checkSt := checkers.OK
+	if exceeded(connected, opts.Crit) || exceeded(blocked, opts.BlockedCrit) {
+		checkSt = checkers.CRITICAL
+	} else if exceeded(connected, opts.Warn) || exceeded(blocked, opts.BlockedWarn) {
+		checkSt = checkers.WARNING
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func checkClients(args []string) *checkers.Checker {
+	opts := clientsOpts{}
+	psr := flags.NewParser(&opts, flags.Default)
+	psr.Usage = "clients [OPTIONS]"
+	_, err := psr.ParseArgs(args)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	c, info, err := connectRedisGetInfo(opts.redisSetting, "clients")
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	defer c.Close()
+
+	return evalClients(*info, getMaxClients(c, *info), opts)
+}
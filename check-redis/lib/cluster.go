@@ -0,0 +1,94 @@
+package checkredis
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+// clusterSlots is the number of hash slots of Redis Cluster
+const clusterSlots = 16384
+
+type clusterOpts struct {
+	redisSetting
+	MinNodes int `long:"cluster-min-nodes" value-name:"N" default:"0" description:"critical if the number of reachable nodes is less than"`
+}
+
+// clusterNodes counts the nodes in the output of CLUSTER NODES and those
+// flagged as fail or fail? (PFAIL)
+func clusterNodes(str string) (total, failing int) {
+	for _, line := range strings.Split(str, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		total++
+		for _, flag := range strings.Split(fields[2], ",") {
+			if flag == "fail" || flag == "fail?" {
+				failing++
+				break
+			}
+		}
+	}
+	return total, failing
+}
+
+func evalCluster(clusterInfo map[string]string, nodes string, minNodes int) *checkers.Checker {
+	state := clusterInfo["cluster_state"]
+	slotsFail, err := strconv.ParseInt(clusterInfo["cluster_slots_fail"], 10, 64)
+	if err != nil {
+		return checkers.Unknown("couldn't get cluster_slots_fail")
+	}
+	slotsOK, err := strconv.ParseInt(clusterInfo["cluster_slots_ok"], 10, 64)
+	if err != nil {
+		return checkers.Unknown("couldn't get cluster_slots_ok")
+	}
+	total, failing := clusterNodes(nodes)
+	reachable := total - failing
+
+	msg := fmt.Sprintf("cluster_state: %s, cluster_slots_fail: %d, nodes: %d (%d failing), slot coverage: %.2f%%",
+		state, slotsFail, total, failing, float64(slotsOK)/clusterSlots*100)
+
+	checkSt := checkers.OK
+	if state != "ok" || slotsFail > 0 || reachable < minNodes {
+		checkSt = checkers.CRITICAL
+	} else if failing > 0 {
+		checkSt = checkers.WARNING
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func checkCluster(args []string) *checkers.Checker {
+	opts := clusterOpts{}
+	psr := flags.NewParser(&opts, flags.Default)
+	psr.Usage = "cluster [OPTIONS]"
+	_, err := psr.ParseArgs(args)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	c, info, err := connectRedisGetInfo(opts.redisSetting, "cluster")
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	defer c.Close()
+
+	if (*info)["cluster_enabled"] != "1" {
+		return checkers.Critical("cluster_enabled: 0")
+	}
+
+	str, err := c.Str("CLUSTER", "INFO")
+	if err != nil {
+		return checkers.Unknown("couldn't execute query")
+	}
+	nodes, err := c.Str("CLUSTER", "NODES")
+	if err != nil {
+		return checkers.Unknown("couldn't execute query")
+	}
+	return evalCluster(parseInfo(str), nodes, opts.MinNodes)
+}
@@ -0,0 +1,136 @@
+package checkredis
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+	"github.com/mackerelio/go-check-plugins/check-file-size/lib"
+)
+
+type memoryOpts struct {
+	redisSetting
+	MemCrit string `long:"memory-critical" value-name:"N[KMGT], N%" description:"critical if used_memory is over N bytes, or over N% of maxmemory (or the system memory if maxmemory is not set)"`
+	MemWarn string `long:"memory-warning" value-name:"N[KMGT], N%" description:"warning if used_memory is over N bytes, or over N% of maxmemory (or the system memory if maxmemory is not set)"`
+	RSSCrit string `long:"memory-rss-critical" value-name:"N[KMGT]" description:"critical if used_memory_rss is over N bytes"`
+	RSSWarn string `long:"memory-rss-warning" value-name:"N[KMGT]" description:"warning if used_memory_rss is over N bytes"`
+}
+
+// memThreshold is a threshold of memory usage either in bytes or in
+// percentage of the memory limit
+type memThreshold struct {
+	value   float64
+	percent bool
+}
+
+func parseMemThreshold(s string, allowPercent bool) (*memThreshold, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if allowPercent && strings.HasSuffix(s, "%") {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s is invalid", s)
+		}
+		return &memThreshold{value: v, percent: true}, nil
+	}
+	v, err := checkfilesize.SizeValue(s)
+	if err != nil {
+		return nil, err
+	}
+	return &memThreshold{value: v}, nil
+}
+
+func (t *memThreshold) exceeded(used, limit float64) bool {
+	if t == nil {
+		return false
+	}
+	if t.percent {
+		return limit > 0 && used/limit*100 > t.value
+	}
+	return used > t.value
+}
+
+func evalMemory(info map[string]string, memWarn, memCrit, rssWarn, rssCrit *memThreshold) *checkers.Checker {
+	var used, rss, limit float64
+	for _, v := range []struct {
+		key  string
+		dest *float64
+	}{
+		{"used_memory", &used},
+		{"used_memory_rss", &rss},
+	} {
+		value, ok := info[v.key]
+		if !ok {
+			return checkers.Unknown(fmt.Sprintf("couldn't get %s", v.key))
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return checkers.Unknown(err.Error())
+		}
+		*v.dest = n
+	}
+
+	// maxmemory 0 means unlimited, so use the system memory instead
+	limitKey := "maxmemory"
+	limit, _ = strconv.ParseFloat(info[limitKey], 64)
+	if limit == 0 {
+		limitKey = "total_system_memory"
+		limit, _ = strconv.ParseFloat(info[limitKey], 64)
+	}
+	if limit == 0 && ((memWarn != nil && memWarn.percent) || (memCrit != nil && memCrit.percent)) {
+		return checkers.Unknown("couldn't get maxmemory nor total_system_memory for the percentage")
+	}
+
+	msg := fmt.Sprintf("used_memory: %s", info["used_memory_human"])
+	if limit > 0 {
+		msg += fmt.Sprintf(" / %s: %s (%.2f%%)", limitKey, info[limitKey+"_human"], used/limit*100)
+	}
+	msg += fmt.Sprintf(", used_memory_rss: %s", info["used_memory_rss_human"])
+
+	checkSt := checkers.OK
+	if memCrit.exceeded(used, limit) || rssCrit.exceeded(rss, 0) {
+		checkSt = checkers.CRITICAL
+	} else if memWarn.exceeded(used, limit) || rssWarn.exceeded(rss, 0) {
+		checkSt = checkers.WARNING
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func checkMemory(args []string) *checkers.Checker {
+	opts := memoryOpts{}
+	psr := flags.NewParser(&opts, flags.Default)
+	psr.Usage = "memory [OPTIONS]"
+	_, err := psr.ParseArgs(args)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var thresholds [4]*memThreshold
+	for i, v := range []struct {
+		value        string
+		allowPercent bool
+	}{
+		{opts.MemWarn, true},
+		{opts.MemCrit, true},
+		{opts.RSSWarn, false},
+		{opts.RSSCrit, false},
+	} {
+		thresholds[i], err = parseMemThreshold(v.value, v.allowPercent)
+		if err != nil {
+			return checkers.Unknown(err.Error())
+		}
+	}
+
+	c, info, err := connectRedisGetInfo(opts.redisSetting, "memory")
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	defer c.Close()
+
+	return evalMemory(*info, thresholds[0], thresholds[1], thresholds[2], thresholds[3])
+}
@@ -1,29 +1,54 @@
 package checksmtp
 
 import (
+	"bufio"
+	"bytes"
 	"crypto/tls"
 	"fmt"
 	"net"
 	"net/smtp"
+	"net/textproto"
 	"os"
 	"time"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/mackerelio/checkers"
+	"github.com/mackerelio/go-check-plugins/check-ssl-cert/lib"
 )
 
 type options struct {
-	Host     string  `short:"H" long:"host" default:"localhost" description:"Hostname"`
-	Port     string  `short:"p" long:"port" default:"25" description:"Port"`
-	FQDN     string  `short:"F" long:"fqdn" description:"FQDN used for HELO"`
-	SMTPS    bool    `short:"s" long:"smtps" description:"Use SMTP over TLS"`
-	StartTLS bool    `short:"S" long:"starttls" description:"Use STARTTLS"`
-	Auth     string  `short:"A" long:"authmech" description:"SMTP AUTH Authentication Mechanisms (only PLAIN supported)"`
-	User     string  `short:"U" long:"authuser" description:"SMTP AUTH username"`
-	Password string  `short:"P" long:"authpassword" description:"SMTP AUTH password"`
-	Warning  float64 `short:"w" long:"warning" description:"Warning threshold (sec)"`
-	Critical float64 `short:"c" long:"critical" description:"Critical threshold (sec)"`
-	Timeout  int     `short:"t" long:"timeout" default:"10" description:"Timeout (sec)"`
+	Host        string  `short:"H" long:"host" default:"localhost" description:"Hostname"`
+	Port        string  `short:"p" long:"port" default:"25" description:"Port"`
+	FQDN        string  `short:"F" long:"fqdn" description:"FQDN used for HELO"`
+	SMTPS       bool    `short:"s" long:"smtps" description:"Use SMTP over TLS"`
+	StartTLS    bool    `short:"S" long:"starttls" description:"Use STARTTLS"`
+	Auth        string  `short:"A" long:"authmech" description:"SMTP AUTH Authentication Mechanisms (only PLAIN supported)"`
+	User        string  `short:"U" long:"authuser" description:"SMTP AUTH username"`
+	Password    string  `short:"P" long:"authpassword" description:"SMTP AUTH password"`
+	Warning     float64 `short:"w" long:"warning" description:"Warning threshold (sec)"`
+	Critical    float64 `short:"c" long:"critical" description:"Critical threshold (sec)"`
+	Timeout     int     `short:"t" long:"timeout" default:"10" description:"Timeout (sec)"`
+	TLSWarnDays *int    `long:"tls-warn-days" value-name:"days" description:"Days before the certificate expires to result in warning status, used with --smtps or --starttls"`
+	TLSCritDays *int    `long:"tls-crit-days" value-name:"days" description:"Days before the certificate expires to result in critical status, used with --smtps or --starttls"`
+}
+
+// recordConn records the data read from the connection to get the banner,
+// which smtp.Client discards
+type recordConn struct {
+	net.Conn
+	buf bytes.Buffer
+}
+
+func (c *recordConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.buf.Write(b[:n])
+	return n, err
+}
+
+func (c *recordConn) banner() string {
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(c.buf.Bytes())))
+	_, msg, _ := r.ReadResponse(220)
+	return msg
 }
 
 // Do the plugin
@@ -77,11 +102,13 @@ func run(args []string) *checkers.Checker {
 
 	stTime := time.Now()
 
-	c, err := smtp.NewClient(conn, opts.Host)
+	rc := &recordConn{Conn: conn}
+	c, err := smtp.NewClient(rc, opts.Host)
 	if err != nil {
 		return checkers.Critical(err.Error())
 	}
 	defer c.Quit()
+	banner := rc.banner()
 
 	if err := c.Hello(fqdn); err != nil {
 		return checkers.Critical(err.Error())
@@ -93,6 +120,27 @@ func run(args []string) *checkers.Checker {
 		}
 	}
 
+	var certCkr *checkers.Checker
+	if opts.TLSWarnDays != nil || opts.TLSCritDays != nil {
+		var state tls.ConnectionState
+		if tlsConn, ok := conn.(*tls.Conn); ok {
+			state = tlsConn.ConnectionState()
+		} else if s, ok := c.TLSConnectionState(); ok {
+			state = s
+		}
+		if len(state.PeerCertificates) == 0 {
+			return checkers.Unknown("--tls-warn-days and --tls-crit-days require --smtps or --starttls")
+		}
+		warn, crit := 0, 0
+		if opts.TLSWarnDays != nil {
+			warn = *opts.TLSWarnDays
+		}
+		if opts.TLSCritDays != nil {
+			crit = *opts.TLSCritDays
+		}
+		certCkr = checksslcert.CheckExpiry(net.JoinHostPort(opts.Host, opts.Port), state.PeerCertificates[0], warn, crit)
+	}
+
 	if opts.Auth == "PLAIN" {
 		auth := smtp.PlainAuth("", opts.User, opts.Password, opts.Host)
 		if err := c.Auth(auth); err != nil {
@@ -108,13 +156,19 @@ func run(args []string) *checkers.Checker {
 
 	elapsed := time.Since(stTime)
 
-	msg := fmt.Sprintf("%.3f seconds response time", elapsed.Seconds())
+	msg := fmt.Sprintf("%.3f seconds response time (banner: %s)", elapsed.Seconds(), banner)
 
+	checkSt := checkers.OK
 	if opts.Critical != 0 && elapsed.Seconds() > opts.Critical {
-		return checkers.Critical(msg)
+		checkSt = checkers.CRITICAL
 	} else if opts.Warning != 0 && elapsed.Seconds() > opts.Warning {
-		return checkers.Warning(msg)
+		checkSt = checkers.WARNING
 	}
-
-	return checkers.Ok(msg)
+	if certCkr != nil {
+		if certCkr.Status > checkSt {
+			checkSt = certCkr.Status
+		}
+		msg += "\n" + certCkr.Message
+	}
+	return checkers.NewChecker(checkSt, msg)
 }
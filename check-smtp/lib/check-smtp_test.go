@@ -118,3 +118,25 @@ func TestSMTP_NoThresholdOptions(t *testing.T) {
 		t.Errorf("expected UNKNOWN to eq %s", ckr.Status.String())
 	}
 }
+
+func TestSMTP_Banner(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer ln.Close()
+	s := mockSMTPServer{
+		listener:  ln,
+		responses: responseDefault,
+	}
+	go s.runServe()
+
+	port := strings.Split(ln.Addr().String(), ":")[1]
+	ckr := run([]string{"--host", "127.0.0.1", "--port", port, "--warning", "10"})
+	if ckr.Status.String() != "OK" {
+		t.Errorf("%s: %s", ckr.Status.String(), ckr.Message)
+	}
+	if !strings.HasSuffix(ckr.Message, " seconds response time (banner: mail.example.com ESMTP unknown)") {
+		t.Errorf("the banner is not reported: %s", ckr.Message)
+	}
+}
@@ -0,0 +1,209 @@
+package checksnmp
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+type snmpOpts struct {
+	Host          string   `short:"H" long:"host" required:"true" description:"Hostname"`
+	Port          uint16   `short:"p" long:"port" default:"161" description:"Port"`
+	Community     string   `long:"community" default:"public" description:"Community (SNMPv1 and v2c)"`
+	Version       string   `long:"version" default:"2c" choice:"1" choice:"2c" choice:"3" description:"SNMP version"`
+	OID           string   `long:"oid" required:"true" description:"OID to get, e.g. .1.3.6.1.2.1.1.3.0"`
+	Warning       *float64 `short:"w" long:"warning" description:"warning if the numeric value is over"`
+	Critical      *float64 `short:"c" long:"critical" description:"critical if the numeric value is over"`
+	StringMatch   string   `long:"string-match" value-name:"REGEXP" description:"critical unless the value matches"`
+	Timeout       int      `long:"timeout" value-name:"SECONDS" default:"10" description:"Timeout in seconds"`
+	SecurityName  string   `long:"security-name" description:"User name (SNMPv3)"`
+	SecurityLevel string   `long:"security-level" choice:"noAuthNoPriv" choice:"authNoPriv" choice:"authPriv" description:"Security level (SNMPv3), derived from the passwords if omitted"`
+	AuthProtocol  string   `long:"auth-protocol" default:"SHA" choice:"MD5" choice:"SHA" choice:"SHA256" choice:"SHA512" description:"Authentication protocol (SNMPv3)"`
+	AuthPassword  string   `long:"auth-password" description:"Authentication password (SNMPv3)"`
+	PrivProtocol  string   `long:"priv-protocol" default:"AES" choice:"DES" choice:"AES" choice:"AES192" choice:"AES256" description:"Privacy protocol (SNMPv3)"`
+	PrivPassword  string   `long:"priv-password" description:"Privacy password (SNMPv3)"`
+}
+
+// Do the plugin
+func Do() {
+	ckr := run(os.Args[1:])
+	ckr.Name = "SNMP"
+	ckr.Exit()
+}
+
+var authProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+	"MD5":    gosnmp.MD5,
+	"SHA":    gosnmp.SHA,
+	"SHA256": gosnmp.SHA256,
+	"SHA512": gosnmp.SHA512,
+}
+
+var privProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+	"DES":    gosnmp.DES,
+	"AES":    gosnmp.AES,
+	"AES192": gosnmp.AES192,
+	"AES256": gosnmp.AES256,
+}
+
+// authErrors are the errors reported by the agent when the USM rejects the request
+var authErrors = map[error]bool{
+	gosnmp.ErrUnknownUsername:      true,
+	gosnmp.ErrWrongDigest:          true,
+	gosnmp.ErrDecryption:           true,
+	gosnmp.ErrUnknownSecurityLevel: true,
+}
+
+func securityLevel(opts *snmpOpts) string {
+	if opts.SecurityLevel != "" {
+		return opts.SecurityLevel
+	}
+	switch {
+	case opts.AuthPassword != "" && opts.PrivPassword != "":
+		return "authPriv"
+	case opts.AuthPassword != "":
+		return "authNoPriv"
+	}
+	return "noAuthNoPriv"
+}
+
+func newSNMP(opts *snmpOpts) (*gosnmp.GoSNMP, error) {
+	g := &gosnmp.GoSNMP{
+		Target:    opts.Host,
+		Port:      opts.Port,
+		Community: opts.Community,
+		Timeout:   time.Duration(opts.Timeout) * time.Second,
+		Retries:   1,
+		MaxOids:   gosnmp.MaxOids,
+	}
+	switch opts.Version {
+	case "1":
+		g.Version = gosnmp.Version1
+	case "2c":
+		g.Version = gosnmp.Version2c
+	case "3":
+		if opts.SecurityName == "" {
+			return nil, fmt.Errorf("--security-name is required for SNMPv3")
+		}
+		g.Version = gosnmp.Version3
+		g.SecurityModel = gosnmp.UserSecurityModel
+		params := &gosnmp.UsmSecurityParameters{
+			UserName:               opts.SecurityName,
+			AuthenticationProtocol: gosnmp.NoAuth,
+			PrivacyProtocol:        gosnmp.NoPriv,
+		}
+		switch securityLevel(opts) {
+		case "noAuthNoPriv":
+			g.MsgFlags = gosnmp.NoAuthNoPriv
+		case "authNoPriv":
+			if opts.AuthPassword == "" {
+				return nil, fmt.Errorf("--auth-password is required for authNoPriv")
+			}
+			g.MsgFlags = gosnmp.AuthNoPriv
+		case "authPriv":
+			if opts.AuthPassword == "" || opts.PrivPassword == "" {
+				return nil, fmt.Errorf("both --auth-password and --priv-password are required for authPriv")
+			}
+			g.MsgFlags = gosnmp.AuthPriv
+			params.PrivacyProtocol = privProtocols[opts.PrivProtocol]
+			params.PrivacyPassphrase = opts.PrivPassword
+		}
+		if g.MsgFlags != gosnmp.NoAuthNoPriv {
+			params.AuthenticationProtocol = authProtocols[opts.AuthProtocol]
+			params.AuthenticationPassphrase = opts.AuthPassword
+		}
+		g.SecurityParameters = params
+	}
+	return g, nil
+}
+
+// numericValue returns the value as a number if the type is numeric
+func numericValue(pdu gosnmp.SnmpPDU) (float64, bool) {
+	switch pdu.Type {
+	case gosnmp.Integer, gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Counter64, gosnmp.Uinteger32:
+		f, _ := new(big.Float).SetInt(gosnmp.ToBigInt(pdu.Value)).Float64()
+		return f, true
+	case gosnmp.OpaqueFloat:
+		return float64(pdu.Value.(float32)), true
+	case gosnmp.OpaqueDouble:
+		return pdu.Value.(float64), true
+	}
+	return 0, false
+}
+
+func stringValue(pdu gosnmp.SnmpPDU) string {
+	if b, ok := pdu.Value.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(pdu.Value)
+}
+
+func evalPDU(opts *snmpOpts, pdu gosnmp.SnmpPDU, stringReg *regexp.Regexp) *checkers.Checker {
+	switch pdu.Type {
+	case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+		return checkers.Critical(fmt.Sprintf("%s: %s", pdu.Name, pdu.Type))
+	}
+
+	value := stringValue(pdu)
+	msg := fmt.Sprintf("%s = %s: %s", pdu.Name, pdu.Type, value)
+	checkSt := checkers.OK
+	if opts.Warning != nil || opts.Critical != nil {
+		n, ok := numericValue(pdu)
+		if !ok {
+			return checkers.Unknown(fmt.Sprintf("the value is not numeric: %s", msg))
+		}
+		if opts.Critical != nil && n > *opts.Critical {
+			checkSt = checkers.CRITICAL
+		} else if opts.Warning != nil && n > *opts.Warning {
+			checkSt = checkers.WARNING
+		}
+	}
+	if stringReg != nil && !stringReg.MatchString(value) {
+		checkSt = checkers.CRITICAL
+		msg += fmt.Sprintf(", not matched to %s", stringReg)
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func run(args []string) *checkers.Checker {
+	opts := snmpOpts{}
+	_, err := flags.ParseArgs(&opts, args)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	var stringReg *regexp.Regexp
+	if opts.StringMatch != "" {
+		if stringReg, err = regexp.Compile(opts.StringMatch); err != nil {
+			return checkers.Unknown(err.Error())
+		}
+	}
+	g, err := newSNMP(&opts)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	if err := g.Connect(); err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't connect to %s:%d: %s", opts.Host, opts.Port, err))
+	}
+	defer g.Conn.Close()
+
+	result, err := g.Get([]string{opts.OID})
+	if authErrors[err] {
+		return checkers.Critical(fmt.Sprintf("authentication failed for %s@%s: %s", opts.SecurityName, opts.Host, err))
+	}
+	if err != nil {
+		return checkers.Critical(fmt.Sprintf("couldn't get %s: %s", opts.OID, err))
+	}
+	if result.Error != gosnmp.NoError {
+		return checkers.Critical(fmt.Sprintf("couldn't get %s: %s", opts.OID, result.Error))
+	}
+	if len(result.Variables) == 0 {
+		return checkers.Critical(fmt.Sprintf("couldn't get %s: no variables", opts.OID))
+	}
+	return evalPDU(&opts, result.Variables[0], stringReg)
+}
@@ -0,0 +1,95 @@
+package checksnmp
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSNMP(t *testing.T) {
+	g, err := newSNMP(&snmpOpts{Host: "192.0.2.1", Port: 161, Community: "public", Version: "2c", Timeout: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, gosnmp.Version2c, g.Version)
+	assert.Equal(t, "public", g.Community)
+
+	g, err = newSNMP(&snmpOpts{Host: "192.0.2.1", Port: 161, Version: "3", SecurityName: "monitor", AuthProtocol: "SHA", AuthPassword: "authpass", PrivProtocol: "AES", PrivPassword: "privpass"})
+	assert.Nil(t, err)
+	assert.Equal(t, gosnmp.Version3, g.Version)
+	assert.Equal(t, gosnmp.AuthPriv, g.MsgFlags)
+	params := g.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	assert.Equal(t, "monitor", params.UserName)
+	assert.Equal(t, gosnmp.SHA, params.AuthenticationProtocol)
+	assert.Equal(t, gosnmp.AES, params.PrivacyProtocol)
+
+	g, err = newSNMP(&snmpOpts{Host: "192.0.2.1", Port: 161, Version: "3", SecurityName: "monitor", AuthProtocol: "MD5", AuthPassword: "authpass", PrivProtocol: "AES"})
+	assert.Nil(t, err)
+	assert.Equal(t, gosnmp.AuthNoPriv, g.MsgFlags)
+
+	_, err = newSNMP(&snmpOpts{Host: "192.0.2.1", Port: 161, Version: "3"})
+	assert.NotNil(t, err)
+}
+
+func TestNewSNMPSecurityLevel(t *testing.T) {
+	g, err := newSNMP(&snmpOpts{Version: "3", SecurityName: "monitor", SecurityLevel: "authPriv", AuthProtocol: "SHA512", AuthPassword: "authpass", PrivProtocol: "AES256", PrivPassword: "privpass"})
+	assert.Nil(t, err)
+	assert.Equal(t, gosnmp.AuthPriv, g.MsgFlags)
+	params := g.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	assert.Equal(t, gosnmp.SHA512, params.AuthenticationProtocol)
+	assert.Equal(t, "authpass", params.AuthenticationPassphrase)
+	assert.Equal(t, gosnmp.AES256, params.PrivacyProtocol)
+	assert.Equal(t, "privpass", params.PrivacyPassphrase)
+
+	g, err = newSNMP(&snmpOpts{Version: "3", SecurityName: "monitor", SecurityLevel: "authNoPriv", AuthProtocol: "SHA256", AuthPassword: "authpass", PrivProtocol: "AES", PrivPassword: "privpass"})
+	assert.Nil(t, err)
+	assert.Equal(t, gosnmp.AuthNoPriv, g.MsgFlags)
+	params = g.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	assert.Equal(t, gosnmp.SHA256, params.AuthenticationProtocol)
+	assert.Equal(t, gosnmp.NoPriv, params.PrivacyProtocol)
+
+	g, err = newSNMP(&snmpOpts{Version: "3", SecurityName: "monitor", SecurityLevel: "noAuthNoPriv", AuthProtocol: "SHA", AuthPassword: "authpass"})
+	assert.Nil(t, err)
+	assert.Equal(t, gosnmp.NoAuthNoPriv, g.MsgFlags)
+	params = g.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	assert.Equal(t, gosnmp.NoAuth, params.AuthenticationProtocol)
+
+	_, err = newSNMP(&snmpOpts{Version: "3", SecurityName: "monitor", SecurityLevel: "authPriv", AuthProtocol: "SHA", AuthPassword: "authpass", PrivProtocol: "AES"})
+	assert.EqualError(t, err, "both --auth-password and --priv-password are required for authPriv")
+
+	_, err = newSNMP(&snmpOpts{Version: "3", SecurityName: "monitor", SecurityLevel: "authPriv", AuthProtocol: "SHA", PrivProtocol: "AES", PrivPassword: "privpass"})
+	assert.EqualError(t, err, "both --auth-password and --priv-password are required for authPriv")
+
+	_, err = newSNMP(&snmpOpts{Version: "3", SecurityName: "monitor", SecurityLevel: "authNoPriv", AuthProtocol: "SHA"})
+	assert.EqualError(t, err, "--auth-password is required for authNoPriv")
+}
+
+func TestEvalPDU(t *testing.T) {
+	warn, crit := 80.0, 90.0
+	pdu := gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.2021.11.9.0", Type: gosnmp.Integer, Value: 85}
+	ckr := evalPDU(&snmpOpts{Warning: &warn, Critical: &crit}, pdu, nil)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, ".1.3.6.1.4.1.2021.11.9.0 = Integer: 85", ckr.Message)
+
+	pdu = gosnmp.SnmpPDU{Name: ".1.3.6.1.2.1.31.1.1.1.6.1", Type: gosnmp.Counter64, Value: uint64(1 << 40)}
+	ckr = evalPDU(&snmpOpts{Critical: &crit}, pdu, nil)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	pdu = gosnmp.SnmpPDU{Name: ".1.3.6.1.2.1.1.1.0", Type: gosnmp.OctetString, Value: []byte("Linux router 4.19.0")}
+	ckr = evalPDU(&snmpOpts{}, pdu, regexp.MustCompile(`^Linux`))
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, ".1.3.6.1.2.1.1.1.0 = OctetString: Linux router 4.19.0", ckr.Message)
+
+	ckr = evalPDU(&snmpOpts{}, pdu, regexp.MustCompile(`^FreeBSD`))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, ".1.3.6.1.2.1.1.1.0 = OctetString: Linux router 4.19.0, not matched to ^FreeBSD", ckr.Message)
+
+	ckr = evalPDU(&snmpOpts{Warning: &warn}, pdu, nil)
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status)
+
+	pdu = gosnmp.SnmpPDU{Name: ".1.3.6.1.2.1.1.99.0", Type: gosnmp.NoSuchObject}
+	ckr = evalPDU(&snmpOpts{}, pdu, nil)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, ".1.3.6.1.2.1.1.99.0: NoSuchObject", ckr.Message)
+}
@@ -1,6 +1,7 @@
 package checkssh
 
 import (
+	"bytes"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
@@ -9,6 +10,7 @@ import (
 	"net"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jessevdk/go-flags"
@@ -26,6 +28,8 @@ type sshOpts struct {
 	Password     string  `short:"p" long:"password" description:"Login password" env:"LOGIN_PASSWORD"`
 	IdentityFile string  `short:"i" long:"identity" description:"Identity file (ssh private key)"`
 	PassPhrase   string  `long:"passphrase" description:"Identity passphrase" env:"CHECK_SSH_IDENTITY_PASSPHRASE"`
+	HostKey      string  `long:"host-key" value-name:"FINGERPRINT" description:"Critical if the SHA256 fingerprint of the host key differs"`
+	NoAuth       bool    `long:"no-auth" description:"Check only the key exchange without the authentication"`
 }
 
 // Do the plugin
@@ -85,8 +89,26 @@ func readPrivateKey(file, passphrase string) ([]byte, error) {
 	return privateKey, nil
 }
 
-func (opts *sshOpts) makeClientConfig() (*ssh.ClientConfig, error) {
+// verifyHostKey compares the fingerprint of the host key with --host-key
+func (opts *sshOpts) verifyHostKey(key ssh.PublicKey) error {
+	if opts.HostKey == "" {
+		return nil
+	}
+	expected := opts.HostKey
+	if !strings.HasPrefix(expected, "SHA256:") {
+		expected = "SHA256:" + expected
+	}
+	if actual := ssh.FingerprintSHA256(key); actual != expected {
+		return fmt.Errorf("host key fingerprint mismatch: expected %s but got %s", expected, actual)
+	}
+	return nil
+}
+
+func (opts *sshOpts) makeClientConfig(hostKeyCallback ssh.HostKeyCallback) (*ssh.ClientConfig, error) {
 	authenticities := make([]ssh.AuthMethod, 0, 1)
+	if opts.NoAuth {
+		return &ssh.ClientConfig{User: opts.User, Auth: authenticities, HostKeyCallback: hostKeyCallback}, nil
+	}
 	if opts.Password != "" {
 		authenticities = append(authenticities, ssh.Password(opts.Password))
 	}
@@ -104,22 +126,62 @@ func (opts *sshOpts) makeClientConfig() (*ssh.ClientConfig, error) {
 		authenticities = append(authenticities, ssh.PublicKeys(signer))
 	}
 
-	config := &ssh.ClientConfig{User: opts.User, Auth: authenticities, HostKeyCallback: ssh.InsecureIgnoreHostKey()}
+	config := &ssh.ClientConfig{User: opts.User, Auth: authenticities, HostKeyCallback: hostKeyCallback}
 	return config, nil
 }
 
-func (opts *sshOpts) dial(config *ssh.ClientConfig) (*ssh.Client, error) {
+// versionConn records the version banner read from the server, which is the
+// first line starting with "SSH-". The server may send other lines before it
+// (RFC 4253 section 4.2).
+type versionConn struct {
+	net.Conn
+	line []byte
+	done bool
+}
+
+func (c *versionConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	for i := 0; i < n && !c.done; i++ {
+		if p[i] == '\n' {
+			if bytes.HasPrefix(c.line, []byte("SSH-")) {
+				c.done = true
+			} else {
+				c.line = c.line[:0]
+			}
+			continue
+		}
+		if len(c.line) < 255 {
+			c.line = append(c.line, p[i])
+		}
+	}
+	return n, err
+}
+
+func (c *versionConn) version() string {
+	if !bytes.HasPrefix(c.line, []byte("SSH-")) {
+		return ""
+	}
+	return strings.TrimSpace(string(c.line))
+}
+
+func (opts *sshOpts) dial(config *ssh.ClientConfig) (*ssh.Client, string, error) {
 	addr := opts.Hostname + ":" + strconv.Itoa(opts.Port)
-	timeout := opts.Timeout * float64(time.Second)
-	conn, err := net.DialTimeout("tcp", addr, time.Duration(timeout))
+	timeout := time.Duration(opts.Timeout * float64(time.Second))
+	conn, err := net.DialTimeout("tcp", addr, timeout)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	vconn := &versionConn{Conn: conn}
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	c, chans, reqs, err := ssh.NewClientConn(vconn, addr, config)
 	if err != nil {
-		return nil, err
+		conn.Close()
+		return nil, vconn.version(), err
 	}
-	return ssh.NewClient(c, chans, reqs), nil
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), vconn.version(), nil
 }
 
 func (opts *sshOpts) run() *checkers.Checker {
@@ -127,13 +189,31 @@ func (opts *sshOpts) run() *checkers.Checker {
 	os.Setenv("LANG", "C")
 	os.Setenv("LC_ALL", "C")
 
-	config, err := opts.makeClientConfig()
+	var keyExchanged bool
+	var hostKeyErr error
+	config, err := opts.makeClientConfig(func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		hostKeyErr = opts.verifyHostKey(key)
+		keyExchanged = hostKeyErr == nil
+		return hostKeyErr
+	})
 	if err != nil {
 		return checkers.Unknown(err.Error())
 	}
 
 	start := time.Now()
-	client, err := opts.dial(config)
+	client, version, err := opts.dial(config)
+	if hostKeyErr != nil {
+		// always critical even if --no-auth
+		return checkers.Critical(hostKeyErr.Error())
+	}
+	if opts.NoAuth && keyExchanged {
+		// the authentication with no methods is expected to fail
+		elapsed := time.Now().Sub(start)
+		if client != nil {
+			client.Close()
+		}
+		return opts.checkTimeout(elapsed, version)
+	}
 	if err != nil {
 		if addrerr, ok := err.(*net.AddrError); ok {
 			if addrerr.Timeout() {
@@ -145,6 +225,7 @@ func (opts *sshOpts) run() *checkers.Checker {
 		}
 		return checkers.Critical(err.Error())
 	}
+	defer client.Close()
 	session, err := client.NewSession()
 	if err != nil {
 		return checkers.Critical(err.Error())
@@ -154,11 +235,11 @@ func (opts *sshOpts) run() *checkers.Checker {
 		return checkers.Unknown(err.Error())
 	}
 	elapsed := time.Now().Sub(start)
-	return opts.checkTimeout(elapsed)
+	return opts.checkTimeout(elapsed, version)
 }
 
 func (opts *sshOpts) checkTimeoutError(elapsed time.Duration, err error) *checkers.Checker {
-	checker := opts.checkTimeout(elapsed)
+	checker := opts.checkTimeout(elapsed, "")
 	if checker.Status == checkers.OK {
 		checker.Status = checkers.WARNING
 	}
@@ -166,7 +247,7 @@ func (opts *sshOpts) checkTimeoutError(elapsed time.Duration, err error) *checke
 	return checker
 }
 
-func (opts *sshOpts) checkTimeout(elapsed time.Duration) *checkers.Checker {
+func (opts *sshOpts) checkTimeout(elapsed time.Duration, version string) *checkers.Checker {
 	chkSt := checkers.OK
 	if opts.Warning > 0 && elapsed > time.Duration(opts.Warning)*time.Second {
 		chkSt = checkers.WARNING
@@ -181,5 +262,8 @@ func (opts *sshOpts) checkTimeout(elapsed time.Duration) *checkers.Checker {
 	if opts.Port > 0 {
 		msg += fmt.Sprintf(" port %d", opts.Port)
 	}
+	if version != "" {
+		msg += fmt.Sprintf(" (%s)", version)
+	}
 	return checkers.NewChecker(chkSt, msg)
 }
@@ -0,0 +1,116 @@
+package checkssh
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+// startServer starts the SSH server which rejects any authentication
+func startServer(t *testing.T) (net.Listener, ssh.Signer) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		ServerVersion: "SSH-2.0-TestServer_1.0",
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			return nil, errors.New("denied")
+		},
+	}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				ssh.NewServerConn(conn, config)
+			}()
+		}
+	}()
+	return ln, signer
+}
+
+func TestRunNoAuth(t *testing.T) {
+	ln, signer := startServer(t)
+	defer ln.Close()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	args := []string{"-H", host, "-P", port, "--no-auth"}
+
+	opts, err := parseArgs(args)
+	assert.Nil(t, err)
+	ckr := opts.run()
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Regexp(t, `^[\d.]+ seconds response time on 127.0.0.1 port `+port+` \(SSH-2.0-TestServer_1.0\)$`, ckr.Message)
+
+	opts, _ = parseArgs(append(args, "--host-key", ssh.FingerprintSHA256(signer.PublicKey())))
+	ckr = opts.run()
+	assert.Equal(t, checkers.OK, ckr.Status)
+
+	fingerprint := ssh.FingerprintSHA256(signer.PublicKey())[len("SHA256:"):]
+	opts, _ = parseArgs(append(args, "--host-key", fingerprint))
+	ckr = opts.run()
+	assert.Equal(t, checkers.OK, ckr.Status, "SHA256: prefix can be omitted")
+
+	opts, _ = parseArgs(append(args, "--host-key", "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8"))
+	ckr = opts.run()
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Regexp(t, `^host key fingerprint mismatch: expected SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8 but got SHA256:`, ckr.Message)
+
+	// the authentication fails without --no-auth
+	opts, _ = parseArgs([]string{"-H", host, "-P", port, "-u", "nobody"})
+	ckr = opts.run()
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+}
+
+func TestCheckTimeout(t *testing.T) {
+	opts := &sshOpts{Hostname: "localhost", Port: 22, Warning: 1, Critical: 3}
+	ckr := opts.checkTimeout(0, "SSH-2.0-OpenSSH_7.4")
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "0.000 seconds response time on localhost port 22 (SSH-2.0-OpenSSH_7.4)", ckr.Message)
+
+	ckr = opts.checkTimeout(2*time.Second, "")
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "2.000 seconds response time on localhost port 22", ckr.Message)
+}
+
+func TestVersionConn(t *testing.T) {
+	server, client := net.Pipe()
+	go func() {
+		server.Write([]byte("Welcome to the server\r\nSSH-2.0-OpenSSH_7.4\r\n"))
+		server.Close()
+	}()
+	vconn := &versionConn{Conn: client}
+	ioutil.ReadAll(vconn)
+	assert.Equal(t, "SSH-2.0-OpenSSH_7.4", vconn.version(), "the lines before the version should be skipped")
+
+	server, client = net.Pipe()
+	go func() {
+		server.Write([]byte("Welcome to the server\r\n"))
+		server.Close()
+	}()
+	vconn = &versionConn{Conn: client}
+	ioutil.ReadAll(vconn)
+	assert.Equal(t, "", vconn.version())
+}
@@ -3,8 +3,13 @@ package checksslcert
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jessevdk/go-flags"
@@ -12,10 +17,19 @@ import (
 )
 
 type certOpts struct {
-	Host     string `short:"H" long:"host" required:"true" description:"Host name"`
-	Port     int    `short:"p" long:"port" default:"443" description:"Port number"`
-	Warning  int    `short:"w" long:"warning" value-name:"days" default:"30" description:"The warning threshold in days before expiry"`
-	Critical int    `short:"c" long:"critical" value-name:"days" default:"14" description:"The critical threshold in days before expiry"`
+	Host                 string   `short:"H" long:"host" description:"Host name"`
+	Port                 int      `short:"p" long:"port" default:"443" description:"Port number"`
+	Warning              int      `short:"w" long:"warning" value-name:"days" default:"30" description:"The warning threshold in days before expiry"`
+	Critical             int      `short:"c" long:"critical" value-name:"days" default:"14" description:"The critical threshold in days before expiry"`
+	SNI                  string   `long:"sni" value-name:"NAME" description:"Server name for SNI and the verification (default: the host name)"`
+	Timeout              int      `long:"timeout" value-name:"seconds" default:"10" description:"Timeout in seconds"`
+	CACert               string   `long:"ca-cert" value-name:"FILE" description:"PEM file of the CA certificates to verify the certificate instead of the system ones"`
+	File                 string   `long:"file" value-name:"FILE" description:"Check the certificates in the local PEM file instead of the host"`
+	CriticalWhenNotFound bool     `long:"critical-when-not-found" description:"Critical if the file of --file doesn't exist, instead of unknown"`
+	MinTLSVersion        string   `long:"min-tls-version" choice:"TLS10" choice:"TLS11" choice:"TLS12" choice:"TLS13" description:"Critical if the negotiated TLS version is lower"`
+	ForbiddenCiphers     []string `long:"forbidden-cipher" value-name:"CIPHER" description:"Critical if the negotiated cipher suite is CIPHER, e.g. TLS_RSA_WITH_RC4_128_SHA (repeatable)"`
+	CheckOCSPStapling    bool     `long:"check-ocsp-stapling" description:"Check the stapled OCSP response, which is warning if not stapled"`
+	RequireOCSPStapling  bool     `long:"require-ocsp-stapling" description:"Critical if the OCSP response is not stapled (implies --check-ocsp-stapling)"`
 }
 
 func parseArgs(args []string) (*certOpts, error) {
@@ -32,18 +46,169 @@ func Do() {
 }
 
 func run(args []string) *checkers.Checker {
-	opts, err := parseArgs(os.Args[1:])
+	opts, err := parseArgs(args)
 	if err != nil {
 		os.Exit(1)
 	}
 
-	addr := fmt.Sprintf("%s:%d", opts.Host, opts.Port)
-	cert, err := getCert(addr)
+	if opts.File != "" {
+		return checkFile(opts)
+	}
+	if opts.Host == "" {
+		return checkers.Unknown("either --host or --file is required")
+	}
+
+	serverName := opts.SNI
+	if serverName == "" {
+		serverName = opts.Host
+	}
+	var roots *x509.CertPool
+	if opts.CACert != "" {
+		roots, err = loadCACert(opts.CACert)
+		if err != nil {
+			return checkers.Unknown(err.Error())
+		}
+	}
+
+	policy, err := newTLSPolicy(opts.MinTLSVersion, opts.ForbiddenCiphers)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	cfg := &tls.Config{
+		ServerName: serverName,
+		// verified later to check the expiry of even the expired certificate
+		InsecureSkipVerify: true,
+	}
+	policy.configure(cfg)
+
+	addr := net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
+	state, err := getConnState(addr, cfg, time.Duration(opts.Timeout)*time.Second)
 	if err != nil {
 		return checkers.Critical(err.Error())
 	}
+	if err := policy.check(state); err != nil {
+		return checkers.Critical(fmt.Sprintf("'%s' violates the TLS policy: %s", addr, err))
+	}
+	certs := state.PeerCertificates
+
+	ckr := evalCert(opts, addr, certs[0], time.Now())
+	if ckr.Status == checkers.CRITICAL {
+		return ckr
+	}
+	if err := verifyCerts(certs, serverName, roots); err != nil {
+		return checkers.Critical(fmt.Sprintf("Certificate '%s' is invalid: %s", addr, err))
+	}
+	if opts.CheckOCSPStapling || opts.RequireOCSPStapling {
+		st, msg := evalOCSPStapling(state, opts.RequireOCSPStapling, time.Now())
+		if st > ckr.Status {
+			ckr.Status = st
+		}
+		ckr.Message += "\n" + msg
+	}
+	return ckr
+}
+
+func loadCACert(file string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates are found in %s", file)
+	}
+	return roots, nil
+}
+
+// readCertFile returns all the certificates in the PEM file
+func readCertFile(file string) ([]*x509.Certificate, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates are found in %s", file)
+	}
+	return certs, nil
+}
+
+// checkFile checks the certificate which expires first in the file
+func checkFile(opts *certOpts) *checkers.Checker {
+	certs, err := readCertFile(opts.File)
+	if err != nil {
+		if os.IsNotExist(err) && opts.CriticalWhenNotFound {
+			return checkers.Critical(err.Error())
+		}
+		return checkers.Unknown(err.Error())
+	}
+
+	earliest := certs[0]
+	for _, cert := range certs[1:] {
+		if cert.NotAfter.Before(earliest.NotAfter) {
+			earliest = cert
+		}
+	}
+	return evalCert(opts, opts.File, earliest, time.Now())
+}
+
+// getConnState returns the state of the connection after the handshake
+func getConnState(addr string, cfg *tls.Config, timeout time.Duration) (tls.ConnectionState, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, cfg)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer conn.Close()
+	state := conn.ConnectionState()
+
+	if len(state.PeerCertificates) < 1 {
+		return state, fmt.Errorf("no certifiations are available")
+	}
+	return state, nil
+}
+
+// verifyCerts verifies the chain and the server name as tls.Dial does
+func verifyCerts(certs []*x509.Certificate, serverName string, roots *x509.CertPool) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
+// CheckExpiry checks the expiry of the certificate with the thresholds in
+// days, for the other plugins
+func CheckExpiry(name string, cert *x509.Certificate, warning, critical int) *checkers.Checker {
+	return evalCert(&certOpts{Warning: warning, Critical: critical}, name, cert, time.Now())
+}
+
+func evalCert(opts *certOpts, name string, cert *x509.Certificate, now time.Time) *checkers.Checker {
 	expiry := cert.NotAfter
-	dur := expiry.Sub(time.Now())
+	dur := expiry.Sub(now)
+	if dur <= 0 {
+		return checkers.Critical(fmt.Sprintf("Certificate '%s' has EXPIRED at %s%s", name, expiry, certDetails(cert)))
+	}
 
 	chkSt := checkers.OK
 	days := int(dur.Hours() / 24)
@@ -53,26 +218,22 @@ func run(args []string) *checkers.Checker {
 	} else {
 		dayMsg += "days"
 	}
-	msg := fmt.Sprintf("Certificate '%s' expires in %s (%s)", addr, dayMsg, expiry)
+	msg := fmt.Sprintf("Certificate '%s' expires in %s (%s)", name, dayMsg, expiry)
 	if dur < time.Duration(opts.Warning)*time.Hour*24 {
 		chkSt = checkers.WARNING
 	}
 	if dur < time.Duration(opts.Critical)*time.Hour*24 {
 		chkSt = checkers.CRITICAL
 	}
-	return checkers.NewChecker(chkSt, msg)
+	return checkers.NewChecker(chkSt, msg+certDetails(cert))
 }
 
-func getCert(addr string) (*x509.Certificate, error) {
-	conn, err := tls.Dial("tcp", addr, &tls.Config{})
-	if err != nil {
-		return nil, err
-	}
-	defer conn.Close()
-	certs := conn.ConnectionState().PeerCertificates
-
-	if len(certs) < 1 {
-		return nil, fmt.Errorf("no certifiations are available")
+// certDetails returns the lines to append to the message
+func certDetails(cert *x509.Certificate) string {
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
 	}
-	return certs[0], err
+	return fmt.Sprintf("\nsubject CN: %s\nSANs: %s\nissuer: %s\nnot before: %s\nnot after: %s",
+		cert.Subject.CommonName, strings.Join(sans, ", "), cert.Issuer.CommonName, cert.NotBefore, cert.NotAfter)
 }
@@ -0,0 +1,221 @@
+package checksslcert
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestEvalCert(t *testing.T) {
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	opts := &certOpts{Warning: 30, Critical: 14}
+	cert := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "example.com"},
+		Issuer:      pkix.Name{CommonName: "Example CA"},
+		DNSNames:    []string{"example.com", "www.example.com"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:   now.AddDate(0, -1, 0),
+		NotAfter:    now.AddDate(0, 0, 20),
+	}
+
+	ckr := evalCert(opts, "example.com:443", cert, now)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, `Certificate 'example.com:443' expires in 20 days (2018-01-21 00:00:00 +0000 UTC)
+subject CN: example.com
+SANs: example.com, www.example.com, 127.0.0.1
+issuer: Example CA
+not before: 2017-12-01 00:00:00 +0000 UTC
+not after: 2018-01-21 00:00:00 +0000 UTC`, ckr.Message)
+
+	ckr = evalCert(opts, "example.com:443", cert, now.AddDate(0, 0, 10))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = evalCert(opts, "example.com:443", cert, now.AddDate(0, 0, -20))
+	assert.Equal(t, checkers.OK, ckr.Status)
+
+	ckr = evalCert(opts, "example.com:443", cert, now.AddDate(0, 0, 21))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Regexp(t, `^Certificate 'example.com:443' has EXPIRED at 2018-01-21 00:00:00 \+0000 UTC\n`, ckr.Message)
+}
+
+func TestRun(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	args := []string{"-H", host, "-p", port}
+
+	ckr := run(args)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status, "the certificate of httptest is not trusted by the system")
+	assert.Regexp(t, `^Certificate '127.0.0.1:\d+' is invalid: `, ckr.Message)
+
+	f, err := ioutil.TempFile("", "check-ssl-cert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	f.Close()
+
+	ckr = run(append(args, "--ca-cert", f.Name()))
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Regexp(t, `^Certificate '127.0.0.1:\d+' expires in \d+ days`, ckr.Message)
+
+	ckr = run(append(args, "--ca-cert", f.Name(), "--sni", "example.com"))
+	assert.Equal(t, checkers.OK, ckr.Status)
+
+	ckr = run(append(args, "--ca-cert", f.Name(), "--sni", "example.org"))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+}
+
+// certPEM creates a self-signed certificate which expires at notAfter
+func certPEM(t *testing.T, cn string, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    notAfter.AddDate(-1, 0, 0),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return buf.Bytes()
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check-ssl-cert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	chain := dir + "/chain.pem"
+	content := append(certPEM(t, "leaf", now.AddDate(0, 0, 60)), certPEM(t, "intermediate", now.AddDate(0, 0, 20))...)
+	ioutil.WriteFile(chain, content, 0644)
+
+	ckr := run([]string{"--file", chain})
+	assert.Equal(t, checkers.WARNING, ckr.Status, "the certificate which expires first should be checked")
+	assert.Regexp(t, `^Certificate '.+/chain.pem' expires in 19 days .*\nsubject CN: intermediate\n`, ckr.Message)
+
+	expired := dir + "/expired.pem"
+	ioutil.WriteFile(expired, certPEM(t, "expired", now.AddDate(0, 0, -1)), 0644)
+	ckr = run([]string{"--file", expired})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	empty := dir + "/empty.pem"
+	ioutil.WriteFile(empty, []byte("not a certificate"), 0644)
+	ckr = run([]string{"--file", empty})
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status)
+
+	ckr = run([]string{"--file", dir + "/missing.pem"})
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status)
+
+	ckr = run([]string{"--file", dir + "/missing.pem", "--critical-when-not-found"})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = run([]string{})
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status)
+}
+
+func TestTLSPolicy(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = &tls.Config{
+		MinVersion:   tls.VersionTLS10,
+		MaxVersion:   tls.VersionTLS11,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA},
+	}
+	ts.StartTLS()
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	args := []string{"-H", host, "-p", port}
+
+	ckr := run(append(args, "--min-tls-version", "TLS12"))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Regexp(t, `^'127.0.0.1:\d+' violates the TLS policy: TLS version TLS11 is lower than TLS12$`, ckr.Message)
+
+	ckr = run(append(args, "--min-tls-version", "TLS10", "--forbidden-cipher", "TLS_RSA_WITH_RC4_128_SHA", "--forbidden-cipher", "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA"))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Regexp(t, `^'127.0.0.1:\d+' violates the TLS policy: cipher suite TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA is forbidden$`, ckr.Message)
+
+	ckr = run(append(args, "--min-tls-version", "TLS10", "--forbidden-cipher", "TLS_RSA_WITH_RC4_128_SHA"))
+	assert.Regexp(t, `^Certificate '127.0.0.1:\d+' is invalid: `, ckr.Message, "the policy should be satisfied")
+
+	ckr = run(append(args, "--forbidden-cipher", "TLS_UNKNOWN"))
+	assert.Equal(t, checkers.UNKNOWN, ckr.Status)
+	assert.Equal(t, "unknown cipher suite: TLS_UNKNOWN", ckr.Message)
+}
+
+func TestOCSPStapling(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	cert := ts.Certificate()
+
+	staple := func(status int, nextUpdate time.Time) tls.ConnectionState {
+		resp, err := ocsp.CreateResponse(cert, cert, ocsp.Response{
+			Status:       status,
+			SerialNumber: cert.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Hour),
+			NextUpdate:   nextUpdate,
+			RevokedAt:    time.Now().Add(-time.Hour),
+		}, ts.TLS.Certificates[0].PrivateKey.(crypto.Signer))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, OCSPResponse: resp}
+	}
+	now := time.Now()
+
+	st, msg := evalOCSPStapling(staple(ocsp.Good, now.Add(time.Hour)), false, now)
+	assert.Equal(t, checkers.OK, st)
+	assert.Regexp(t, `^OCSP status: good`, msg)
+
+	st, msg = evalOCSPStapling(staple(ocsp.Revoked, now.Add(time.Hour)), false, now)
+	assert.Equal(t, checkers.CRITICAL, st)
+	assert.Regexp(t, `^OCSP status: REVOKED at `, msg)
+
+	st, _ = evalOCSPStapling(staple(ocsp.Unknown, now.Add(time.Hour)), false, now)
+	assert.Equal(t, checkers.WARNING, st)
+
+	st, msg = evalOCSPStapling(staple(ocsp.Good, now.Add(-time.Minute)), false, now)
+	assert.Equal(t, checkers.CRITICAL, st)
+	assert.Regexp(t, `^OCSP response is outdated`, msg)
+
+	st, _ = evalOCSPStapling(tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, OCSPResponse: []byte("invalid")}, false, now)
+	assert.Equal(t, checkers.CRITICAL, st)
+
+	noStaple := tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	st, msg = evalOCSPStapling(noStaple, false, now)
+	assert.Equal(t, checkers.WARNING, st)
+	assert.Equal(t, "OCSP stapling is not configured", msg)
+
+	st, _ = evalOCSPStapling(noStaple, true, now)
+	assert.Equal(t, checkers.CRITICAL, st)
+}
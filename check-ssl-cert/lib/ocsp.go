@@ -0,0 +1,43 @@
+package checksslcert
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/mackerelio/checkers"
+	"golang.org/x/crypto/ocsp"
+)
+
+// evalOCSPStapling checks the OCSP response stapled in the handshake
+func evalOCSPStapling(state tls.ConnectionState, require bool, now time.Time) (checkers.Status, string) {
+	if len(state.OCSPResponse) == 0 {
+		if require {
+			return checkers.CRITICAL, "OCSP stapling is not configured"
+		}
+		return checkers.WARNING, "OCSP stapling is not configured"
+	}
+
+	certs := state.PeerCertificates
+	// a self-signed certificate is the issuer of itself
+	issuer := certs[0]
+	if len(certs) > 1 {
+		issuer = certs[1]
+	}
+	resp, err := ocsp.ParseResponseForCert(state.OCSPResponse, certs[0], issuer)
+	if err != nil {
+		return checkers.CRITICAL, fmt.Sprintf("invalid OCSP response: %s", err)
+	}
+
+	if !resp.NextUpdate.IsZero() && resp.NextUpdate.Before(now) {
+		return checkers.CRITICAL, fmt.Sprintf("OCSP response is outdated (next update: %s)", resp.NextUpdate)
+	}
+	switch resp.Status {
+	case ocsp.Good:
+		return checkers.OK, fmt.Sprintf("OCSP status: good (next update: %s)", resp.NextUpdate)
+	case ocsp.Revoked:
+		return checkers.CRITICAL, fmt.Sprintf("OCSP status: REVOKED at %s", resp.RevokedAt)
+	default:
+		return checkers.WARNING, "OCSP status: unknown"
+	}
+}
@@ -0,0 +1,100 @@
+package checksslcert
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sort"
+)
+
+// the constants of TLS 1.3 are defined here to build with Go 1.11
+var tlsVersions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": 0x0304,
+}
+
+var cipherSuites = map[string]uint16{
+	"TLS_RSA_WITH_RC4_128_SHA":                tls.TLS_RSA_WITH_RC4_128_SHA,
+	"TLS_RSA_WITH_3DES_EDE_CBC_SHA":           tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_CBC_SHA":            tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_RSA_WITH_AES_256_CBC_SHA":            tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_CBC_SHA256":         tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
+	"TLS_RSA_WITH_AES_128_GCM_SHA256":         tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_RSA_WITH_AES_256_GCM_SHA384":         tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_RC4_128_SHA":        tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_RC4_128_SHA":          tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA,
+	"TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA":     tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256": tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256":   tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":   tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256": tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":   tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384": tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305":    tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305":  tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	"TLS_AES_128_GCM_SHA256":                  0x1301,
+	"TLS_AES_256_GCM_SHA384":                  0x1302,
+	"TLS_CHACHA20_POLY1305_SHA256":            0x1303,
+}
+
+func nameOf(m map[string]uint16, v uint16) string {
+	for name, value := range m {
+		if value == v {
+			return name
+		}
+	}
+	return fmt.Sprintf("0x%04x", v)
+}
+
+type tlsPolicy struct {
+	minVersion       uint16
+	forbiddenCiphers []uint16
+}
+
+func newTLSPolicy(minVersion string, forbiddenCiphers []string) (*tlsPolicy, error) {
+	p := &tlsPolicy{}
+	if minVersion != "" {
+		p.minVersion = tlsVersions[minVersion]
+	}
+	for _, name := range forbiddenCiphers {
+		id, ok := cipherSuites[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite: %s", name)
+		}
+		p.forbiddenCiphers = append(p.forbiddenCiphers, id)
+	}
+	return p, nil
+}
+
+// configure makes the client offer the old versions and all the cipher
+// suites, so that the server can negotiate the ones to be forbidden
+func (p *tlsPolicy) configure(cfg *tls.Config) {
+	if p.minVersion == 0 && len(p.forbiddenCiphers) == 0 {
+		return
+	}
+	cfg.MinVersion = tls.VersionTLS10
+	for _, id := range cipherSuites {
+		cfg.CipherSuites = append(cfg.CipherSuites, id)
+	}
+	sort.Slice(cfg.CipherSuites, func(i, j int) bool { return cfg.CipherSuites[i] < cfg.CipherSuites[j] })
+}
+
+// check returns an error if the negotiated version or cipher suite
+// violates the policy
+func (p *tlsPolicy) check(state tls.ConnectionState) error {
+	if state.Version < p.minVersion {
+		return fmt.Errorf("TLS version %s is lower than %s",
+			nameOf(tlsVersions, state.Version), nameOf(tlsVersions, p.minVersion))
+	}
+	for _, id := range p.forbiddenCiphers {
+		if state.CipherSuite == id {
+			return fmt.Errorf("cipher suite %s is forbidden", nameOf(cipherSuites, id))
+		}
+	}
+	return nil
+}
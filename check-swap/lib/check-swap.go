@@ -0,0 +1,127 @@
+package checkswap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+	"github.com/mackerelio/go-check-plugins/check-file-size/lib"
+)
+
+type swapOpts struct {
+	Warning     float64 `short:"w" long:"warning" value-name:"PERCENT" default:"50" description:"warning if the swap usage is over (%)"`
+	Critical    float64 `short:"c" long:"critical" value-name:"PERCENT" default:"80" description:"critical if the swap usage is over (%)"`
+	WarnIfAny   bool    `long:"warn-if-any" description:"warning if any swap is in use"`
+	RequireSwap bool    `long:"require-swap" description:"warning if no swap is configured"`
+}
+
+// swapDevice is a swap partition or file in /proc/swaps
+type swapDevice struct {
+	name string
+	typ  string
+	size uint64
+	used uint64
+}
+
+type swapStat struct {
+	total   uint64
+	free    uint64
+	devices []swapDevice
+}
+
+func (s *swapStat) used() uint64 {
+	if s.free > s.total {
+		return 0
+	}
+	return s.total - s.free
+}
+
+// Do the plugin
+func Do() {
+	ckr := run(os.Args[1:])
+	ckr.Name = "Swap"
+	ckr.Exit()
+}
+
+// parseSwaps parses the content of /proc/swaps, whose sizes are in KiB
+func parseSwaps(r io.Reader) ([]swapDevice, error) {
+	var devices []swapDevice
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] == "Filename" {
+			continue
+		}
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("unexpected format of /proc/swaps: %q", scanner.Text())
+		}
+		size, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		used, err := strconv.ParseUint(fields[3], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, swapDevice{
+			// spaces in the file name are escaped as \040
+			name: strings.Replace(fields[0], `\040`, " ", -1),
+			typ:  fields[1],
+			size: size * 1024,
+			used: used * 1024,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+func evalSwap(opts *swapOpts, s *swapStat) *checkers.Checker {
+	if s.total == 0 {
+		if opts.RequireSwap {
+			return checkers.Warning("No swap configured")
+		}
+		return checkers.Ok("No swap configured")
+	}
+
+	used := float64(s.used())
+	usage := used / float64(s.total) * 100
+	checkSt := checkers.OK
+	if usage > opts.Critical {
+		checkSt = checkers.CRITICAL
+	} else if usage > opts.Warning || opts.WarnIfAny && used > 0 {
+		checkSt = checkers.WARNING
+	}
+
+	msgs := []string{fmt.Sprintf("%.2f%% used (total %s, free %s, used %s)",
+		usage, checkfilesize.HumanizeBytes(float64(s.total)), checkfilesize.HumanizeBytes(float64(s.free)), checkfilesize.HumanizeBytes(used))}
+	for _, d := range s.devices {
+		free := uint64(0)
+		if d.size > d.used {
+			free = d.size - d.used
+		}
+		msgs = append(msgs, fmt.Sprintf("%s (%s): total %s, used %s, free %s",
+			d.name, d.typ, checkfilesize.HumanizeBytes(float64(d.size)), checkfilesize.HumanizeBytes(float64(d.used)), checkfilesize.HumanizeBytes(float64(free))))
+	}
+	return checkers.NewChecker(checkSt, strings.Join(msgs, "\n"))
+}
+
+func run(args []string) *checkers.Checker {
+	opts := swapOpts{}
+	_, err := flags.ParseArgs(&opts, args)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	s, err := getSwap()
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	return evalSwap(&opts, s)
+}
@@ -0,0 +1,28 @@
+package checkswap
+
+import (
+	"os"
+
+	"github.com/mackerelio/go-osstat/memory"
+)
+
+func getSwap() (*swapStat, error) {
+	m, err := memory.Get()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open("/proc/swaps")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	devices, err := parseSwaps(f)
+	if err != nil {
+		return nil, err
+	}
+	return &swapStat{
+		total:   m.SwapTotal,
+		free:    m.SwapFree,
+		devices: devices,
+	}, nil
+}
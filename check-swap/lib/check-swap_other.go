@@ -0,0 +1,12 @@
+// +build !linux
+
+package checkswap
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func getSwap() (*swapStat, error) {
+	return nil, fmt.Errorf("check-swap is not supported on %s", runtime.GOOS)
+}
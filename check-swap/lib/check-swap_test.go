@@ -0,0 +1,62 @@
+package checkswap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+const procSwaps = `Filename				Type		Size	Used	Priority
+/dev/sda2                               partition	2097148	524288	-2
+/var/swap\040file                       file		1048576	0	-3
+`
+
+func TestParseSwaps(t *testing.T) {
+	devices, err := parseSwaps(strings.NewReader(procSwaps))
+	assert.Nil(t, err)
+	assert.Equal(t, []swapDevice{
+		{name: "/dev/sda2", typ: "partition", size: 2097148 * 1024, used: 524288 * 1024},
+		{name: "/var/swap file", typ: "file", size: 1048576 * 1024, used: 0},
+	}, devices)
+
+	devices, err = parseSwaps(strings.NewReader("Filename\t\t\t\tType\t\tSize\tUsed\tPriority\n"))
+	assert.Nil(t, err)
+	assert.Len(t, devices, 0)
+}
+
+func TestEvalSwap(t *testing.T) {
+	devices, _ := parseSwaps(strings.NewReader(procSwaps))
+	s := &swapStat{
+		total:   3145724 * 1024,
+		free:    2621436 * 1024,
+		devices: devices,
+	}
+
+	ckr := evalSwap(&swapOpts{Warning: 50, Critical: 80}, s)
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "16.67% used (total 3.00 GB, free 2.50 GB, used 512.00 MB)\n"+
+		"/dev/sda2 (partition): total 2.00 GB, used 512.00 MB, free 1.50 GB\n"+
+		"/var/swap file (file): total 1.00 GB, used 0.00 B, free 1.00 GB", ckr.Message)
+
+	ckr = evalSwap(&swapOpts{Warning: 10, Critical: 80}, s)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	ckr = evalSwap(&swapOpts{Warning: 10, Critical: 15}, s)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = evalSwap(&swapOpts{Warning: 50, Critical: 80, WarnIfAny: true}, s)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	ckr = evalSwap(&swapOpts{Warning: 50, Critical: 80, WarnIfAny: true}, &swapStat{total: 1024, free: 1024})
+	assert.Equal(t, checkers.OK, ckr.Status)
+
+	ckr = evalSwap(&swapOpts{Warning: 50, Critical: 80}, &swapStat{})
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "No swap configured", ckr.Message)
+
+	ckr = evalSwap(&swapOpts{Warning: 50, Critical: 80, RequireSwap: true}, &swapStat{})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "No swap configured", ckr.Message)
+}
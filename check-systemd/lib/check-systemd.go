@@ -0,0 +1,81 @@
+package checksystemd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+type systemdOpts struct {
+	Unit              string  `short:"u" long:"unit" required:"true" description:"Unit name with the suffix, e.g. nginx.service"`
+	ExpectActive      string  `long:"expect-active" value-name:"STATE" default:"active" description:"Expected active state, critical if the unit is in another state"`
+	ActivatingTimeout int64   `long:"activating-timeout" value-name:"SECONDS" default:"60" description:"warning if the unit is activating for longer than"`
+	CheckRestartCount bool    `long:"check-restart-count" description:"Check the number of the restarts of the service unit by systemd"`
+	RestartWarning    *uint32 `long:"restart-warning" value-name:"COUNT" description:"warning if the service has been restarted more than (with --check-restart-count)"`
+	RestartCritical   *uint32 `long:"restart-critical" value-name:"COUNT" description:"critical if the service has been restarted more than (with --check-restart-count)"`
+}
+
+// Do the plugin
+func Do() {
+	ckr := run(os.Args[1:])
+	ckr.Name = "systemd"
+	ckr.Exit()
+}
+
+type unitStatus struct {
+	loadState   string
+	activeState string
+	subState    string
+	stateChange time.Time
+	nRestarts   uint32
+}
+
+func evalUnit(opts *systemdOpts, st *unitStatus, now time.Time) *checkers.Checker {
+	if st.loadState == "not-found" {
+		return checkers.Critical(fmt.Sprintf("%s is not found", opts.Unit))
+	}
+
+	checkSt := checkers.OK
+	msg := fmt.Sprintf("%s is %s (%s)", opts.Unit, st.activeState, st.subState)
+	switch {
+	case st.activeState == opts.ExpectActive:
+	case st.activeState == "failed":
+		checkSt = checkers.CRITICAL
+	case st.activeState == "activating":
+		elapsed := now.Sub(st.stateChange)
+		msg += fmt.Sprintf(" for %.0f seconds", elapsed.Seconds())
+		if elapsed > time.Duration(opts.ActivatingTimeout)*time.Second {
+			checkSt = checkers.WARNING
+		}
+	default:
+		checkSt = checkers.CRITICAL
+		msg += fmt.Sprintf(", expected %s", opts.ExpectActive)
+	}
+
+	if opts.CheckRestartCount {
+		msg += fmt.Sprintf(", restarted %d times", st.nRestarts)
+		if opts.RestartCritical != nil && st.nRestarts > *opts.RestartCritical {
+			checkSt = checkers.CRITICAL
+		} else if opts.RestartWarning != nil && st.nRestarts > *opts.RestartWarning && checkSt == checkers.OK {
+			checkSt = checkers.WARNING
+		}
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func run(args []string) *checkers.Checker {
+	opts := systemdOpts{}
+	_, err := flags.ParseArgs(&opts, args)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	st, err := getUnitStatus(opts.Unit, opts.CheckRestartCount)
+	if err != nil {
+		return checkers.Unknown(fmt.Sprintf("couldn't get the status of %s: %s", opts.Unit, err))
+	}
+	return evalUnit(&opts, st, time.Now())
+}
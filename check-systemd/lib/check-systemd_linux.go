@@ -0,0 +1,54 @@
+package checksystemd
+
+import (
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const systemdDest = "org.freedesktop.systemd1"
+
+type property struct {
+	name  string
+	value interface{}
+}
+
+// getUnitStatus reads the properties of the unit via D-Bus
+func getUnitStatus(unit string, withRestarts bool) (*unitStatus, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var path dbus.ObjectPath
+	manager := conn.Object(systemdDest, "/org/freedesktop/systemd1")
+	if err := manager.Call(systemdDest+".Manager.LoadUnit", 0, unit).Store(&path); err != nil {
+		return nil, err
+	}
+	obj := conn.Object(systemdDest, path)
+
+	var st unitStatus
+	var stateChange uint64
+	props := []property{
+		{systemdDest + ".Unit.LoadState", &st.loadState},
+		{systemdDest + ".Unit.ActiveState", &st.activeState},
+		{systemdDest + ".Unit.SubState", &st.subState},
+		{systemdDest + ".Unit.StateChangeTimestamp", &stateChange},
+	}
+	if withRestarts {
+		props = append(props, property{systemdDest + ".Service.NRestarts", &st.nRestarts})
+	}
+	for _, p := range props {
+		v, err := obj.GetProperty(p.name)
+		if err != nil {
+			return nil, err
+		}
+		if err := v.Store(p.value); err != nil {
+			return nil, err
+		}
+	}
+	// in microseconds since the epoch
+	st.stateChange = time.Unix(0, int64(stateChange)*int64(time.Microsecond))
+	return &st, nil
+}
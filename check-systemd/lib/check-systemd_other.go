@@ -0,0 +1,12 @@
+// +build !linux
+
+package checksystemd
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func getUnitStatus(unit string, withRestarts bool) (*unitStatus, error) {
+	return nil, fmt.Errorf("check-systemd is not supported on %s", runtime.GOOS)
+}
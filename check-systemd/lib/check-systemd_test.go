@@ -0,0 +1,55 @@
+package checksystemd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvalUnit(t *testing.T) {
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	opts := &systemdOpts{Unit: "nginx.service", ExpectActive: "active", ActivatingTimeout: 60}
+
+	ckr := evalUnit(opts, &unitStatus{loadState: "loaded", activeState: "active", subState: "running"}, now)
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "nginx.service is active (running)", ckr.Message)
+
+	ckr = evalUnit(opts, &unitStatus{loadState: "loaded", activeState: "failed", subState: "failed"}, now)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+
+	ckr = evalUnit(opts, &unitStatus{loadState: "loaded", activeState: "inactive", subState: "dead"}, now)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "nginx.service is inactive (dead), expected active", ckr.Message)
+
+	ckr = evalUnit(opts, &unitStatus{loadState: "loaded", activeState: "activating", subState: "start", stateChange: now.Add(-30 * time.Second)}, now)
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "nginx.service is activating (start) for 30 seconds", ckr.Message)
+
+	ckr = evalUnit(opts, &unitStatus{loadState: "loaded", activeState: "activating", subState: "auto-restart", stateChange: now.Add(-90 * time.Second)}, now)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	ckr = evalUnit(opts, &unitStatus{loadState: "not-found", activeState: "inactive", subState: "dead"}, now)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "nginx.service is not found", ckr.Message)
+
+	ckr = evalUnit(&systemdOpts{Unit: "backup.timer", ExpectActive: "inactive"}, &unitStatus{loadState: "loaded", activeState: "inactive", subState: "dead"}, now)
+	assert.Equal(t, checkers.OK, ckr.Status)
+}
+
+func TestEvalUnitRestarts(t *testing.T) {
+	now := time.Now()
+	warn, crit := uint32(0), uint32(5)
+	opts := &systemdOpts{Unit: "app.service", ExpectActive: "active", CheckRestartCount: true, RestartWarning: &warn, RestartCritical: &crit}
+
+	ckr := evalUnit(opts, &unitStatus{loadState: "loaded", activeState: "active", subState: "running"}, now)
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "app.service is active (running), restarted 0 times", ckr.Message)
+
+	ckr = evalUnit(opts, &unitStatus{loadState: "loaded", activeState: "active", subState: "running", nRestarts: 3}, now)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	ckr = evalUnit(opts, &unitStatus{loadState: "loaded", activeState: "active", subState: "running", nRestarts: 6}, now)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,13 +19,15 @@ type tcpOpts struct {
 	Service  string `long:"service" description:"Service name. e.g. ftp, smtp, pop, imap and so on"`
 	Hostname string `short:"H" long:"hostname" description:"Host name or IP Address"`
 	exchange
-	Timeout    float64 `short:"t" long:"timeout" default:"10" description:"Seconds before connection times out"`
-	MaxBytes   int     `short:"m" long:"maxbytes" description:"Close connection once more than this number of bytes are received"`
-	Delay      float64 `short:"d" long:"delay" description:"Seconds to wait between sending string and polling for response"`
-	Warning    float64 `short:"w" long:"warning" description:"Response time to result in warning status (seconds)"`
-	Critical   float64 `short:"c" long:"critical" description:"Response time to result in critical status (seconds)"`
-	Escape     bool    `short:"E" long:"escape" description:"Can use \\n, \\r, \\t or \\ in send or quit string. Must come before send or quit option. By default, nothing added to send, \\r\\n added to end of quit"`
-	ErrWarning bool    `short:"W" long:"error-warning" description:"Set the error level to warning when exiting with unexpected error (default: critical). In the case of request succeeded, evaluation result of -c option eval takes priority."`
+	Timeout     float64 `short:"t" long:"timeout" default:"10" description:"Seconds before connection times out"`
+	MaxBytes    int     `short:"m" long:"maxbytes" description:"Close connection once more than this number of bytes are received"`
+	Delay       float64 `short:"d" long:"delay" description:"Seconds to wait between sending string and polling for response"`
+	Warning     float64 `short:"w" long:"warning" description:"Response time to result in warning status (seconds)"`
+	Critical    float64 `short:"c" long:"critical" description:"Response time to result in critical status (seconds)"`
+	Escape      bool    `short:"E" long:"escape" description:"Can use \\n, \\r, \\t or \\ in send or quit string. Must come before send or quit option. By default, nothing added to send, \\r\\n added to end of quit"`
+	ErrWarning  bool    `short:"W" long:"error-warning" description:"Set the error level to warning when exiting with unexpected error (default: critical). In the case of request succeeded, evaluation result of -c option eval takes priority."`
+	TLSWarnDays *int    `long:"tls-warn-days" description:"Days before the certificate expires to result in warning status, used with --ssl"`
+	TLSCritDays *int    `long:"tls-crit-days" description:"Days before the certificate expires to result in critical status, used with --ssl"`
 }
 
 type exchange struct {
@@ -115,6 +118,10 @@ func (opts *tcpOpts) prepare() error {
 		opts.merge(defaultEx)
 	}
 
+	if (opts.TLSWarnDays != nil || opts.TLSCritDays != nil) && !opts.SSL {
+		return fmt.Errorf("--tls-warn-days and --tls-crit-days require --ssl")
+	}
+
 	if opts.Escape {
 		opts.Quit = escapedString(opts.Quit)
 		opts.Send = escapedString(opts.Send)
@@ -166,7 +173,8 @@ func (opts *tcpOpts) run() *checkers.Checker {
 	os.Setenv("LC_ALL", "C")
 
 	proto := "tcp"
-	addr := fmt.Sprintf("%s:%d", opts.Hostname, opts.Port)
+	// IPv6 address is accepted with or without brackets
+	addr := net.JoinHostPort(strings.TrimSuffix(strings.TrimPrefix(opts.Hostname, "["), "]"), strconv.Itoa(opts.Port))
 	if opts.UnixSock != "" {
 		proto = "unix"
 		addr = opts.UnixSock
@@ -232,6 +240,19 @@ func (opts *tcpOpts) run() *checkers.Checker {
 	if opts.Critical > 0 && elapsedSeconds > opts.Critical {
 		chkSt = checkers.CRITICAL
 	}
+	certMsg := ""
+	if tlsConn, ok := conn.(*tls.Conn); ok && (opts.TLSWarnDays != nil || opts.TLSCritDays != nil) {
+		if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+			notAfter := certs[0].NotAfter
+			daysLeft := int(notAfter.Sub(time.Now()).Hours() / 24)
+			if opts.TLSCritDays != nil && daysLeft < *opts.TLSCritDays {
+				chkSt = checkers.CRITICAL
+			} else if opts.TLSWarnDays != nil && daysLeft < *opts.TLSWarnDays && chkSt == checkers.OK {
+				chkSt = checkers.WARNING
+			}
+			certMsg = fmt.Sprintf(", certificate expires at %s (%d days left)", notAfter.Format("2006-01-02 15:04:05 MST"), daysLeft)
+		}
+	}
 	msg := fmt.Sprintf("%.3f seconds response time on", elapsedSeconds)
 	if opts.Hostname != "" {
 		msg += " " + opts.Hostname
@@ -242,6 +263,7 @@ func (opts *tcpOpts) run() *checkers.Checker {
 	if res != "" {
 		msg += fmt.Sprintf(" [%s]", strings.Trim(res, "\r\n"))
 	}
+	msg += certMsg
 	return checkers.NewChecker(chkSt, msg)
 }
 
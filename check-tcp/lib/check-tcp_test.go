@@ -1,6 +1,7 @@
 package checktcp
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -10,6 +11,7 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -32,6 +34,64 @@ func TestTLS(t *testing.T) {
 	assert.Equal(t, checkers.OK, ckr.Status, "should be OK")
 }
 
+func TestTLSCertificateExpiry(t *testing.T) {
+	// the certificate of httptest expires in 2084
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "OKOK")
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+
+	testCases := []struct {
+		args    []string
+		want    checkers.Status
+		message string
+	}{
+		{
+			// self-signed
+			args: []string{"-S", "-H", host, "-p", port},
+			want: checkers.CRITICAL,
+		},
+		{
+			// the expiry is not checked by default
+			args:    []string{"-S", "--no-check-certificate", "-H", host, "-p", port},
+			want:    checkers.OK,
+			message: `response time on ` + host + ` port ` + port + `$`,
+		},
+		{
+			args:    []string{"-S", "--no-check-certificate", "-H", host, "-p", port, "--tls-warn-days", "30", "--tls-crit-days", "14"},
+			want:    checkers.OK,
+			message: `, certificate expires at 2084-.* \(\d+ days left\)$`,
+		},
+		{
+			args:    []string{"-S", "--no-check-certificate", "-H", host, "-p", port, "--tls-warn-days", "100000"},
+			want:    checkers.WARNING,
+			message: `, certificate expires at 2084-.* \(\d+ days left\)$`,
+		},
+		{
+			args:    []string{"-S", "--no-check-certificate", "-H", host, "-p", port, "--tls-crit-days", "100000"},
+			want:    checkers.CRITICAL,
+			message: `, certificate expires at 2084-.* \(\d+ days left\)$`,
+		},
+		{
+			args:    []string{"-H", host, "-p", port, "--tls-warn-days", "30"},
+			want:    checkers.UNKNOWN,
+			message: `require --ssl`,
+		},
+	}
+	for i, tc := range testCases {
+		opts, err := parseArgs(tc.args)
+		assert.Equal(t, nil, err, "no errors")
+		ckr := opts.run()
+		assert.Equal(t, tc.want, ckr.Status, "#%d: %s", i, ckr.Message)
+		if tc.message != "" {
+			assert.Regexp(t, tc.message, ckr.Message)
+		}
+	}
+}
+
 func TestFTP(t *testing.T) {
 	opts, err := parseArgs([]string{"--service=ftp", "-H", "ftp.iij.ad.jp"})
 	assert.Equal(t, nil, err, "no errors")
@@ -126,6 +186,87 @@ func TestGEARMAN(t *testing.T) {
 	server.shutdown()
 }
 
+func TestBannerAndExpect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				c.Write([]byte("220 mock ESMTP\r\n"))
+				r := bufio.NewReader(c)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch strings.TrimSpace(line) {
+					case "PING":
+						c.Write([]byte("+PONG\r\n"))
+					case "QUIT":
+						c.Write([]byte("221 Bye\r\n"))
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+
+	testCases := []struct {
+		args []string
+		want checkers.Status
+	}{
+		{
+			// the server speaks first
+			args: []string{"-H", host, "-p", port, "-e", "^220 ", "-q", "QUIT"},
+			want: checkers.OK,
+		},
+		{
+			args: []string{"-H", host, "-p", port, "-e", "^554 "},
+			want: checkers.CRITICAL,
+		},
+	}
+	for i, tc := range testCases {
+		opts, err := parseArgs(tc.args)
+		assert.Equal(t, nil, err, "no errors")
+		ckr := opts.run()
+		assert.Equal(t, tc.want, ckr.Status, "#%d: %s", i, ckr.Message)
+	}
+}
+
+func TestExpectTimeout(t *testing.T) {
+	// a server which never responds
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+
+	opts, err := parseArgs([]string{"-H", host, "-p", port, "-s", `PING\n`, "-E", "-e", "PONG", "-t", "0.5"})
+	assert.Equal(t, nil, err, "no errors")
+	ckr := opts.run()
+	assert.Equal(t, checkers.CRITICAL, ckr.Status, "should be CRITICAL")
+	assert.Regexp(t, `i/o timeout`, ckr.Message)
+}
+
 func TestHTTP(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		time.Sleep(time.Second / 5)
@@ -327,6 +468,15 @@ func TestHTTPIPv6(t *testing.T) {
 	}
 	testOk()
 
+	testOkWithoutBrackets := func() {
+		opts, err := parseArgs([]string{"-H", h, "-p", port, "--send", `GET / HTTP/1.0\r\n\r\n`, "-E", "-e", "OKOK"})
+		assert.Equal(t, nil, err, "no errors")
+		ckr := opts.run()
+		assert.Equal(t, checkers.OK, ckr.Status, "should be OK")
+		assert.Regexp(t, `seconds response time on`, ckr.Message, "Unexpected response")
+	}
+	testOkWithoutBrackets()
+
 	testUnexpected := func() {
 		opts, err := parseArgs(
 			[]string{"-H", host, "-p", port, "--send", `GET / HTTP/1.0\r\n\r\n`, "-E", "-e", "OKOKOK"})
@@ -10,7 +10,7 @@ import (
 	"github.com/mackerelio/go-osstat/uptime"
 )
 
-var opts struct {
+type uptimeOpts struct {
 	WarnUnder    *float64 `long:"warn-under" value-name:"N" description:"(DEPRECATED) Trigger a warning if under the seconds"`
 	WarningUnder *float64 `short:"w" long:"warning-under" value-name:"N" description:"Trigger a warning if under the seconds"`
 	CritUnder    *float64 `short:"c" long:"critical-under" value-name:"N" description:"Trigger a critial if under the seconds"`
@@ -26,7 +26,25 @@ func Do() {
 	ckr.Exit()
 }
 
+// plural returns "1 day" or "2 days"
+func plural(n int64, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// humanizeDuration formats the duration like "42 days 3 hours 15 minutes"
+func humanizeDuration(dur time.Duration) string {
+	hours := int64(dur.Hours())
+	days := hours / 24
+	hours = hours % 24
+	mins := int64(dur.Minutes()) % 60
+	return fmt.Sprintf("%s %s %s", plural(days, "day"), plural(hours, "hour"), plural(mins, "minute"))
+}
+
 func run(args []string) *checkers.Checker {
+	opts := uptimeOpts{}
 	_, err := flags.ParseArgs(&opts, args)
 	if err != nil {
 		os.Exit(1)
@@ -59,11 +77,7 @@ func run(args []string) *checkers.Checker {
 		checkSt = checkers.CRITICAL
 	}
 	dur := time.Duration(ut * float64(time.Second))
-	hours := int64(dur.Hours())
-	days := hours / 24
-	hours = hours % 24
-	mins := int64(dur.Minutes()) % 60
-	msg := fmt.Sprintf("%d day(s) %d hour(s) %d minute(s) (%d second(s))\n", days, hours, mins, int64(dur.Seconds()))
+	msg := fmt.Sprintf("%s (%s)", humanizeDuration(dur), plural(int64(dur.Seconds()), "second"))
 
 	return checkers.NewChecker(checkSt, msg)
 }
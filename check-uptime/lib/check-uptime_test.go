@@ -0,0 +1,14 @@
+package checkuptime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHumanizeDuration(t *testing.T) {
+	assert.Equal(t, "0 days 0 hours 0 minutes", humanizeDuration(30*time.Second))
+	assert.Equal(t, "1 day 1 hour 1 minute", humanizeDuration(25*time.Hour+time.Minute))
+	assert.Equal(t, "42 days 3 hours 15 minutes", humanizeDuration(42*24*time.Hour+3*time.Hour+15*time.Minute+59*time.Second))
+}
@@ -0,0 +1,93 @@
+package checkusers
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+	"github.com/shirou/gopsutil/host"
+)
+
+type usersOpts struct {
+	Warning  int    `short:"w" long:"warning" value-name:"N" default:"5" description:"warning if the number of the logged-in users is over"`
+	Critical int    `short:"c" long:"critical" value-name:"N" default:"10" description:"critical if the number of the logged-in users is over"`
+	User     string `long:"user" value-name:"REGEXP" description:"Count only the users whose name matches"`
+	Unique   bool   `long:"unique" description:"Count the unique user names instead of the sessions"`
+}
+
+// Do the plugin
+func Do() {
+	ckr := run(os.Args[1:])
+	ckr.Name = "Users"
+	ckr.Exit()
+}
+
+// plural returns "1 session" or "2 sessions"
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+func formatSession(u host.UserStat) string {
+	s := fmt.Sprintf("%s %s %s", u.User, u.Terminal, time.Unix(int64(u.Started), 0).Format("2006-01-02 15:04"))
+	if u.Host != "" {
+		s += fmt.Sprintf(" (%s)", u.Host)
+	}
+	return s
+}
+
+func evalUsers(opts *usersOpts, users []host.UserStat, userReg *regexp.Regexp) *checkers.Checker {
+	var sessions []string
+	names := make(map[string]bool)
+	for _, u := range users {
+		if userReg != nil && !userReg.MatchString(u.User) {
+			continue
+		}
+		names[u.User] = true
+		sessions = append(sessions, formatSession(u))
+	}
+
+	count := len(sessions)
+	msg := plural(count, "session")
+	if opts.Unique {
+		count = len(names)
+		msg = fmt.Sprintf("%s in %s", plural(count, "unique user"), msg)
+	}
+	checkSt := checkers.OK
+	if count > opts.Critical {
+		checkSt = checkers.CRITICAL
+	} else if count > opts.Warning {
+		checkSt = checkers.WARNING
+	}
+	if len(sessions) > 0 {
+		msg += "\n" + strings.Join(sessions, "\n")
+	}
+	return checkers.NewChecker(checkSt, msg)
+}
+
+func run(args []string) *checkers.Checker {
+	opts := usersOpts{}
+	_, err := flags.ParseArgs(&opts, args)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	var userReg *regexp.Regexp
+	if opts.User != "" {
+		if userReg, err = regexp.Compile(opts.User); err != nil {
+			return checkers.Unknown(err.Error())
+		}
+	}
+	// host.Users returns only the USER_PROCESS entries of utmp (utmpx on macOS)
+	users, err := host.Users()
+	if err != nil {
+		return checkers.Unknown(fmt.Sprintf("couldn't read the logged-in users: %s", err))
+	}
+	return evalUsers(&opts, users, userReg)
+}
@@ -0,0 +1,46 @@
+package checkusers
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/checkers"
+	"github.com/shirou/gopsutil/host"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvalUsers(t *testing.T) {
+	started := time.Date(2018, 4, 1, 9, 30, 0, 0, time.Local)
+	loginTime := started.Format("2006-01-02 15:04")
+	users := []host.UserStat{
+		{User: "alice", Terminal: "pts/0", Host: "192.0.2.10", Started: int(started.Unix())},
+		{User: "alice", Terminal: "pts/1", Host: "192.0.2.10", Started: int(started.Unix())},
+		{User: "bob", Terminal: "tty1", Started: int(started.Unix())},
+	}
+
+	ckr := evalUsers(&usersOpts{Warning: 1, Critical: 5}, users, nil)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Equal(t, "3 sessions\n"+
+		"alice pts/0 "+loginTime+" (192.0.2.10)\n"+
+		"alice pts/1 "+loginTime+" (192.0.2.10)\n"+
+		"bob tty1 "+loginTime, ckr.Message)
+
+	ckr = evalUsers(&usersOpts{Warning: 2, Critical: 5}, users, nil)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	ckr = evalUsers(&usersOpts{Warning: 2, Critical: 5, Unique: true}, users, nil)
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "2 unique users in 3 sessions\n"+
+		"alice pts/0 "+loginTime+" (192.0.2.10)\n"+
+		"alice pts/1 "+loginTime+" (192.0.2.10)\n"+
+		"bob tty1 "+loginTime, ckr.Message)
+
+	ckr = evalUsers(&usersOpts{Warning: 0, Critical: 0}, users, regexp.MustCompile(`^bob$`))
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "1 session\nbob tty1 "+loginTime, ckr.Message)
+
+	ckr = evalUsers(&usersOpts{Warning: 5, Critical: 10}, nil, nil)
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "0 sessions", ckr.Message)
+}
@@ -0,0 +1,8 @@
+package checkzombie
+
+import "github.com/mackerelio/go-check-plugins/check-procs/lib"
+
+// Do the plugin
+func Do() {
+	checkprocs.DoZombie()
+}
@@ -0,0 +1,163 @@
+package checkzookeeper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mackerelio/checkers"
+)
+
+type zookeeperOpts struct {
+	Host                string   `short:"H" long:"host" default:"localhost" description:"Hostname"`
+	Port                int      `short:"p" long:"port" default:"2181" description:"Port"`
+	Timeout             int      `long:"timeout" value-name:"SECONDS" default:"10" description:"Timeout in seconds"`
+	Warning             *float64 `short:"w" long:"warning" value-name:"MSEC" description:"warning if the average latency is over (ms)"`
+	Critical            *float64 `short:"c" long:"critical" value-name:"MSEC" description:"critical if the average latency is over (ms)"`
+	OutstandingWarning  *int64   `long:"outstanding-warning" value-name:"REQUESTS" description:"warning if the number of the outstanding requests is over"`
+	OutstandingCritical *int64   `long:"outstanding-critical" value-name:"REQUESTS" description:"critical if the number of the outstanding requests is over"`
+}
+
+// Do the plugin
+func Do() {
+	ckr := run(os.Args[1:])
+	ckr.Name = "ZooKeeper"
+	ckr.Exit()
+}
+
+type serverStat struct {
+	version     string
+	mode        string
+	connections int64
+	outstanding int64
+	zxid        string
+	minLatency  float64
+	avgLatency  float64
+	maxLatency  float64
+}
+
+// sendCommand sends the four letter word and returns the response
+func sendCommand(addr, cmd string, timeout time.Duration) (string, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := io.WriteString(conn, cmd); err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// parseSrvr parses the response of the srvr command like:
+//
+//	Zookeeper version: 3.4.13-2d71af4dbe22557fda74f9a9b4309b15a7487f03, built on 06/29/2018 04:05 GMT
+//	Latency min/avg/max: 0/0/23
+//	Received: 1203
+//	Sent: 1202
+//	Connections: 2
+//	Outstanding: 0
+//	Zxid: 0x10000002a
+//	Mode: follower
+//	Node count: 4
+func parseSrvr(r io.Reader) (*serverStat, error) {
+	var stat serverStat
+	var err error
+	parsed := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), ": ", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(kv[1])
+		switch kv[0] {
+		case "Zookeeper version":
+			stat.version = strings.SplitN(value, "-", 2)[0]
+			stat.version = strings.SplitN(stat.version, ",", 2)[0]
+		case "Latency min/avg/max":
+			latencies := strings.Split(value, "/")
+			if len(latencies) != 3 {
+				return nil, fmt.Errorf("invalid latency: %s", value)
+			}
+			for i, p := range []*float64{&stat.minLatency, &stat.avgLatency, &stat.maxLatency} {
+				if *p, err = strconv.ParseFloat(latencies[i], 64); err != nil {
+					return nil, err
+				}
+			}
+			parsed = true
+		case "Connections":
+			if stat.connections, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return nil, err
+			}
+		case "Outstanding":
+			if stat.outstanding, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return nil, err
+			}
+		case "Zxid":
+			stat.zxid = value
+		case "Mode":
+			stat.mode = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !parsed {
+		return nil, fmt.Errorf("unexpected response")
+	}
+	return &stat, nil
+}
+
+func evalStat(opts *zookeeperOpts, stat *serverStat) *checkers.Checker {
+	checkSt := checkers.OK
+	if opts.Critical != nil && stat.avgLatency > *opts.Critical ||
+		opts.OutstandingCritical != nil && stat.outstanding > *opts.OutstandingCritical {
+		checkSt = checkers.CRITICAL
+	} else if opts.Warning != nil && stat.avgLatency > *opts.Warning ||
+		opts.OutstandingWarning != nil && stat.outstanding > *opts.OutstandingWarning {
+		checkSt = checkers.WARNING
+	}
+	msg := fmt.Sprintf("mode %s, latency min/avg/max %g/%g/%g ms, connections %d, outstanding %d, zxid %s, version %s",
+		stat.mode, stat.minLatency, stat.avgLatency, stat.maxLatency, stat.connections, stat.outstanding, stat.zxid, stat.version)
+	return checkers.NewChecker(checkSt, msg)
+}
+
+// notServing is the response of the four letter words while the server is
+// not in the quorum
+const notServing = "This ZooKeeper instance is not currently serving requests"
+
+func run(args []string) *checkers.Checker {
+	opts := zookeeperOpts{}
+	_, err := flags.ParseArgs(&opts, args)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	addr := net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
+	resp, err := sendCommand(addr, "srvr", time.Duration(opts.Timeout)*time.Second)
+	if err != nil {
+		return checkers.Critical(err.Error())
+	}
+	if strings.HasPrefix(resp, notServing) {
+		return checkers.Critical(strings.TrimSpace(resp))
+	}
+	stat, err := parseSrvr(strings.NewReader(resp))
+	if err != nil {
+		// e.g. "srvr is not executed because it is not in the whitelist."
+		return checkers.Unknown(fmt.Sprintf("couldn't parse the response of srvr: %s: %s", err, strings.TrimSpace(resp)))
+	}
+	return evalStat(&opts, stat)
+}
@@ -0,0 +1,96 @@
+package checkzookeeper
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mackerelio/checkers"
+	"github.com/stretchr/testify/assert"
+)
+
+const srvrResponse = `Zookeeper version: 3.4.13-2d71af4dbe22557fda74f9a9b4309b15a7487f03, built on 06/29/2018 04:05 GMT
+Latency min/avg/max: 0/1.5/23
+Received: 1203
+Sent: 1202
+Connections: 2
+Outstanding: 12
+Zxid: 0x10000002a
+Mode: follower
+Node count: 4
+`
+
+func TestParseSrvr(t *testing.T) {
+	stat, err := parseSrvr(strings.NewReader(srvrResponse))
+	assert.Nil(t, err)
+	assert.Equal(t, &serverStat{
+		version:     "3.4.13",
+		mode:        "follower",
+		connections: 2,
+		outstanding: 12,
+		zxid:        "0x10000002a",
+		minLatency:  0,
+		avgLatency:  1.5,
+		maxLatency:  23,
+	}, stat)
+
+	_, err = parseSrvr(strings.NewReader("srvr is not executed because it is not in the whitelist.\n"))
+	assert.NotNil(t, err)
+}
+
+func TestEvalStat(t *testing.T) {
+	stat, _ := parseSrvr(strings.NewReader(srvrResponse))
+	ckr := evalStat(&zookeeperOpts{}, stat)
+	assert.Equal(t, checkers.OK, ckr.Status)
+	assert.Equal(t, "mode follower, latency min/avg/max 0/1.5/23 ms, connections 2, outstanding 12, zxid 0x10000002a, version 3.4.13", ckr.Message)
+
+	warn, crit := 1.0, 10.0
+	ckr = evalStat(&zookeeperOpts{Warning: &warn, Critical: &crit}, stat)
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+
+	outWarn, outCrit := int64(5), int64(10)
+	ckr = evalStat(&zookeeperOpts{Warning: &warn, Critical: &crit, OutstandingWarning: &outWarn, OutstandingCritical: &outCrit}, stat)
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+}
+
+// fakeZookeeper starts the server which returns resp to srvr
+func fakeZookeeper(t *testing.T, resp string) (port int, closer func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			buf := make([]byte, 4)
+			conn.Read(buf)
+			if string(buf) == "srvr" {
+				conn.Write([]byte(resp))
+			}
+			conn.Close()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, func() { ln.Close() }
+}
+
+func TestRun(t *testing.T) {
+	port, closer := fakeZookeeper(t, srvrResponse)
+	defer closer()
+
+	ckr := run([]string{"-H", "127.0.0.1", "-p", strconv.Itoa(port), "--outstanding-warning", "10"})
+	assert.Equal(t, checkers.WARNING, ckr.Status)
+	assert.Regexp(t, `^mode follower, `, ckr.Message)
+}
+
+func TestRunNotServing(t *testing.T) {
+	port, closer := fakeZookeeper(t, "This ZooKeeper instance is not currently serving requests\n")
+	defer closer()
+
+	ckr := run([]string{"-H", "127.0.0.1", "-p", strconv.Itoa(port)})
+	assert.Equal(t, checkers.CRITICAL, ckr.Status)
+	assert.Equal(t, "This ZooKeeper instance is not currently serving requests", ckr.Message)
+}
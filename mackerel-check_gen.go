@@ -7,30 +7,47 @@ import (
 	"github.com/mackerelio/go-check-plugins/check-aws-cloudwatch-logs/lib"
 	"github.com/mackerelio/go-check-plugins/check-aws-sqs-queue-size/lib"
 	"github.com/mackerelio/go-check-plugins/check-cert-file/lib"
+	"github.com/mackerelio/go-check-plugins/check-consul/lib"
 	"github.com/mackerelio/go-check-plugins/check-disk/lib"
+	"github.com/mackerelio/go-check-plugins/check-dns/lib"
+	"github.com/mackerelio/go-check-plugins/check-docker/lib"
 	"github.com/mackerelio/go-check-plugins/check-elasticsearch/lib"
+	"github.com/mackerelio/go-check-plugins/check-etcd/lib"
 	"github.com/mackerelio/go-check-plugins/check-file-age/lib"
 	"github.com/mackerelio/go-check-plugins/check-file-size/lib"
+	"github.com/mackerelio/go-check-plugins/check-haproxy/lib"
 	"github.com/mackerelio/go-check-plugins/check-http/lib"
 	"github.com/mackerelio/go-check-plugins/check-jmx-jolokia/lib"
+	"github.com/mackerelio/go-check-plugins/check-kafka/lib"
 	"github.com/mackerelio/go-check-plugins/check-ldap/lib"
 	"github.com/mackerelio/go-check-plugins/check-load/lib"
 	"github.com/mackerelio/go-check-plugins/check-log/lib"
 	"github.com/mackerelio/go-check-plugins/check-mailq/lib"
 	"github.com/mackerelio/go-check-plugins/check-masterha/lib"
 	"github.com/mackerelio/go-check-plugins/check-memcached/lib"
+	"github.com/mackerelio/go-check-plugins/check-memory/lib"
+	"github.com/mackerelio/go-check-plugins/check-mongodb/lib"
 	"github.com/mackerelio/go-check-plugins/check-mysql/lib"
+	"github.com/mackerelio/go-check-plugins/check-network-interface/lib"
+	"github.com/mackerelio/go-check-plugins/check-nginx/lib"
 	"github.com/mackerelio/go-check-plugins/check-ntpoffset/lib"
 	"github.com/mackerelio/go-check-plugins/check-ping/lib"
 	"github.com/mackerelio/go-check-plugins/check-postgresql/lib"
 	"github.com/mackerelio/go-check-plugins/check-procs/lib"
+	"github.com/mackerelio/go-check-plugins/check-rabbitmq/lib"
 	"github.com/mackerelio/go-check-plugins/check-redis/lib"
 	"github.com/mackerelio/go-check-plugins/check-smtp/lib"
+	"github.com/mackerelio/go-check-plugins/check-snmp/lib"
 	"github.com/mackerelio/go-check-plugins/check-solr/lib"
 	"github.com/mackerelio/go-check-plugins/check-ssh/lib"
 	"github.com/mackerelio/go-check-plugins/check-ssl-cert/lib"
+	"github.com/mackerelio/go-check-plugins/check-swap/lib"
+	"github.com/mackerelio/go-check-plugins/check-systemd/lib"
 	"github.com/mackerelio/go-check-plugins/check-tcp/lib"
 	"github.com/mackerelio/go-check-plugins/check-uptime/lib"
+	"github.com/mackerelio/go-check-plugins/check-users/lib"
+	"github.com/mackerelio/go-check-plugins/check-zombie/lib"
+	"github.com/mackerelio/go-check-plugins/check-zookeeper/lib"
 )
 
 func runPlugin(plug string) error {
@@ -41,18 +58,30 @@ func runPlugin(plug string) error {
 		checkawssqsqueuesize.Do()
 	case "cert-file":
 		checkcertfile.Do()
+	case "consul":
+		checkconsul.Do()
 	case "disk":
 		checkdisk.Do()
+	case "dns":
+		checkdns.Do()
+	case "docker":
+		checkdocker.Do()
 	case "elasticsearch":
 		checkelasticsearch.Do()
+	case "etcd":
+		checketcd.Do()
 	case "file-age":
 		checkfileage.Do()
 	case "file-size":
 		checkfilesize.Do()
+	case "haproxy":
+		checkhaproxy.Do()
 	case "http":
 		checkhttp.Do()
 	case "jmx-jolokia":
 		checkjmxjolokia.Do()
+	case "kafka":
+		checkkafka.Do()
 	case "ldap":
 		checkldap.Do()
 	case "load":
@@ -65,8 +94,16 @@ func runPlugin(plug string) error {
 		checkmasterha.Do()
 	case "memcached":
 		checkmemcached.Do()
+	case "memory":
+		checkmemory.Do()
+	case "mongodb":
+		checkmongodb.Do()
 	case "mysql":
 		checkmysql.Do()
+	case "network-interface":
+		checknetworkinterface.Do()
+	case "nginx":
+		checknginx.Do()
 	case "ntpoffset":
 		checkntpoffset.Do()
 	case "ping":
@@ -75,20 +112,34 @@ func runPlugin(plug string) error {
 		checkpostgresql.Do()
 	case "procs":
 		checkprocs.Do()
+	case "rabbitmq":
+		checkrabbitmq.Do()
 	case "redis":
 		checkredis.Do()
 	case "smtp":
 		checksmtp.Do()
+	case "snmp":
+		checksnmp.Do()
 	case "solr":
 		checksolr.Do()
 	case "ssh":
 		checkssh.Do()
 	case "ssl-cert":
 		checksslcert.Do()
+	case "swap":
+		checkswap.Do()
+	case "systemd":
+		checksystemd.Do()
 	case "tcp":
 		checktcp.Do()
 	case "uptime":
 		checkuptime.Do()
+	case "users":
+		checkusers.Do()
+	case "zombie":
+		checkzombie.Do()
+	case "zookeeper":
+		checkzookeeper.Do()
 	default:
 		return fmt.Errorf("unknown plugin: %q", plug)
 	}
@@ -99,28 +150,45 @@ var plugins = []string{
 	"aws-cloudwatch-logs",
 	"aws-sqs-queue-size",
 	"cert-file",
+	"consul",
 	"disk",
+	"dns",
+	"docker",
 	"elasticsearch",
+	"etcd",
 	"file-age",
 	"file-size",
+	"haproxy",
 	"http",
 	"jmx-jolokia",
+	"kafka",
 	"ldap",
 	"load",
 	"log",
 	"mailq",
 	"masterha",
 	"memcached",
+	"memory",
+	"mongodb",
 	"mysql",
+	"network-interface",
+	"nginx",
 	"ntpoffset",
 	"ping",
 	"postgresql",
 	"procs",
+	"rabbitmq",
 	"redis",
 	"smtp",
+	"snmp",
 	"solr",
 	"ssh",
 	"ssl-cert",
+	"swap",
+	"systemd",
 	"tcp",
 	"uptime",
+	"users",
+	"zombie",
+	"zookeeper",
 }
@@ -26,7 +26,7 @@ mackerel.io check plugins
 
 %{__mkdir} -p %{buildroot}%{__targetdir}
 
-for i in aws-cloudwatch-logs aws-sqs-queue-size cert-file disk elasticsearch file-age file-size http jmx-jolokia ldap load log mailq masterha memcached mysql ntpoffset ping postgresql procs redis smtp solr ssh ssl-cert tcp uptime; do \
+for i in aws-cloudwatch-logs aws-sqs-queue-size cert-file consul disk dns docker elasticsearch etcd file-age file-size haproxy http jmx-jolokia kafka ldap load log mailq masterha memcached memory mongodb mysql network-interface nginx ntpoffset ping postgresql procs rabbitmq redis smtp snmp solr ssh ssl-cert swap systemd tcp uptime users zombie zookeeper; do \
     %{__install} -m0755 %{_sourcedir}/build/check-$i %{buildroot}%{__targetdir}/; \
 done
 